@@ -0,0 +1,30 @@
+package cpi
+
+import (
+	"bosh-virtualbox-cpi/driver"
+)
+
+type AuditFilter struct {
+	VMCID     string
+	RequestID string
+}
+
+// AuditEntries returns recorded VBoxManage invocations in the order they happened
+func (f Factory) AuditEntries(filter AuditFilter) ([]driver.AuditEntry, error) {
+	audit := driver.NewAuditLogImpl(f.newRunner(), f.opts.AuditLogPath(), "", "", f.logger)
+
+	entries, err := audit.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []driver.AuditEntry
+
+	for _, entry := range entries {
+		if entry.Matches(filter.VMCID, filter.RequestID) {
+			matching = append(matching, entry)
+		}
+	}
+
+	return matching, nil
+}
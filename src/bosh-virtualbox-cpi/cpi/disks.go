@@ -18,8 +18,13 @@ func NewDisks(creator bdisk.Creator, finder bdisk.Finder, vmFinder bvm.Finder) D
 	return Disks{creator, finder, vmFinder}
 }
 
-func (a Disks) CreateDisk(size int, _ apiv1.DiskCloudProps, _ *apiv1.VMCID) (apiv1.DiskCID, error) {
-	disk, err := a.creator.Create(size)
+func (a Disks) CreateDisk(size int, cloudProps apiv1.DiskCloudProps, _ *apiv1.VMCID) (apiv1.DiskCID, error) {
+	props, err := bdisk.NewProps(cloudProps)
+	if err != nil {
+		return apiv1.DiskCID{}, bosherr.WrapError(err, "Parsing disk cloud properties")
+	}
+
+	disk, err := a.creator.Create(size, props)
 	if err != nil {
 		return apiv1.DiskCID{}, bosherr.WrapErrorf(err, "Creating disk of size '%d'", size)
 	}
@@ -94,9 +99,29 @@ func (a Disks) HasDisk(cid apiv1.DiskCID) (bool, error) {
 }
 
 func (a Disks) SetDiskMetadata(cid apiv1.DiskCID, meta apiv1.DiskMeta) error {
+	disk, err := a.finder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	err = disk.SetMetadata(meta)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Setting metadata for disk '%s'", cid)
+	}
+
 	return nil
 }
 
 func (a Disks) ResizeDisk(cid apiv1.DiskCID, size int) error {
+	disk, err := a.finder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	err = disk.Resize(size)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resizing disk '%s' to '%d'", cid, size)
+	}
+
 	return nil
 }
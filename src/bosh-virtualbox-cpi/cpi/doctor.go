@@ -0,0 +1,450 @@
+package cpi
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+	bnet "bosh-virtualbox-cpi/vm/network"
+)
+
+const (
+	DoctorStatusPass = "pass"
+	DoctorStatusFail = "fail"
+	DoctorStatusSkip = "skip" // check depends on a check that failed
+)
+
+// doctorNetworksConfPath lists IP ranges allowed for host-only networks
+const doctorNetworksConfPath = "/etc/vbox/networks.conf"
+
+// doctorDefaultIPRange is allowed when networks.conf does not exist
+var doctorDefaultIPRange = mustParseCIDR("192.168.56.0/21")
+
+type DoctorOpts struct {
+	MinFreeDiskMB int
+
+	// IPs planned for host-only networks (e.g. of deployed VMs)
+	HostOnlyIPs []net.IP
+}
+
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint"` // how to remediate failure
+}
+
+func (c DoctorCheck) Failed() bool { return c.Status == DoctorStatusFail }
+
+// Doctor checks that configured host is usable by CPI. Checks are run
+// without retries so that misconfiguration is reported quickly.
+func (f Factory) Doctor(opts DoctorOpts) []DoctorCheck {
+	return f.newDoctor(opts, f.newRunner(), driver.NewExecDriverCache(0)).Run()
+}
+
+func (f Factory) newDoctor(opts DoctorOpts, runner *driver.ExpandingPathRunner, cache *driver.ExecDriverCache) *doctor {
+	retrier := f.retrier
+	if retrier == nil {
+		retrier = driver.NewRetrierImpl(driver.RetryPolicy{MaxAttempts: 1}, f.logger)
+	}
+
+	// Store directory might not be writable so nothing is audited
+	drv := driver.NewExecDriver(runner, retrier, f.opts.BinPath, driver.NoopAuditLog{}, f.logger).WithCache(cache)
+
+	d := &doctor{
+		opts:     opts,
+		cpiOpts:  f.opts,
+		runner:   runner,
+		driver:   drv,
+		networks: bnet.NewNetworks(drv, f.logger),
+		tmpName:  ".doctor",
+	}
+
+	if id, err := f.uuidGen.Generate(); err == nil {
+		d.tmpName = ".doctor-" + id
+	}
+
+	return d
+}
+
+type doctor struct {
+	opts    DoctorOpts
+	cpiOpts FactoryOpts
+
+	runner   *driver.ExpandingPathRunner
+	driver   driver.Driver
+	networks bnet.Networks
+
+	tmpName string // directory created in StoreDir
+
+	// Populated by checks for subsequent checks
+	storeDir      string
+	caps          driver.Capabilities
+	versionOutput string
+	hostOnlys     []bnet.Network
+
+	checks []DoctorCheck
+}
+
+func (d *doctor) Run() []DoctorCheck {
+	connected := d.check("Host connectivity", nil, d.checkConnectivity)
+	vboxManage := d.check("VBoxManage version", []bool{connected}, d.checkVersion)
+	d.check("Kernel modules", []bool{vboxManage}, d.checkKernelModules)
+	stored := d.check("Store directory", []bool{connected}, d.checkStoreDir)
+	d.check("Disk space", []bool{stored}, d.checkDiskSpace)
+	listed := d.check("Network listing", []bool{vboxManage}, d.checkNetworks)
+	d.check("Host-only IP ranges", []bool{listed}, d.checkIPRanges)
+	d.check("Test disk", []bool{vboxManage, stored}, d.checkTestDisk)
+
+	return d.checks
+}
+
+// Preflight only runs checks that are necessary for every CPI call
+func (d *doctor) Preflight() []DoctorCheck {
+	connected := d.check("Host connectivity", nil, d.checkConnectivity)
+	d.check("VBoxManage version", []bool{connected}, d.checkVersion)
+	d.check("Store directory", []bool{connected}, d.checkStoreDir)
+
+	return d.checks
+}
+
+// check runs checkFunc unless any of required checks did not pass.
+// checkFunc returns detail on success or detail and hint on failure.
+func (d *doctor) check(name string, required []bool, checkFunc func() (string, string, error)) bool {
+	for _, passed := range required {
+		if !passed {
+			d.checks = append(d.checks, DoctorCheck{Name: name, Status: DoctorStatusSkip})
+			return false
+		}
+	}
+
+	detail, hint, err := checkFunc()
+	if err != nil {
+		if len(detail) == 0 {
+			detail = err.Error()
+		}
+		d.checks = append(d.checks, DoctorCheck{Name: name, Status: DoctorStatusFail, Detail: detail, Hint: hint})
+		return false
+	}
+
+	d.checks = append(d.checks, DoctorCheck{Name: name, Status: DoctorStatusPass, Detail: detail})
+
+	return true
+}
+
+func (d *doctor) checkConnectivity() (string, string, error) {
+	target := "local"
+
+	if len(d.cpiOpts.Host) > 0 {
+		target = fmt.Sprintf("%s@%s", d.cpiOpts.Username, d.cpiOpts.Host)
+	}
+
+	_, err := d.runner.ExpandPath("~")
+	if err != nil {
+		return "", "Check host, port, username and private key; " +
+			"host key must match known_hosts or host_key_fingerprint", err
+	}
+
+	return target, "", nil
+}
+
+func (d *doctor) checkVersion() (string, string, error) {
+	var err error
+
+	d.versionOutput, err = d.driver.Execute("--version")
+	if err != nil {
+		return "", d.vboxManageHint(err), err
+	}
+
+	d.caps, err = d.driver.Capabilities()
+	if err != nil {
+		return "", d.vboxManageHint(err), err
+	}
+
+	detail := fmt.Sprintf("VirtualBox %s on %s", d.caps.Version, d.caps.HostOS)
+
+	if !d.caps.SupportedVersion() {
+		return detail, "Upgrade VirtualBox to 5.0 or newer", bosherr.Error("Unsupported VirtualBox version")
+	}
+
+	if !d.caps.SupportsStemcellStorageController(d.cpiOpts.StorageController) {
+		detail += fmt.Sprintf("; storage controller '%s' is not supported", d.cpiOpts.StorageController)
+		hint := fmt.Sprintf("Set storage_controller to one of: %s",
+			strings.Join(d.caps.StemcellStorageControllers(), ", "))
+		return detail, hint, bosherr.Error("Unsupported storage controller")
+	}
+
+	return detail, "", nil
+}
+
+func (d *doctor) vboxManageHint(err error) string {
+	if vboxErr, ok := driver.AsVBoxError(err); ok && vboxErr.Status == 127 {
+		return fmt.Sprintf("Install VirtualBox or set bin_path to location of VBoxManage (currently '%s')", d.cpiOpts.BinPath)
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(msg, "not found") || strings.Contains(msg, "no such file"):
+		return fmt.Sprintf("Install VirtualBox or set bin_path to location of VBoxManage (currently '%s')", d.cpiOpts.BinPath)
+	case strings.Contains(msg, "corrupted virtualbox installation"):
+		return "Reinstall VirtualBox"
+	case strings.Contains(msg, "vboxnetctl") || strings.Contains(msg, "vboxdrv"):
+		return d.kernelModulesHint()
+	default:
+		return "Run VBoxManage --version on the host to see the problem"
+	}
+}
+
+func (d *doctor) checkKernelModules() (string, string, error) {
+	// VBoxManage warns before its version if kernel driver is not loaded
+	for _, line := range strings.Split(d.versionOutput, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "WARNING:") {
+			return strings.TrimSpace(line), d.kernelModulesHint(), bosherr.Error("Kernel driver is not loaded")
+		}
+	}
+
+	if d.caps.HostOS == driver.HostOSWindows {
+		return "not checked on Windows", "", nil
+	}
+
+	devices := []string{"/dev/vboxdrv"}
+
+	// Host-only networks use vmnet framework instead of kernel extension
+	if !d.caps.HostOnlyNets() {
+		devices = append(devices, "/dev/vboxnetctl")
+	}
+
+	for _, device := range devices {
+		_, err := d.runner.Stat(device)
+		if err != nil {
+			return fmt.Sprintf("'%s' is missing", device), d.kernelModulesHint(), err
+		}
+	}
+
+	return strings.Join(devices, ", "), "", nil
+}
+
+func (d *doctor) kernelModulesHint() string {
+	if d.caps.HostOS == driver.HostOSDarwin {
+		return "Allow Oracle kernel extensions in System Settings > Privacy & Security and restart the host"
+	}
+	return "Load kernel modules with 'sudo /sbin/vboxconfig' (or 'sudo modprobe vboxdrv vboxnetadp vboxnetflt')"
+}
+
+func (d *doctor) checkStoreDir() (string, string, error) {
+	hint := fmt.Sprintf("Make store_dir writable by the user CPI connects as or point it elsewhere (currently '%s')", d.cpiOpts.StoreDir)
+
+	storeDir, err := d.runner.ExpandPath(d.cpiOpts.StoreDir)
+	if err != nil {
+		return "", hint, err
+	}
+
+	tmpDir := filepath.Join(storeDir, d.tmpName)
+
+	err = d.runner.MkdirAll(tmpDir)
+	if err != nil {
+		return "", hint, err
+	}
+
+	defer d.runner.RemoveAll(tmpDir)
+
+	err = d.runner.Put(filepath.Join(tmpDir, "test"), []byte("test"))
+	if err != nil {
+		return "", hint, err
+	}
+
+	d.storeDir = storeDir
+
+	return storeDir, "", nil
+}
+
+func (d *doctor) checkDiskSpace() (string, string, error) {
+	hint := "Free up space or point store_dir to a larger volume"
+
+	if d.caps.HostOS == driver.HostOSWindows {
+		return "not checked on Windows", "", nil
+	}
+
+	output, _, err := d.runner.Execute("df", "-P", "-k", d.storeDir)
+	if err != nil {
+		return "", hint, bosherr.WrapError(err, "Running df")
+	}
+
+	freeMB, err := doctorParseDf(output)
+	if err != nil {
+		return "", hint, err
+	}
+
+	detail := fmt.Sprintf("%d MB free", freeMB)
+
+	if freeMB < d.opts.MinFreeDiskMB {
+		detail += fmt.Sprintf(", at least %d MB required", d.opts.MinFreeDiskMB)
+		return detail, hint, bosherr.Error("Not enough disk space")
+	}
+
+	return detail, "", nil
+}
+
+// doctorParseDf returns available space from POSIX `df -P -k` output
+func doctorParseDf(output string) (int, error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[0] == "Filesystem" {
+			continue
+		}
+
+		freeKB, err := strconv.Atoi(fields[3])
+		if err != nil {
+			break
+		}
+
+		return freeKB / 1024, nil
+	}
+
+	return 0, bosherr.Errorf("Unexpected df output '%s'", strings.TrimSpace(output))
+}
+
+func (d *doctor) checkNetworks() (string, string, error) {
+	hint := "Networking requires vboxnetadp and vboxnetflt kernel modules; " +
+		"managing host-only networks might require running as root on Linux"
+
+	var err error
+
+	d.hostOnlys, err = d.networks.HostOnlys()
+	if err != nil {
+		return "", hint, err
+	}
+
+	natNets, err := d.networks.NATNetworks()
+	if err != nil {
+		return "", hint, err
+	}
+
+	bridgedNets, err := d.networks.BridgedNetworks()
+	if err != nil {
+		return "", hint, err
+	}
+
+	return fmt.Sprintf("%d host-only, %d NAT, %d bridged", len(d.hostOnlys), len(natNets), len(bridgedNets)), "", nil
+}
+
+func (d *doctor) checkIPRanges() (string, string, error) {
+	if !d.caps.HostOnlyIPRanges() {
+		return "not restricted by this VirtualBox version", "", nil
+	}
+
+	allowed, err := d.allowedIPRanges()
+	if err != nil {
+		return "", fmt.Sprintf("Make '%s' readable", doctorNetworksConfPath), err
+	}
+
+	ips := append([]net.IP{}, d.opts.HostOnlyIPs...)
+
+	for _, hostOnly := range d.hostOnlys {
+		if ipNet := hostOnly.IPNet(); ipNet != nil {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+
+	var disallowed []string
+
+	for _, ip := range ips {
+		if !doctorIPAllowed(ip, allowed) {
+			disallowed = append(disallowed, ip.String())
+		}
+	}
+
+	var ranges []string
+
+	for _, ipNet := range allowed {
+		ranges = append(ranges, ipNet.String())
+	}
+
+	if len(disallowed) > 0 {
+		detail := fmt.Sprintf("%s not in allowed ranges %s", strings.Join(disallowed, ", "), strings.Join(ranges, ", "))
+		hint := fmt.Sprintf("Add ranges to '%s', e.g. '* 10.0.0.0/8 192.168.0.0/16'", doctorNetworksConfPath)
+		return detail, hint, bosherr.Error("Host-only IPs are not allowed")
+	}
+
+	return strings.Join(ranges, ", "), "", nil
+}
+
+// allowedIPRanges parses lines such as '* 10.0.0.0/8 192.168.0.0/16'
+func (d *doctor) allowedIPRanges() ([]*net.IPNet, error) {
+	contents, err := d.runner.Get(doctorNetworksConfPath)
+	if err != nil {
+		if _, statErr := d.runner.Stat(doctorNetworksConfPath); driver.IsNotExistErr(statErr) {
+			return []*net.IPNet{doctorDefaultIPRange}, nil
+		}
+		return nil, bosherr.WrapErrorf(err, "Reading '%s'", doctorNetworksConfPath)
+	}
+
+	var ranges []*net.IPNet
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "*" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			_, ipNet, err := net.ParseCIDR(field)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Parsing '%s'", doctorNetworksConfPath)
+			}
+			ranges = append(ranges, ipNet)
+		}
+	}
+
+	return ranges, nil
+}
+
+func doctorIPAllowed(ip net.IP, allowed []*net.IPNet) bool {
+	for _, ipNet := range allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *doctor) checkTestDisk() (string, string, error) {
+	hint := "Check that VirtualBox can create media in store_dir (e.g. permissions, SELinux, disk space)"
+
+	tmpDir := filepath.Join(d.storeDir, d.tmpName)
+
+	err := d.runner.MkdirAll(tmpDir)
+	if err != nil {
+		return "", hint, err
+	}
+
+	defer d.runner.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "test.vdi")
+
+	_, err = d.driver.Execute("createhd", "--filename", path, "--size", "1", "--format", "VDI")
+	if err != nil {
+		return "", hint, err
+	}
+
+	_, err = d.driver.Execute("closemedium", "disk", path, "--delete")
+	if err != nil {
+		return "", "Remove test medium with 'VBoxManage closemedium disk <path> --delete'", err
+	}
+
+	return "created and deleted 1 MB medium", "", nil
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
@@ -1,6 +1,9 @@
 package cpi
 
 import (
+	"sync"
+	"time"
+
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -9,6 +12,7 @@ import (
 
 	bdisk "bosh-virtualbox-cpi/disk"
 	"bosh-virtualbox-cpi/driver"
+	bsnap "bosh-virtualbox-cpi/snapshot"
 	bstem "bosh-virtualbox-cpi/stemcell"
 	bvm "bosh-virtualbox-cpi/vm"
 )
@@ -20,6 +24,35 @@ type Factory struct {
 	compressor boshcmd.Compressor
 	opts       FactoryOpts
 	logger     boshlog.Logger
+
+	// Optional; used instead of local or SSH runner (e.g. simulated host)
+	rawRunner driver.RawRunner
+	retrier   driver.Retrier
+
+	// CPI method being served; recorded in audit log
+	method string
+
+	// Optional; shared by CPIs created by this factory (see WithWarmState)
+	warm *warmState
+
+	// Optional; shared by preflight and CPI serving the same request (see ForRequest)
+	request *requestState
+}
+
+// warmState outlives CPI calls when CPI is served by a daemon
+type warmState struct {
+	runner *driver.ExpandingPathRunner // keeps SSH connection and home dir
+	cache  *driver.ExecDriverCache     // keeps capabilities and read caches
+
+	preflightMu   sync.Mutex
+	preflightedAt time.Time
+}
+
+// requestState lets preflight and CPI of a single request use the same
+// SSH connection and VirtualBox capabilities
+type requestState struct {
+	runner *driver.ExpandingPathRunner
+	cache  *driver.ExecDriverCache
 }
 
 var _ apiv1.CPIFactory = Factory{}
@@ -42,24 +75,78 @@ func NewFactory(
 	opts FactoryOpts,
 	logger boshlog.Logger,
 ) Factory {
-	return Factory{fs, cmdRunner, uuidGen, compressor, opts, logger}
+	return Factory{
+		fs:         fs,
+		cmdRunner:  cmdRunner,
+		uuidGen:    uuidGen,
+		compressor: compressor,
+		opts:       opts,
+		logger:     logger,
+	}
 }
 
-func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
-	retrier := driver.RetrierImpl{}
-	rawRunner := driver.RawRunner(driver.NewLocalRunner(f.fs, f.cmdRunner, f.logger))
+// NewFactoryWithRunner returns factory that executes all host commands
+// via given runner instead of running them locally or over SSH.
+func NewFactoryWithRunner(
+	rawRunner driver.RawRunner,
+	retrier driver.Retrier,
+	fs boshsys.FileSystem,
+	uuidGen boshuuid.Generator,
+	compressor boshcmd.Compressor,
+	opts FactoryOpts,
+	logger boshlog.Logger,
+) Factory {
+	f := NewFactory(fs, nil, uuidGen, compressor, opts, logger)
+	f.rawRunner = rawRunner
+	f.retrier = retrier
+	return f
+}
 
-	if len(f.opts.Host) > 0 {
-		runnerOpts := driver.SSHRunnerOpts{
-			Host:       f.opts.Host,
-			Username:   f.opts.Username,
-			PrivateKey: f.opts.PrivateKey,
+// WithMethod returns factory that records given CPI method in audit log
+func (f Factory) WithMethod(method string) Factory {
+	f.method = method
+	return f
+}
+
+// WithWarmState returns factory whose CPIs share SSH connections, capabilities
+// and results of read-only VBoxManage commands (for at most cacheTTL).
+func (f Factory) WithWarmState(cacheTTL time.Duration) Factory {
+	f.warm = &warmState{
+		runner: f.newRunner(),
+		cache:  driver.NewExecDriverCache(cacheTTL),
+	}
+	return f
+}
+
+// ForRequest returns factory whose preflight and CPI share host connection
+// and capabilities. Factories with warm state already share both.
+func (f Factory) ForRequest() Factory {
+	if f.warm == nil {
+		f.request = &requestState{
+			runner: f.newRunner(),
+			cache:  driver.NewExecDriverCache(0),
 		}
-		rawRunner = driver.NewSSHRunner(runnerOpts, f.fs, f.logger)
 	}
+	return f
+}
+
+func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
+	retrier := f.newRetrier()
+
+	var callCtx struct {
+		RequestID string `json:"request_id"`
+	}
+
+	err := ctx.As(&callCtx)
+	if err != nil {
+		f.logger.Debug("cpi.Factory", "Failed to determine request ID: %s", err)
+	}
+
+	runner, cache := f.callRunnerAndCache()
 
-	runner := driver.NewExpandingPathRunner(rawRunner)
-	driver := driver.NewExecDriver(runner, retrier, f.opts.BinPath, f.logger)
+	locks := driver.NewLocks(runner, f.opts.LocksDir(), f.logger)
+	audit := f.newAuditLog(runner, f.method, callCtx.RequestID)
+	driver := driver.NewExecDriver(runner, retrier, f.opts.BinPath, audit, f.logger).WithCache(cache)
 
 	stemcellsOpts := bstem.FactoryOpts{
 		DirPath:           f.opts.StemcellsDir(),
@@ -71,14 +158,19 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 
 	disks := bdisk.NewFactory(f.opts.DisksDir(), f.uuidGen, driver, runner, f.logger)
 
+	snapshots := bsnap.NewFactory(f.opts.SnapshotsDir(), f.uuidGen, driver, runner, f.logger)
+
 	vmsOpts := bvm.FactoryOpts{
 		DirPath:            f.opts.VMsDir(),
 		StorageController:  f.opts.StorageController,
 		AutoEnableNetworks: f.opts.AutoEnableNetworks,
+
+		GracefulShutdown:        f.opts.GracefulShutdown,
+		GracefulShutdownTimeout: f.opts.GracefulShutdownTimeout,
 	}
 
 	vms := bvm.NewFactory(
-		vmsOpts, f.uuidGen, driver, runner, disks,
+		vmsOpts, f.uuidGen, driver, runner, locks, disks,
 		f.opts.Agent, apiv1.NewStemcellAPIVersion(ctx), f.logger)
 
 	return CPI{
@@ -86,6 +178,50 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 		NewStemcells(stemcells, stemcells),
 		NewVMs(stemcells, vms, vms),
 		NewDisks(disks, disks, vms),
-		NewSnapshots(),
+		NewSnapshots(snapshots, snapshots, disks),
 	}, nil
 }
+
+// newCmdDriver returns driver for commands (e.g. inventory) that are not CPI calls
+func (f Factory) newCmdDriver(runner driver.Runner, cmd string) driver.ExecDriver {
+	audit := f.newAuditLog(runner, cmd, "")
+	return driver.NewExecDriver(runner, f.newRetrier(), f.opts.BinPath, audit, f.logger)
+}
+
+func (f Factory) newAuditLog(runner driver.Runner, method, requestID string) driver.AuditLog {
+	if f.opts.DisableAuditLog {
+		return driver.NoopAuditLog{}
+	}
+	return driver.NewAuditLogImpl(runner, f.opts.AuditLogPath(), method, requestID, f.logger)
+}
+
+func (f Factory) newRetrier() driver.Retrier {
+	if f.retrier != nil {
+		return f.retrier
+	}
+	return driver.NewRetrierImpl(f.opts.RetryPolicy(), f.logger)
+}
+
+// callRunnerAndCache returns runner and driver cache for a single CPI call
+func (f Factory) callRunnerAndCache() (*driver.ExpandingPathRunner, *driver.ExecDriverCache) {
+	switch {
+	case f.warm != nil:
+		return f.warm.runner, f.warm.cache.ForCall()
+	case f.request != nil:
+		return f.request.runner, f.request.cache
+	default:
+		return f.newRunner(), driver.NewExecDriverCache(0)
+	}
+}
+
+func (f Factory) newRunner() *driver.ExpandingPathRunner {
+	rawRunner := driver.RawRunner(driver.NewLocalRunner(f.fs, f.cmdRunner, f.logger))
+
+	if f.rawRunner != nil {
+		rawRunner = f.rawRunner
+	} else if len(f.opts.Host) > 0 {
+		rawRunner = driver.NewSSHRunner(f.opts.SSHRunnerOpts(), f.fs, f.logger)
+	}
+
+	return driver.NewExpandingPathRunner(rawRunner)
+}
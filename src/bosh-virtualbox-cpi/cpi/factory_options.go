@@ -2,35 +2,122 @@ package cpi
 
 import (
 	"path/filepath"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
 
+	"bosh-virtualbox-cpi/driver"
 	bpds "bosh-virtualbox-cpi/vm/portdevices"
 )
 
 type FactoryOpts struct {
 	Host       string
+	Port       int
 	Username   string
 	PrivateKey string
 
+	// Relative PrivateKeyPath and KnownHostsPath are resolved against configuration file directory
+	PrivateKeyPath       string
+	PrivateKeyPassphrase string
+	UseSSHAgent          bool
+
+	KnownHostsPath     string
+	HostKeyFingerprint string
+	IgnoreHostKey      bool // must be explicitly enabled if neither of above is provided
+
+	// Jump hosts are connected to in the given order before Host
+	JumpHosts []JumpHostOpts
+
+	// Interval in seconds between SSH keepalive requests (0 uses default, -1 disables)
+	KeepAliveInterval int
+
 	BinPath  string
 	StoreDir string
 
 	StorageController  string
 	AutoEnableNetworks bool
 
+	// Checks VirtualBox host before serving first CPI request (see Factory.Preflight)
+	Preflight bool
+
+	// Audit log costs one extra round trip to VirtualBox host per VBoxManage invocation
+	DisableAuditLog bool
+
+	// Running VMs are first asked to shut down via ACPI power button
+	// and are only powered off if they are still running after timeout.
+	// Can be overridden per VM via cloud properties.
+	GracefulShutdown        bool
+	GracefulShutdownTimeout int // in seconds
+
+	// Applies to VBoxManage commands failing with transient errors and to uploads
+	Retry RetryOpts
+
+	// Only used when CPI is served by a long-running daemon
+	Daemon DaemonOpts
+
 	Agent apiv1.AgentOptions
 }
 
+// RetryOpts uses driver's default for each option that is not set
+type RetryOpts struct {
+	MaxAttempts int
+
+	InitialBackoff float64 // in seconds
+	MaxBackoff     float64 // in seconds
+	Multiplier     float64
+	Jitter         float64 // fraction of backoff (0..1)
+
+	Deadline int // in seconds; 0 means no deadline
+}
+
+type DaemonOpts struct {
+	// Unix socket shared by daemon and its clients; defaults to a path in
+	// temporary directory derived from configuration file and CPI binary
+	SocketPath string
+
+	// Daemon exits after not serving any requests for this long (0 means never)
+	IdleTimeout int // in seconds
+
+	// Results of read-only VBoxManage commands are reused by subsequent
+	// CPI calls for this long (0 means only within the same call)
+	CacheTTL float64 // in seconds
+}
+
+// JumpHostOpts has the same meaning as SSH related options of FactoryOpts
+type JumpHostOpts struct {
+	Host       string
+	Port       int
+	Username   string
+	PrivateKey string
+
+	PrivateKeyPath       string
+	PrivateKeyPassphrase string
+	UseSSHAgent          bool
+
+	KnownHostsPath     string
+	HostKeyFingerprint string
+	IgnoreHostKey      bool
+}
+
 func (o FactoryOpts) Validate() error {
 	if len(o.Host) > 0 {
-		if o.Username == "" {
-			return bosherr.Error("Must provide non-empty Username")
+		err := o.hostOpts().validate()
+		if err != nil {
+			return err
 		}
+	} else if len(o.JumpHosts) > 0 {
+		return bosherr.Error("Must provide non-empty Host when using JumpHosts")
+	}
 
-		if o.PrivateKey == "" {
-			return bosherr.Error("Must provide non-empty PrivateKey")
+	for i, jumpHost := range o.JumpHosts {
+		if jumpHost.Host == "" {
+			return bosherr.Errorf("Must provide non-empty Host for jump host %d", i)
+		}
+
+		err := jumpHost.validate()
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Validating jump host '%s'", jumpHost.Host)
 		}
 	}
 
@@ -49,7 +136,20 @@ func (o FactoryOpts) Validate() error {
 		return bosherr.Error("Unexpected StorageController")
 	}
 
-	err := o.Agent.Validate()
+	if o.GracefulShutdownTimeout < 0 {
+		return bosherr.Error("Must provide non-negative GracefulShutdownTimeout")
+	}
+
+	err := o.Retry.validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating Retry configuration")
+	}
+
+	if o.Daemon.IdleTimeout < 0 || o.Daemon.CacheTTL < 0 {
+		return bosherr.Error("Must provide non-negative Daemon IdleTimeout and CacheTTL")
+	}
+
+	err = o.Agent.Validate()
 	if err != nil {
 		return bosherr.WrapError(err, "Validating Agent configuration")
 	}
@@ -57,6 +157,49 @@ func (o FactoryOpts) Validate() error {
 	return nil
 }
 
+func (o FactoryOpts) SSHRunnerOpts() driver.SSHRunnerOpts {
+	opts := driver.SSHRunnerOpts{
+		SSHHostOpts:       o.hostOpts().sshHostOpts(),
+		KeepAliveInterval: time.Duration(o.KeepAliveInterval) * time.Second,
+	}
+
+	for _, jumpHost := range o.JumpHosts {
+		opts.JumpHosts = append(opts.JumpHosts, jumpHost.sshHostOpts())
+	}
+
+	return opts
+}
+
+func (o FactoryOpts) RetryPolicy() driver.RetryPolicy {
+	return o.Retry.retryPolicy()
+}
+
+// hostOpts extracts connection options for the final host
+func (o FactoryOpts) hostOpts() JumpHostOpts {
+	return JumpHostOpts{
+		Host:       o.Host,
+		Port:       o.Port,
+		Username:   o.Username,
+		PrivateKey: o.PrivateKey,
+
+		PrivateKeyPath:       o.PrivateKeyPath,
+		PrivateKeyPassphrase: o.PrivateKeyPassphrase,
+		UseSSHAgent:          o.UseSSHAgent,
+
+		KnownHostsPath:     o.KnownHostsPath,
+		HostKeyFingerprint: o.HostKeyFingerprint,
+		IgnoreHostKey:      o.IgnoreHostKey,
+	}
+}
+
+func (o FactoryOpts) DaemonIdleTimeout() time.Duration {
+	return time.Duration(o.Daemon.IdleTimeout) * time.Second
+}
+
+func (o FactoryOpts) DaemonCacheTTL() time.Duration {
+	return seconds(o.Daemon.CacheTTL)
+}
+
 func (o FactoryOpts) StemcellsDir() string {
 	return filepath.Join(o.StoreDir, "stemcells")
 }
@@ -68,3 +211,103 @@ func (o FactoryOpts) VMsDir() string {
 func (o FactoryOpts) DisksDir() string {
 	return filepath.Join(o.StoreDir, "disks")
 }
+
+func (o FactoryOpts) SnapshotsDir() string {
+	return filepath.Join(o.StoreDir, "snapshots")
+}
+
+func (o FactoryOpts) LocksDir() string {
+	return filepath.Join(o.StoreDir, "locks")
+}
+
+func (o FactoryOpts) AuditLogPath() string {
+	return filepath.Join(o.StoreDir, "audit.log")
+}
+
+func (o FactoryOpts) PreflightPath() string {
+	return filepath.Join(o.StoreDir, "preflight.json")
+}
+
+func (o RetryOpts) validate() error {
+	if o.MaxAttempts < 0 {
+		return bosherr.Error("Must provide non-negative MaxAttempts")
+	}
+
+	if o.InitialBackoff < 0 || o.MaxBackoff < 0 || o.Deadline < 0 {
+		return bosherr.Error("Must provide non-negative InitialBackoff, MaxBackoff and Deadline")
+	}
+
+	if o.Multiplier != 0 && o.Multiplier < 1 {
+		return bosherr.Error("Must provide Multiplier of at least 1")
+	}
+
+	if o.Jitter < 0 || o.Jitter > 1 {
+		return bosherr.Error("Must provide Jitter between 0 and 1")
+	}
+
+	return nil
+}
+
+func (o RetryOpts) retryPolicy() driver.RetryPolicy {
+	policy := driver.DefaultRetryPolicy()
+
+	if o.MaxAttempts > 0 {
+		policy.MaxAttempts = o.MaxAttempts
+	}
+	if o.InitialBackoff > 0 {
+		policy.InitialBackoff = seconds(o.InitialBackoff)
+	}
+	if o.MaxBackoff > 0 {
+		policy.MaxBackoff = seconds(o.MaxBackoff)
+	}
+	if o.Multiplier > 0 {
+		policy.Multiplier = o.Multiplier
+	}
+	policy.Jitter = o.Jitter
+	if o.Deadline > 0 {
+		policy.Deadline = time.Duration(o.Deadline) * time.Second
+	}
+
+	return policy
+}
+
+func seconds(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second))
+}
+
+func (o JumpHostOpts) validate() error {
+	if o.Username == "" {
+		return bosherr.Error("Must provide non-empty Username")
+	}
+
+	if o.PrivateKey == "" && o.PrivateKeyPath == "" && !o.UseSSHAgent {
+		return bosherr.Error("Must provide non-empty PrivateKey, PrivateKeyPath or enable UseSSHAgent")
+	}
+
+	if o.Port < 0 || o.Port > 65535 {
+		return bosherr.Error("Must provide valid Port")
+	}
+
+	if o.KnownHostsPath == "" && o.HostKeyFingerprint == "" && !o.IgnoreHostKey {
+		return bosherr.Error("Must provide non-empty KnownHostsPath, HostKeyFingerprint or enable IgnoreHostKey")
+	}
+
+	return nil
+}
+
+func (o JumpHostOpts) sshHostOpts() driver.SSHHostOpts {
+	return driver.SSHHostOpts{
+		Host:     o.Host,
+		Port:     o.Port,
+		Username: o.Username,
+
+		PrivateKey:           o.PrivateKey,
+		PrivateKeyPath:       o.PrivateKeyPath,
+		PrivateKeyPassphrase: o.PrivateKeyPassphrase,
+		UseAgent:             o.UseSSHAgent,
+
+		KnownHostsPath:     o.KnownHostsPath,
+		HostKeyFingerprint: o.HostKeyFingerprint,
+		IgnoreHostKey:      o.IgnoreHostKey,
+	}
+}
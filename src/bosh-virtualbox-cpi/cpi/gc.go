@@ -0,0 +1,281 @@
+package cpi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+const (
+	GCActionDelete  = "delete" // would be deleted if confirmed
+	GCActionDeleted = "deleted"
+	GCActionSkip    = "skip"
+	GCActionFailed  = "failed"
+)
+
+const (
+	gcReasonUnknown  = "not known to Director"
+	gcReasonUnusedBy = "not attached to any VM known to Director"
+)
+
+type GCOpts struct {
+	// CIDs referenced by Director state; when nil, only resources
+	// that are inconsistent between StoreDir and VirtualBox are collected
+	KnownCIDs map[string]bool
+
+	// Resources modified more recently (e.g. being created) are kept
+	MinAge time.Duration
+
+	// Otherwise only reports what would be deleted
+	Confirm bool
+}
+
+type GCResult struct {
+	Kind   string `json:"kind"`
+	CID    string `json:"cid"`
+	Reason string `json:"reason"` // why resource is considered garbage
+	Action string `json:"action"`
+	Note   string `json:"note"` // why resource was skipped or failed to be deleted
+}
+
+func (r GCResult) Failed() bool { return r.Action == GCActionFailed }
+
+// CollectGarbage deletes VMs, then disks and finally stemcells that are no longer
+// used. Stemcells are only deleted once all of their linked clones are gone.
+func (f Factory) CollectGarbage(opts GCOpts) ([]GCResult, error) {
+	inv, err := f.Inventory()
+	if err != nil {
+		return nil, err
+	}
+
+	gc := garbageCollector{opts: opts, inv: inv, now: time.Now()}
+
+	results := gc.candidates()
+
+	if opts.Confirm {
+		cpi, err := f.WithMethod("gc").New(cmdCallContext{})
+		if err != nil {
+			return nil, err
+		}
+
+		runner := f.newRunner()
+		gc.cpi = cpi
+		gc.driver = f.newCmdDriver(runner, "gc")
+	}
+
+	deletedVMs := map[string]bool{}
+
+	for i, res := range results {
+		if res.Action != GCActionDelete {
+			continue
+		}
+
+		switch res.Kind {
+		case InventoryKindVM:
+			results[i] = gc.deleteVM(res)
+			deletedVMs[res.CID] = !results[i].Failed()
+
+		case InventoryKindDisk:
+			results[i] = gc.deleteDisk(res, deletedVMs)
+
+		case InventoryKindStemcell:
+			results[i] = gc.deleteStemcell(res, deletedVMs)
+		}
+	}
+
+	return results, nil
+}
+
+// cmdCallContext is used for CPI calls made by commands instead of Director
+type cmdCallContext struct{}
+
+func (cmdCallContext) As(interface{}) error { return nil }
+
+type garbageCollector struct {
+	opts GCOpts
+	inv  Inventory
+	now  time.Time
+
+	// Only set when deleting
+	cpi    apiv1.CPI
+	driver driver.Driver
+}
+
+// candidates are ordered so that VMs are deleted before their disks and stemcells
+func (gc garbageCollector) candidates() []GCResult {
+	var results []GCResult
+
+	orphans := map[string]string{}
+	for _, orphan := range gc.inv.Orphans {
+		orphans[orphan.CID] = orphan.Reason
+	}
+
+	// VMs that would be deleted
+	vmCandidates := map[string]bool{}
+
+	for _, vm := range gc.inv.VMs {
+		if reason, found := gc.reason(vm.CID, orphans); found {
+			res := gc.checkAge(InventoryKindVM, vm.CID, reason, vm.ModifiedAt)
+			vmCandidates[vm.CID] = res.Action == GCActionDelete
+			results = append(results, res)
+		}
+	}
+
+	for _, disk := range gc.inv.Disks {
+		reason := orphans[disk.CID]
+
+		switch {
+		case gc.opts.KnownCIDs != nil && gc.opts.KnownCIDs[disk.CID]:
+			continue
+		case reason == InventoryOrphanNotStored:
+			// Only a registered medium is left
+		case gc.opts.KnownCIDs == nil:
+			continue // detached persistent disks are expected
+		case len(disk.Owner) == 0:
+			reason = gcReasonUnknown
+		case vmCandidates[disk.Owner]:
+			reason = gcReasonUnusedBy
+		default:
+			continue // e.g. ephemeral disk of a VM that is kept
+		}
+
+		results = append(results, gc.checkAge(InventoryKindDisk, disk.CID, reason, disk.ModifiedAt))
+	}
+
+	for _, stemcell := range gc.inv.Stemcells {
+		if reason, found := gc.reason(stemcell.CID, orphans); found {
+			res := gc.checkAge(InventoryKindStemcell, stemcell.CID, reason, stemcell.ModifiedAt)
+
+			var remainingClones []string
+
+			for _, cid := range stemcell.Clones {
+				if !vmCandidates[cid] {
+					remainingClones = append(remainingClones, cid)
+				}
+			}
+
+			if res.Action == GCActionDelete && len(remainingClones) > 0 {
+				res.Action = GCActionSkip
+				res.Note = "has linked clones: " + strings.Join(remainingClones, ", ")
+			}
+
+			results = append(results, res)
+		}
+	}
+
+	return results
+}
+
+// reason keeps resources referenced by Director even if they are inconsistent
+func (gc garbageCollector) reason(cid string, orphans map[string]string) (string, bool) {
+	if gc.opts.KnownCIDs != nil {
+		if gc.opts.KnownCIDs[cid] {
+			return "", false
+		}
+		if reason, found := orphans[cid]; found {
+			return reason, true
+		}
+		return gcReasonUnknown, true
+	}
+
+	reason, found := orphans[cid]
+
+	return reason, found
+}
+
+func (gc garbageCollector) checkAge(kind, cid, reason string, modifiedAt time.Time) GCResult {
+	res := GCResult{Kind: kind, CID: cid, Reason: reason, Action: GCActionDelete}
+
+	if gc.opts.MinAge > 0 {
+		if modifiedAt.IsZero() {
+			res.Action = GCActionSkip
+			res.Note = "age is unknown"
+		} else if age := gc.now.Sub(modifiedAt); age < gc.opts.MinAge {
+			res.Action = GCActionSkip
+			res.Note = fmt.Sprintf("modified %s ago", age.Round(time.Second))
+		}
+	}
+
+	return res
+}
+
+func (gc garbageCollector) deleteVM(res GCResult) GCResult {
+	if !gc.opts.Confirm {
+		return res
+	}
+
+	// Persistent disks are detached and kept; ephemeral disk is deleted
+	return gc.result(res, gc.cpi.DeleteVM(apiv1.NewVMCID(res.CID)))
+}
+
+func (gc garbageCollector) deleteDisk(res GCResult, deletedVMs map[string]bool) GCResult {
+	if !gc.opts.Confirm {
+		return res
+	}
+
+	var disk InventoryDisk
+
+	for _, d := range gc.inv.Disks {
+		if d.CID == res.CID {
+			disk = d
+		}
+	}
+
+	if len(disk.Owner) > 0 && !deletedVMs[disk.Owner] {
+		res.Action = GCActionSkip
+		res.Note = fmt.Sprintf("VM '%s' it is attached to was not deleted", disk.Owner)
+		return res
+	}
+
+	err := gc.cpi.DeleteDisk(apiv1.NewDiskCID(res.CID))
+	if err != nil {
+		return gc.result(res, err)
+	}
+
+	// Deleting disk only removes its directory; medium of ephemeral
+	// disk is already gone when its VM was deleted in the meantime
+	if len(disk.MediumPath) > 0 {
+		_, err = gc.driver.Execute("closemedium", "disk", disk.MediumPath)
+		if driver.IsObjectNotFoundErr(err) || driver.IsFileErr(err) {
+			err = nil
+		}
+	}
+
+	return gc.result(res, err)
+}
+
+func (gc garbageCollector) deleteStemcell(res GCResult, deletedVMs map[string]bool) GCResult {
+	if !gc.opts.Confirm {
+		return res
+	}
+
+	for _, stemcell := range gc.inv.Stemcells {
+		if stemcell.CID != res.CID {
+			continue
+		}
+
+		for _, cid := range stemcell.Clones {
+			if !deletedVMs[cid] {
+				res.Action = GCActionSkip
+				res.Note = fmt.Sprintf("linked clone '%s' was not deleted", cid)
+				return res
+			}
+		}
+	}
+
+	return gc.result(res, gc.cpi.DeleteStemcell(apiv1.NewStemcellCID(res.CID)))
+}
+
+func (gc garbageCollector) result(res GCResult, err error) GCResult {
+	if err != nil {
+		res.Action = GCActionFailed
+		res.Note = err.Error()
+	} else {
+		res.Action = GCActionDeleted
+	}
+	return res
+}
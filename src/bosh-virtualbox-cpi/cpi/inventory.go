@@ -0,0 +1,400 @@
+package cpi
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+const (
+	InventoryKindStemcell = "stemcell"
+	InventoryKindVM       = "vm"
+	InventoryKindDisk     = "disk"
+
+	InventoryOrphanNotStored     = "registered in VirtualBox without store directory"
+	InventoryOrphanNotRegistered = "store directory without VirtualBox registration"
+	InventoryOrphanNotReferenced = "not attached to any VM"
+)
+
+// Inventory describes resources found in StoreDir and in VirtualBox registry
+type Inventory struct {
+	Stemcells []InventoryStemcell `json:"stemcells"`
+	VMs       []InventoryVM       `json:"vms"`
+	Disks     []InventoryDisk     `json:"disks"`
+	Orphans   []InventoryOrphan   `json:"orphans"`
+}
+
+type InventoryStemcell struct {
+	CID        string    `json:"cid"`
+	Registered bool      `json:"registered"`
+	Stored     bool      `json:"stored"`
+	Clones     []string  `json:"clones"` // CIDs of VMs linked to stemcell's snapshot
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type InventoryVM struct {
+	CID        string                 `json:"cid"`
+	Registered bool                   `json:"registered"`
+	Stored     bool                   `json:"stored"`
+	State      string                 `json:"state"` // e.g. running, poweroff, inaccessible
+	Stemcell   string                 `json:"stemcell"`
+	Metadata   map[string]interface{} `json:"metadata"`
+	Disks      []string               `json:"disks"` // includes ephemeral disk
+	ModifiedAt time.Time              `json:"modified_at"`
+}
+
+type InventoryDisk struct {
+	CID        string    `json:"cid"`
+	Registered bool      `json:"registered"` // medium is known to VirtualBox
+	Stored     bool      `json:"stored"`
+	SizeMB     int       `json:"size_mb"`
+	MediumPath string    `json:"medium_path"` // location of registered medium
+	Owner      string    `json:"owner"`       // CID of VM disk is attached to
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type InventoryOrphan struct {
+	Kind   string `json:"kind"`
+	CID    string `json:"cid"`
+	Reason string `json:"reason"`
+}
+
+// Inventory cross-checks StoreDir with VMs and media registered in VirtualBox
+func (f Factory) Inventory() (Inventory, error) {
+	runner := f.newRunner()
+
+	c := inventoryCollector{
+		runner: runner,
+		driver: f.newCmdDriver(runner, "inventory"),
+
+		stemcells: map[string]*InventoryStemcell{},
+		vms:       map[string]*InventoryVM{},
+		disks:     map[string]*InventoryDisk{},
+		media:     map[string]driver.RegisteredMedium{},
+	}
+
+	// Media locations reported by VirtualBox are absolute
+	storeDir, err := runner.ExpandPath(f.opts.StoreDir)
+	if err != nil {
+		return Inventory{}, bosherr.WrapError(err, "Expanding store directory")
+	}
+
+	expandedOpts := f.opts
+	expandedOpts.StoreDir = storeDir
+
+	c.stemcellsDir = expandedOpts.StemcellsDir()
+	c.vmsDir = expandedOpts.VMsDir()
+	c.disksDir = expandedOpts.DisksDir()
+
+	return c.Collect()
+}
+
+type inventoryCollector struct {
+	runner driver.Runner
+	driver driver.Driver
+
+	stemcellsDir string
+	vmsDir       string
+	disksDir     string
+
+	stemcells map[string]*InventoryStemcell
+	vms       map[string]*InventoryVM
+	disks     map[string]*InventoryDisk
+	media     map[string]driver.RegisteredMedium // by UUID
+
+	// Ancestor media of stemcells and VMs, used to find linked clones
+	stemcellMedia map[string]string // medium UUID -> stemcell CID
+	vmMedia       map[string][]string
+}
+
+func (c *inventoryCollector) Collect() (Inventory, error) {
+	err := c.collectStored()
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	err = c.collectMedia()
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	err = c.collectRegisteredVMs()
+	if err != nil {
+		return Inventory{}, err
+	}
+
+	c.linkClones()
+
+	return c.inventory(), nil
+}
+
+func (c *inventoryCollector) collectStored() error {
+	stored := []struct {
+		dir    string
+		prefix string
+		add    func(cid string, modifiedAt time.Time)
+	}{
+		{c.stemcellsDir, "sc-", func(cid string, t time.Time) {
+			c.stemcell(cid).Stored, c.stemcell(cid).ModifiedAt = true, t
+		}},
+		{c.vmsDir, "vm-", func(cid string, t time.Time) {
+			c.vm(cid).Stored, c.vm(cid).ModifiedAt = true, t
+		}},
+		{c.disksDir, "disk-", func(cid string, t time.Time) {
+			c.disk(cid).Stored, c.disk(cid).ModifiedAt = true, t
+		}},
+	}
+
+	for _, s := range stored {
+		names, err := c.runner.List(s.dir)
+		if err != nil {
+			if driver.IsNotExistErr(err) {
+				continue
+			}
+			return bosherr.WrapErrorf(err, "Listing '%s'", s.dir)
+		}
+
+		for _, name := range names {
+			if !strings.HasPrefix(name, s.prefix) {
+				continue
+			}
+
+			info, err := c.runner.Stat(filepath.Join(s.dir, name))
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Checking '%s'", name)
+			}
+
+			s.add(name, info.ModTime())
+		}
+	}
+
+	for cid, vm := range c.vms {
+		bytes, err := c.runner.Get(filepath.Join(c.vmsDir, cid, "metadata.json"))
+		if err != nil {
+			continue // metadata is only set after VM is created
+		}
+
+		err = json.Unmarshal(bytes, &vm.Metadata)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Unmarshaling metadata of VM '%s'", cid)
+		}
+	}
+
+	return nil
+}
+
+func (c *inventoryCollector) collectMedia() error {
+	output, err := c.driver.Execute("list", "hdds")
+	if err != nil {
+		return bosherr.WrapError(err, "Listing media")
+	}
+
+	for _, medium := range driver.NewRegisteredMedia(output) {
+		c.media[medium.UUID] = medium
+
+		if cid, found := c.diskCID(medium.Location); found {
+			disk := c.disk(cid)
+			disk.Registered = true
+			disk.SizeMB = medium.CapacityMB
+			disk.MediumPath = medium.Location
+		}
+	}
+
+	return nil
+}
+
+func (c *inventoryCollector) collectRegisteredVMs() error {
+	output, err := c.driver.Execute("list", "vms")
+	if err != nil {
+		return bosherr.WrapError(err, "Listing VMs")
+	}
+
+	c.stemcellMedia = map[string]string{}
+	c.vmMedia = map[string][]string{}
+
+	for _, regVM := range driver.NewRegisteredVMs(output) {
+		info, err := c.driver.MachineInfo(regVM.UUID)
+		if err != nil {
+			if driver.IsObjectNotFoundErr(err) {
+				continue // deleted in the meantime
+			}
+			return bosherr.WrapErrorf(err, "Inspecting VM '%s'", regVM.Name)
+		}
+
+		name := regVM.Name
+
+		// Inaccessible VMs are only identifiable by their directory
+		if regVM.IsInaccessible() {
+			cfgFile, _ := info.Value("CfgFile")
+			name = filepath.Base(filepath.Dir(cfgFile))
+		}
+
+		switch {
+		case strings.HasPrefix(name, "sc-"):
+			stemcell := c.stemcell(name)
+			stemcell.Registered = true
+
+			for _, uuid := range c.ancestorMedia(info.ImageUUIDs()) {
+				c.stemcellMedia[uuid] = name
+			}
+
+		case strings.HasPrefix(name, "vm-"):
+			vm := c.vm(name)
+			vm.Registered = true
+			vm.State = info.State()
+
+			if !vm.Stored {
+				vm.ModifiedAt, _ = info.StateChangeTime()
+			}
+
+			c.vmMedia[name] = c.ancestorMedia(info.ImageUUIDs())
+
+			for _, uuid := range c.vmMedia[name] {
+				if cid, found := c.diskCID(c.media[uuid].Location); found {
+					vm.Disks = appendUnique(vm.Disks, cid)
+					c.disk(cid).Owner = name
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *inventoryCollector) linkClones() {
+	for cid, uuids := range c.vmMedia {
+		for _, uuid := range uuids {
+			if stemcellCID, found := c.stemcellMedia[uuid]; found {
+				c.vm(cid).Stemcell = stemcellCID
+				stemcell := c.stemcell(stemcellCID)
+				stemcell.Clones = appendUnique(stemcell.Clones, cid)
+			}
+		}
+	}
+}
+
+// ancestorMedia returns given media and their parents
+func (c *inventoryCollector) ancestorMedia(uuids []string) []string {
+	var result []string
+
+	for _, uuid := range uuids {
+		for len(uuid) > 0 {
+			result = appendUnique(result, uuid)
+			uuid = c.media[uuid].ParentUUID
+		}
+	}
+
+	return result
+}
+
+func (c *inventoryCollector) diskCID(location string) (string, bool) {
+	dir := filepath.Dir(location)
+	if filepath.Dir(dir) != c.disksDir || !strings.HasPrefix(filepath.Base(dir), "disk-") {
+		return "", false
+	}
+	return filepath.Base(dir), true
+}
+
+func (c *inventoryCollector) inventory() Inventory {
+	inv := Inventory{
+		Stemcells: []InventoryStemcell{},
+		VMs:       []InventoryVM{},
+		Disks:     []InventoryDisk{},
+		Orphans:   []InventoryOrphan{},
+	}
+
+	var cids []string
+
+	orphan := func(kind, cid string, registered, stored bool) {
+		if registered && !stored {
+			inv.Orphans = append(inv.Orphans, InventoryOrphan{kind, cid, InventoryOrphanNotStored})
+		} else if stored && !registered {
+			inv.Orphans = append(inv.Orphans, InventoryOrphan{kind, cid, InventoryOrphanNotRegistered})
+		}
+	}
+
+	for _, stemcell := range c.stemcells {
+		cids = append(cids, stemcell.CID)
+	}
+
+	for _, cid := range sortedStrings(cids) {
+		stemcell := c.stemcells[cid]
+		sort.Strings(stemcell.Clones)
+		inv.Stemcells = append(inv.Stemcells, *stemcell)
+		orphan(InventoryKindStemcell, cid, stemcell.Registered, stemcell.Stored)
+	}
+
+	cids = nil
+
+	for _, vm := range c.vms {
+		cids = append(cids, vm.CID)
+	}
+
+	for _, cid := range sortedStrings(cids) {
+		vm := c.vms[cid]
+		sort.Strings(vm.Disks)
+		inv.VMs = append(inv.VMs, *vm)
+		orphan(InventoryKindVM, cid, vm.Registered, vm.Stored)
+	}
+
+	cids = nil
+
+	for _, disk := range c.disks {
+		cids = append(cids, disk.CID)
+	}
+
+	for _, cid := range sortedStrings(cids) {
+		disk := c.disks[cid]
+		inv.Disks = append(inv.Disks, *disk)
+
+		// Disks do not have to be registered with VirtualBox while detached
+		if !disk.Stored {
+			orphan(InventoryKindDisk, cid, disk.Registered, disk.Stored)
+		} else if len(disk.Owner) == 0 {
+			inv.Orphans = append(inv.Orphans, InventoryOrphan{InventoryKindDisk, cid, InventoryOrphanNotReferenced})
+		}
+	}
+
+	return inv
+}
+
+func (c *inventoryCollector) stemcell(cid string) *InventoryStemcell {
+	if c.stemcells[cid] == nil {
+		c.stemcells[cid] = &InventoryStemcell{CID: cid, Clones: []string{}}
+	}
+	return c.stemcells[cid]
+}
+
+func (c *inventoryCollector) vm(cid string) *InventoryVM {
+	if c.vms[cid] == nil {
+		c.vms[cid] = &InventoryVM{CID: cid, Disks: []string{}}
+	}
+	return c.vms[cid]
+}
+
+func (c *inventoryCollector) disk(cid string) *InventoryDisk {
+	if c.disks[cid] == nil {
+		c.disks[cid] = &InventoryDisk{CID: cid}
+	}
+	return c.disks[cid]
+}
+
+func sortedStrings(values []string) []string {
+	sort.Strings(values)
+	return values
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,125 @@
+package cpi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+// preflightTTL is how long successful preflight is remembered on the host;
+// checks are repeated afterwards (or as soon as VirtualBox version changes)
+const preflightTTL = 24 * time.Hour
+
+// preflightRecord is kept in StoreDir after successful preflight
+type preflightRecord struct {
+	BinPath           string    `json:"bin_path"`
+	StorageController string    `json:"storage_controller"`
+	Version           string    `json:"version"`
+	CheckedAt         time.Time `json:"checked_at"`
+}
+
+// Preflight checks that VBoxManage is reachable, VirtualBox version and configured
+// storage controller are supported and StoreDir is writable. Success is remembered
+// on the host (and by warm state) so that subsequent CPI calls skip the checks.
+// Like Doctor, checks are not retried. Does nothing unless enabled via FactoryOpts.
+// Connection and capabilities are shared with CPI when factory is ForRequest.
+func (f Factory) Preflight() error {
+	if !f.opts.Preflight || (f.warm != nil && f.warm.preflighted()) {
+		return nil
+	}
+
+	runner, cache := f.callRunnerAndCache()
+
+	d := f.newDoctor(DoctorOpts{}, runner, cache)
+
+	record := preflightRecord{BinPath: f.opts.BinPath, StorageController: f.opts.StorageController}
+
+	if f.preflightRemembered(runner, d.driver, record) {
+		f.markPreflighted()
+		return nil
+	}
+
+	var failures []string
+
+	for _, check := range d.Preflight() {
+		if check.Failed() {
+			failures = append(failures, fmt.Sprintf("%s: %s (%s)", check.Name, check.Detail, check.Hint))
+		}
+	}
+
+	if len(failures) > 0 {
+		return bosherr.Errorf("VirtualBox host failed preflight checks:\n%s", strings.Join(failures, "\n"))
+	}
+
+	record.Version = d.caps.Version.String()
+	record.CheckedAt = time.Now().UTC()
+
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshaling preflight record")
+	}
+
+	err = runner.Put(f.opts.PreflightPath(), bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Remembering preflight")
+	}
+
+	f.markPreflighted()
+
+	return nil
+}
+
+func (f Factory) preflightRemembered(runner driver.Runner, drv driver.Driver, expected preflightRecord) bool {
+	bytes, err := runner.Get(f.opts.PreflightPath())
+	if err != nil {
+		return false
+	}
+
+	var record preflightRecord
+
+	err = json.Unmarshal(bytes, &record)
+	if err != nil {
+		f.logger.Debug("cpi.Factory", "Ignoring invalid preflight record: %s", err)
+		return false
+	}
+
+	if record.BinPath != expected.BinPath ||
+		record.StorageController != expected.StorageController ||
+		time.Since(record.CheckedAt) >= preflightTTL {
+		return false
+	}
+
+	// Capabilities are needed by most CPI calls anyway
+	caps, err := drv.Capabilities()
+	if err != nil {
+		f.logger.Debug("cpi.Factory", "Repeating preflight since capabilities are unknown: %s", err)
+		return false
+	}
+
+	return caps.Version.String() == record.Version
+}
+
+func (f Factory) markPreflighted() {
+	if f.warm != nil {
+		f.warm.markPreflighted()
+	}
+}
+
+func (s *warmState) preflighted() bool {
+	s.preflightMu.Lock()
+	defer s.preflightMu.Unlock()
+
+	return time.Since(s.preflightedAt) < preflightTTL
+}
+
+func (s *warmState) markPreflighted() {
+	s.preflightMu.Lock()
+	defer s.preflightMu.Unlock()
+
+	s.preflightedAt = time.Now()
+}
@@ -2,18 +2,77 @@ package cpi
 
 import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+	bsnap "bosh-virtualbox-cpi/snapshot"
 )
 
-type Snapshots struct{}
+type Snapshots struct {
+	creator    bsnap.Creator
+	finder     bsnap.Finder
+	diskFinder bdisk.Finder
+}
 
-func NewSnapshots() Snapshots {
-	return Snapshots{}
+func NewSnapshots(creator bsnap.Creator, finder bsnap.Finder, diskFinder bdisk.Finder) Snapshots {
+	return Snapshots{creator, finder, diskFinder}
 }
 
 func (s Snapshots) SnapshotDisk(cid apiv1.DiskCID, meta apiv1.DiskMeta) (apiv1.SnapshotCID, error) {
-	return apiv1.SnapshotCID{}, nil
+	disk, err := s.diskFinder.Find(cid)
+	if err != nil {
+		return apiv1.SnapshotCID{}, bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	snapshot, err := s.creator.Create(disk, meta)
+	if err != nil {
+		return apiv1.SnapshotCID{}, bosherr.WrapErrorf(err, "Snapshotting disk '%s'", cid)
+	}
+
+	return snapshot.ID(), nil
 }
 
 func (s Snapshots) DeleteSnapshot(cid apiv1.SnapshotCID) error {
+	snapshot, err := s.finder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding snapshot '%s'", cid)
+	}
+
+	err = snapshot.Delete()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting snapshot '%s'", cid)
+	}
+
 	return nil
 }
+
+// RestoreDisk is not part of the CPI API; it replaces contents
+// of a detached disk with previously taken snapshot.
+func (s Snapshots) RestoreDisk(cid apiv1.SnapshotCID, diskCID apiv1.DiskCID) error {
+	snapshot, err := s.finder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding snapshot '%s'", cid)
+	}
+
+	disk, err := s.diskFinder.Find(diskCID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	err = snapshot.Restore(disk)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Restoring disk '%s' from snapshot '%s'", diskCID, cid)
+	}
+
+	return nil
+}
+
+// RestoreDisk makes Snapshots.RestoreDisk available to commands
+func (f Factory) RestoreDisk(cid apiv1.SnapshotCID, diskCID apiv1.DiskCID) error {
+	cpi, err := f.WithMethod("restore_disk").New(cmdCallContext{})
+	if err != nil {
+		return err
+	}
+
+	return cpi.(CPI).RestoreDisk(cid, diskCID)
+}
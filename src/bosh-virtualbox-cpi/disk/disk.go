@@ -2,6 +2,8 @@ package disk
 
 import (
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -10,10 +12,17 @@ import (
 	"bosh-virtualbox-cpi/driver"
 )
 
+var (
+	// Covers `In use by VMs:   vm-1 (UUID: 1a2b...)` and following indented lines
+	diskInUseByVM = regexp.MustCompile(`(?m)^(?:In use by VMs:)?[ \t]+(\S+) \(UUID: ([0-9a-fA-F-]+)\)`)
+)
+
 type DiskImpl struct {
-	cid  apiv1.DiskCID
-	path string
+	cid   apiv1.DiskCID
+	path  string
+	props Props
 
+	driver driver.Driver
 	runner driver.Runner
 	logger boshlog.Logger
 }
@@ -21,33 +30,90 @@ type DiskImpl struct {
 func NewDiskImpl(
 	cid apiv1.DiskCID,
 	path string,
+	props Props,
+	driver driver.Driver,
 	runner driver.Runner,
 	logger boshlog.Logger,
 ) DiskImpl {
-	return DiskImpl{cid, path, runner, logger}
+	return DiskImpl{cid, path, props, driver, runner, logger}
 }
 
 func (d DiskImpl) ID() apiv1.DiskCID { return d.cid }
 
 func (d DiskImpl) Path() string { return d.path }
 
-func (d DiskImpl) VMDKPath() string {
-	return filepath.Join(d.path, "disk.vmdk")
+func (d DiskImpl) Props() Props { return d.props }
+
+// ImagePath returns path to the medium; extension is picked based on disk format
+// which keeps disks created before formats were configurable at 'disk.vmdk'.
+func (d DiskImpl) ImagePath() string {
+	return filepath.Join(d.path, "disk."+strings.ToLower(d.props.Format))
+}
+
+// AttachedVMIDs returns UUIDs of VMs that currently have medium attached.
+func (d DiskImpl) AttachedVMIDs() ([]string, error) {
+	output, err := d.driver.Execute("showmediuminfo", "disk", d.ImagePath())
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Determining VMs using disk '%s'", d.path)
+	}
+
+	var ids []string
+
+	for _, matches := range diskInUseByVM.FindAllStringSubmatch(output, -1) {
+		ids = append(ids, matches[2])
+	}
+
+	return ids, nil
 }
 
 func (d DiskImpl) Exists() (bool, error) {
-	_, _, err := d.runner.Execute("ls", d.path)
+	_, err := d.runner.Stat(d.ImagePath())
 	if err != nil {
+		if driver.IsNotExistErr(err) {
+			return false, nil
+		}
 		return false, bosherr.WrapErrorf(err, "Checking disk '%s'", d.path)
 	}
 
-	// todo check status
-
 	return true, nil
 }
 
+// ReplaceImage puts medium at given path in place of disk's medium
+// so that disk's path (and CID) stays the same. Previous medium is
+// only deleted once its replacement is in place.
+func (d DiskImpl) ReplaceImage(path string) error {
+	prevPath := filepath.Join(d.path, "previous"+filepath.Ext(d.ImagePath()))
+
+	_, err := d.driver.Execute("modifymedium", "disk", d.ImagePath(), "--move", prevPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Moving previous contents of disk '%s' aside", d.path)
+	}
+
+	_, err = d.driver.Execute("modifymedium", "disk", path, "--move", d.ImagePath())
+	if err != nil {
+		_, moveErr := d.driver.Execute("modifymedium", "disk", prevPath, "--move", d.ImagePath())
+		if moveErr != nil {
+			return bosherr.WrapErrorf(err, "Replacing contents of disk '%s' (previous contents are in '%s')", d.path, prevPath)
+		}
+		return bosherr.WrapErrorf(err, "Replacing contents of disk '%s'", d.path)
+	}
+
+	d.closeMedium(prevPath)
+
+	return nil
+}
+
+// closeMedium deletes medium that is not needed anymore;
+// failures only leave unused files in disk's directory
+func (d DiskImpl) closeMedium(path string) {
+	_, err := d.driver.Execute("closemedium", "disk", path, "--delete")
+	if err != nil {
+		d.logger.Error("disk.DiskImpl", "Failed to delete medium '%s': %s", path, err)
+	}
+}
+
 func (d DiskImpl) Delete() error {
-	_, _, err := d.runner.Execute("rm", "-rf", d.path)
+	err := d.runner.RemoveAll(d.path)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Deleting disk '%s'", d.path)
 	}
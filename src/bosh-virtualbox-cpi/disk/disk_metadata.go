@@ -0,0 +1,75 @@
+package disk
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	metadataFileName = "metadata.json"
+)
+
+// SetMetadata saves metadata next to the disk and makes it visible
+// in VirtualBox Media Manager via medium description.
+func (d DiskImpl) SetMetadata(meta apiv1.DiskMeta) error {
+	bytes, err := json.Marshal(meta)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshaling disk metadata")
+	}
+
+	err = d.runner.Put(filepath.Join(d.path, metadataFileName), bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving disk metadata")
+	}
+
+	var kvs map[string]interface{}
+
+	err = json.Unmarshal(bytes, &kvs)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshaling disk metadata")
+	}
+
+	_, err = d.driver.Execute("modifymedium", "disk", d.ImagePath(), "--description", d.description(kvs))
+	if err != nil {
+		// Media locked by a running VM may refuse modifications;
+		// metadata.json still identifies the owner
+		d.logger.Error("disk.DiskImpl", "Failed to set description of disk '%s': %s", d.path, err)
+	}
+
+	return nil
+}
+
+func (d DiskImpl) Metadata() (apiv1.DiskMeta, error) {
+	var meta apiv1.DiskMeta
+
+	bytes, err := d.runner.Get(filepath.Join(d.path, metadataFileName))
+	if err != nil {
+		return meta, bosherr.WrapError(err, "Getting disk metadata")
+	}
+
+	err = json.Unmarshal(bytes, &meta)
+	if err != nil {
+		return meta, bosherr.WrapError(err, "Unmarshaling disk metadata")
+	}
+
+	return meta, nil
+}
+
+// description produces sorted 'key=value' pairs, e.g. 'deployment=cf, index=0'
+func (DiskImpl) description(kvs map[string]interface{}) string {
+	var pairs []string
+
+	for k, v := range kvs {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,62 @@
+package disk_test
+
+import (
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver"
+	"bosh-virtualbox-cpi/driver/fakes"
+)
+
+var _ = Describe("DiskImpl metadata", func() {
+	var (
+		vb   *fakes.VirtualBox
+		drv  driver.Driver
+		disk Disk
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+		drv = vb.Driver(logger)
+
+		var err error
+
+		disk, err = NewFactory("/store/disks", boshuuid.NewGenerator(), drv, vb.Runner(), logger).
+			Create(1024, DefaultProps())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	meta := apiv1.NewDiskMeta(map[string]interface{}{
+		"deployment":  "cf",
+		"instance_id": "abc",
+	})
+
+	It("saves metadata next to the disk and shows it as medium description", func() {
+		err := disk.SetMetadata(meta)
+		Expect(err).ToNot(HaveOccurred())
+
+		saved, err := disk.Metadata()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(saved).To(Equal(meta))
+
+		output, err := drv.Execute("showmediuminfo", "disk", disk.ImagePath())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(ContainSubstring("Description:    deployment=cf, instance_id=abc\n"))
+	})
+
+	It("keeps metadata when medium description cannot be set", func() {
+		vb.FailOn("modifymedium", "--description")
+
+		err := disk.SetMetadata(meta)
+		Expect(err).ToNot(HaveOccurred())
+
+		saved, err := disk.Metadata()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(saved).To(Equal(meta))
+	})
+})
@@ -0,0 +1,120 @@
+package disk
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+var (
+	// Covers `Capacity:       5000 MBytes`
+	diskCapacityMatch = regexp.MustCompile(`(?m)^Capacity:\s+(\d+) MBytes`)
+)
+
+// Capacity returns logical size of the disk in megabytes.
+func (d DiskImpl) Capacity() (int, error) {
+	output, err := d.driver.Execute("showmediuminfo", "disk", d.ImagePath())
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Determining capacity of disk '%s'", d.path)
+	}
+
+	matches := diskCapacityMatch.FindStringSubmatch(output)
+	if len(matches) != 2 {
+		return 0, bosherr.Errorf("Unknown disk capacity:\nOutput: '%s'", output)
+	}
+
+	return strconv.Atoi(matches[1])
+}
+
+func (d DiskImpl) Resize(size int) error {
+	currSize, err := d.Capacity()
+	if err != nil {
+		return err
+	}
+
+	if size < currSize {
+		return bosherr.Errorf("Shrinking disk '%s' from '%d' MB to '%d' MB is not supported",
+			d.cid.AsString(), currSize, size)
+	}
+
+	if size == currSize {
+		return nil
+	}
+
+	vmIDs, err := d.AttachedVMIDs()
+	if err != nil {
+		return err
+	}
+
+	if len(vmIDs) > 0 {
+		return bosherr.Errorf("Expected disk '%s' to be detached before resizing (attached to '%v')",
+			d.cid.AsString(), vmIDs)
+	}
+
+	_, err = d.driver.Execute("modifymedium", "disk", d.ImagePath(), "--resize", strconv.Itoa(size))
+	if err != nil {
+		// Some formats (or their variants) cannot be resized in place
+		if driver.IsNotSupportedErr(err) {
+			return d.resizeByConverting(size)
+		}
+		return bosherr.WrapErrorf(err, "Resizing disk '%s'", d.path)
+	}
+
+	return nil
+}
+
+// resizeByConverting goes through VDI format which can always be resized
+// and then converts disk back so that its path (and CID) stays the same.
+// Original medium is kept until converted medium replaces it.
+func (d DiskImpl) resizeByConverting(size int) error {
+	tmpPath := filepath.Join(d.path, "resize.vdi")
+	resizedPath := filepath.Join(d.path, "resized"+filepath.Ext(d.ImagePath()))
+
+	// Failed clone may leave partially written (unregistered) file behind
+	defer d.removeMedium(tmpPath)
+
+	_, err := d.driver.Execute("clonemedium", "disk", d.ImagePath(), tmpPath, "--format", "VDI")
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Converting disk '%s' to VDI", d.path)
+	}
+
+	_, err = d.driver.Execute("modifymedium", "disk", tmpPath, "--resize", strconv.Itoa(size))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Resizing converted disk '%s'", tmpPath)
+	}
+
+	_, err = d.driver.Execute(
+		"clonemedium", "disk", tmpPath, resizedPath,
+		"--format", d.props.Format,
+		"--variant", d.props.Variant,
+	)
+	if err != nil {
+		d.removeMedium(resizedPath)
+		return bosherr.WrapErrorf(err, "Converting resized disk back to %s", d.props.Format)
+	}
+
+	err = d.ReplaceImage(resizedPath)
+	if err != nil {
+		d.removeMedium(resizedPath)
+		return err
+	}
+
+	return nil
+}
+
+// removeMedium deletes temporary medium whether or not it got registered
+func (d DiskImpl) removeMedium(path string) {
+	_, err := d.driver.Execute("closemedium", "disk", path, "--delete")
+	if err != nil {
+		d.logger.Debug("disk.DiskImpl", "Ignoring failure to close medium '%s': %s", path, err)
+	}
+
+	err = d.runner.RemoveAll(path)
+	if err != nil {
+		d.logger.Error("disk.DiskImpl", "Failed to remove medium '%s': %s", path, err)
+	}
+}
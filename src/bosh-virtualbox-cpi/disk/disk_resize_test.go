@@ -0,0 +1,131 @@
+package disk_test
+
+import (
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver/fakes"
+)
+
+var _ = Describe("DiskImpl", func() {
+	var (
+		vb    *fakes.VirtualBox
+		disks Factory
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{Version: "6.1.38"})
+		disks = NewFactory("/store/disks", boshuuid.NewGenerator(), vb.Driver(logger), vb.Runner(), logger)
+	})
+
+	create := func(format string) DiskImpl {
+		props := DefaultProps()
+		props.Format = format
+
+		disk, err := disks.Create(1024, props)
+		Expect(err).ToNot(HaveOccurred())
+
+		return disk.(DiskImpl)
+	}
+
+	capacity := func(disk DiskImpl) int {
+		size, err := disk.Capacity()
+		Expect(err).ToNot(HaveOccurred())
+		return size
+	}
+
+	subcommands := func() []string {
+		var cmds []string
+		for _, args := range vb.Invocations() {
+			cmds = append(cmds, args[0])
+		}
+		return cmds
+	}
+
+	Describe("Resize", func() {
+		It("grows medium in place when its format supports it", func() {
+			disk := create(VDIFormat)
+
+			err := disk.Resize(2048)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(2048))
+			Expect(subcommands()).ToNot(ContainElement("clonemedium"))
+		})
+
+		It("converts medium through VDI when its format cannot be resized in place", func() {
+			disk := create(VMDKFormat)
+
+			err := disk.Resize(2048)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(2048))
+			Expect(subcommands()).To(ContainElement("clonemedium"))
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+		})
+
+		It("does not change size when it is the same", func() {
+			disk := create(VMDKFormat)
+
+			err := disk.Resize(1024)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(subcommands()).ToNot(ContainElement("modifymedium"))
+		})
+
+		It("rejects shrinking", func() {
+			disk := create(VDIFormat)
+
+			err := disk.Resize(512)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Shrinking disk"))
+
+			Expect(capacity(disk)).To(Equal(1024))
+		})
+
+		It("keeps original medium when converting back fails", func() {
+			disk := create(VMDKFormat)
+
+			vb.FailOn("clonemedium", filepath.Join(disk.Path(), "resized.vmdk"))
+
+			err := disk.Resize(2048)
+			Expect(err).To(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(1024))
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+			Expect(vb.FileExists(filepath.Join(disk.Path(), "resize.vdi"))).To(BeFalse())
+			Expect(vb.FileExists(filepath.Join(disk.Path(), "resized.vmdk"))).To(BeFalse())
+		})
+
+		It("removes partially converted medium when converting to VDI fails", func() {
+			disk := create(VMDKFormat)
+
+			vb.FailOn("clonemedium", filepath.Join(disk.Path(), "resize.vdi"))
+
+			err := disk.Resize(2048)
+			Expect(err).To(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(1024))
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+			Expect(vb.FileExists(filepath.Join(disk.Path(), "resize.vdi"))).To(BeFalse())
+		})
+
+		It("keeps original medium when converted medium cannot replace it", func() {
+			disk := create(VMDKFormat)
+
+			vb.FailOn("modifymedium", filepath.Join(disk.Path(), "resized.vmdk"))
+
+			err := disk.Resize(2048)
+			Expect(err).To(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(1024))
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package disk
 
 import (
+	"encoding/json"
 	"path/filepath"
 	"strconv"
 
@@ -19,9 +20,14 @@ type Factory struct {
 	driver driver.Driver
 	runner driver.Runner
 
+	logTag string
 	logger boshlog.Logger
 }
 
+const (
+	propsFileName = "props.json"
+)
+
 func NewFactory(
 	dirPath string,
 	uuidGen boshuuid.Generator,
@@ -36,11 +42,12 @@ func NewFactory(
 		driver: driver,
 		runner: runner,
 
+		logTag: "disk.Factory",
 		logger: logger,
 	}
 }
 
-func (f Factory) Create(size int) (Disk, error) {
+func (f Factory) Create(size int, props Props) (Disk, error) {
 	id, err := f.uuidGen.Generate()
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Generating disk id")
@@ -48,32 +55,85 @@ func (f Factory) Create(size int) (Disk, error) {
 
 	id = "disk-" + id
 
-	disk := f.newDisk(apiv1.NewDiskCID(id))
+	disk := f.newDisk(apiv1.NewDiskCID(id), props)
 
-	_, _, err = f.runner.Execute("mkdir", "-p", disk.Path())
+	err = f.runner.MkdirAll(disk.Path())
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Creating disk parent")
 	}
 
 	_, err = f.driver.Execute(
 		"createhd",
-		"--filename", disk.VMDKPath(),
+		"--filename", disk.ImagePath(),
 		"--size", strconv.Itoa(size),
-		"--format", "VMDK",
-		"--variant", "Standard",
+		"--format", props.Format,
+		"--variant", props.Variant,
 	)
 	if err != nil {
+		f.cleanUpPartialCreate(disk)
 		return nil, bosherr.WrapError(err, "Creating disk")
 	}
 
+	bytes, err := json.Marshal(props)
+	if err != nil {
+		f.cleanUpPartialCreate(disk)
+		return nil, bosherr.WrapError(err, "Serializing disk props")
+	}
+
+	err = f.runner.Put(filepath.Join(disk.Path(), propsFileName), bytes)
+	if err != nil {
+		f.cleanUpPartialCreate(disk)
+		return nil, bosherr.WrapError(err, "Saving disk props")
+	}
+
 	return disk, nil
 }
 
 func (f Factory) Find(cid apiv1.DiskCID) (Disk, error) {
-	return f.newDisk(cid), nil
+	props, err := f.findProps(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.newDisk(cid, props), nil
+}
+
+func (f Factory) findProps(cid apiv1.DiskCID) (Props, error) {
+	bytes, err := f.runner.Get(filepath.Join(f.dirPath, cid.AsString(), propsFileName))
+	if err != nil {
+		// Disks created before disk cloud properties were supported
+		// (or disks that do not exist) do not have saved props
+		if driver.IsNotExistErr(err) {
+			f.logger.Debug(f.logTag, "Using default props for disk '%s': %s", cid.AsString(), err)
+			return DefaultProps(), nil
+		}
+		return Props{}, bosherr.WrapErrorf(err, "Reading props of disk '%s'", cid.AsString())
+	}
+
+	props := DefaultProps()
+
+	err = json.Unmarshal(bytes, &props)
+	if err != nil {
+		return Props{}, bosherr.WrapErrorf(err, "Deserializing disk props")
+	}
+
+	return props, nil
+}
+
+func (f Factory) cleanUpPartialCreate(disk DiskImpl) {
+	output, err := f.driver.Execute("closemedium", "disk", disk.ImagePath(), "--delete")
+	if err != nil {
+		// Medium might have never been created or registered
+		f.logger.Debug(f.logTag, "Ignoring failure to close disk medium: %s", output)
+	}
+
+	err = disk.Delete()
+	if err != nil {
+		f.logger.Error(f.logTag, "Failed to clean up partially created disk: %s", err)
+	}
 }
 
-func (f Factory) newDisk(cid apiv1.DiskCID) DiskImpl {
+func (f Factory) newDisk(cid apiv1.DiskCID, props Props) DiskImpl {
 	diskPath := filepath.Join(f.dirPath, cid.AsString())
-	return NewDiskImpl(cid, diskPath, f.runner, f.logger)
+	return NewDiskImpl(cid, diskPath, props, f.driver, f.runner, f.logger)
 }
@@ -0,0 +1,110 @@
+package disk_test
+
+import (
+	"errors"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver/fakes"
+)
+
+// unreadableRunner fails to read any file, e.g. due to dropped SSH connection
+type unreadableRunner struct {
+	fakes.Runner
+}
+
+func (unreadableRunner) Get(string) ([]byte, error) {
+	return nil, errors.New("connection lost")
+}
+
+// unwritableRunner fails to write any file, e.g. due to full disk
+type unwritableRunner struct {
+	fakes.Runner
+}
+
+func (unwritableRunner) Put(string, []byte) error {
+	return errors.New("no space left on device")
+}
+
+var _ = Describe("Factory", func() {
+	var (
+		vb     *fakes.VirtualBox
+		logger boshlog.Logger
+		disks  Factory
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+		disks = NewFactory("/store/disks", boshuuid.NewGenerator(), vb.Driver(logger), vb.Runner(), logger)
+	})
+
+	Describe("Create", func() {
+		diskDirs := func() []string {
+			names, err := vb.Runner().List("/store/disks")
+			Expect(err).ToNot(HaveOccurred())
+			return names
+		}
+
+		It("removes disk directory when medium cannot be created", func() {
+			vb.FailOn("createhd", "")
+
+			_, err := disks.Create(1024, DefaultProps())
+			Expect(err).To(HaveOccurred())
+
+			Expect(diskDirs()).To(BeEmpty())
+			Expect(vb.MediumPaths()).To(BeEmpty())
+		})
+
+		It("removes disk directory and medium when props cannot be saved", func() {
+			disks = NewFactory("/store/disks", boshuuid.NewGenerator(),
+				vb.Driver(logger), unwritableRunner{vb.Runner()}, logger)
+
+			_, err := disks.Create(1024, DefaultProps())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("no space left on device"))
+
+			Expect(diskDirs()).To(BeEmpty())
+			Expect(vb.MediumPaths()).To(BeEmpty())
+		})
+	})
+
+	Describe("Find", func() {
+		It("finds disk with props it was created with", func() {
+			props := Props{Format: VDIFormat, Variant: FixedVariant, MType: "shareable", Controller: "scsi"}
+
+			disk, err := disks.Create(1024, props)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disk.ImagePath()).To(Equal(disk.Path() + "/disk.vdi"))
+
+			found, err := disks.Find(disk.ID())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found.Props()).To(Equal(props))
+			Expect(found.ImagePath()).To(Equal(disk.ImagePath()))
+		})
+
+		It("uses default props for disks without saved props", func() {
+			err := vb.Runner().MkdirAll("/store/disks/disk-old")
+			Expect(err).ToNot(HaveOccurred())
+
+			disk, err := disks.Find(apiv1.NewDiskCID("disk-old"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(disk.Props()).To(Equal(DefaultProps()))
+			Expect(disk.ImagePath()).To(Equal("/store/disks/disk-old/disk.vmdk"))
+		})
+
+		It("returns error when saved props cannot be read", func() {
+			disks = NewFactory("/store/disks", boshuuid.NewGenerator(),
+				vb.Driver(logger), unreadableRunner{vb.Runner()}, logger)
+
+			_, err := disks.Find(apiv1.NewDiskCID("disk-1"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("connection lost"))
+		})
+	})
+})
@@ -5,7 +5,7 @@ import (
 )
 
 type Creator interface {
-	Create(int) (Disk, error)
+	Create(int, Props) (Disk, error)
 }
 
 var _ Creator = Factory{}
@@ -20,9 +20,17 @@ type Disk interface {
 	ID() apiv1.DiskCID
 
 	Path() string
-	VMDKPath() string
+	ImagePath() string
+	Props() Props
+
+	AttachedVMIDs() ([]string, error)
+
+	SetMetadata(apiv1.DiskMeta) error
+	Metadata() (apiv1.DiskMeta, error)
 
 	Exists() (bool, error)
+	Resize(int) error
+	ReplaceImage(string) error
 	Delete() error
 }
 
@@ -0,0 +1,102 @@
+package disk
+
+import (
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bpds "bosh-virtualbox-cpi/vm/portdevices"
+)
+
+const (
+	VDIFormat  = "VDI"
+	VMDKFormat = "VMDK"
+	VHDFormat  = "VHD"
+
+	StandardVariant = "Standard"
+	FixedVariant    = "Fixed"
+	Split2GVariant  = "Split2G"
+)
+
+type Props struct {
+	Format  string `json:"format"`
+	Variant string `json:"variant"`
+
+	MType         string `json:"mtype"`
+	NonRotational bool   `json:"nonrotational"`
+	Discard       bool   `json:"discard"`
+
+	// Empty controller means that globally configured controller is used
+	Controller string `json:"controller"`
+}
+
+func DefaultProps() Props {
+	return Props{
+		Format:  VMDKFormat,
+		Variant: StandardVariant,
+		MType:   "normal",
+	}
+}
+
+func NewProps(props apiv1.DiskCloudProps) (Props, error) {
+	diskProps := DefaultProps()
+
+	err := props.As(&diskProps)
+	if err != nil {
+		return Props{}, err
+	}
+
+	err = diskProps.Validate()
+	if err != nil {
+		return Props{}, err
+	}
+
+	return diskProps, nil
+}
+
+func (p Props) Validate() error {
+	switch p.Format {
+	case VDIFormat, VMDKFormat, VHDFormat:
+		// valid
+	default:
+		return bosherr.Errorf("Unexpected disk format '%s'", p.Format)
+	}
+
+	switch p.Variant {
+	case StandardVariant, FixedVariant:
+		// valid
+	case Split2GVariant:
+		if p.Format != VMDKFormat {
+			return bosherr.Errorf("Expected disk variant '%s' to be used with '%s' format", p.Variant, VMDKFormat)
+		}
+	default:
+		return bosherr.Errorf("Unexpected disk variant '%s'", p.Variant)
+	}
+
+	switch p.MType {
+	case "normal", "writethrough":
+		// valid; other types except shareable do not persist writes
+	case "shareable":
+		if p.Variant != FixedVariant {
+			return bosherr.Errorf("Expected shareable disk to use '%s' variant", FixedVariant)
+		}
+	default:
+		return bosherr.Errorf("Unexpected disk mtype '%s'", p.MType)
+	}
+
+	switch p.Controller {
+	case "", bpds.IDEController, bpds.SCSIController, bpds.SATAController:
+		// valid
+	default:
+		return bosherr.Errorf("Unexpected disk controller '%s'", p.Controller)
+	}
+
+	return nil
+}
+
+func (p Props) AttachOpts() bpds.AttachOpts {
+	return bpds.AttachOpts{
+		MType:         p.MType,
+		NonRotational: p.NonRotational,
+		Discard:       p.Discard,
+	}
+}
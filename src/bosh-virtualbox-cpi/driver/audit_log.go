@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	auditLogMaxOutput = 1024
+)
+
+// AuditEntry describes single VBoxManage invocation including its retries
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method,omitempty"`     // CPI method, e.g. create_vm
+	RequestID string    `json:"request_id,omitempty"` // as provided by the Director
+
+	Args       []string `json:"args"`
+	Status     int      `json:"status"`
+	DurationMs int64    `json:"duration_ms"`
+	Retries    int      `json:"retries"`
+	Output     string   `json:"output,omitempty"` // last part of combined stdout and stderr
+	Error      string   `json:"error,omitempty"`
+}
+
+// Matches checks whether entry belongs to a VM (by CID in arguments) and/or request
+func (e AuditEntry) Matches(vmCID, requestID string) bool {
+	if len(requestID) > 0 && e.RequestID != requestID {
+		return false
+	}
+
+	if len(vmCID) > 0 {
+		for _, arg := range e.Args {
+			if strings.Contains(arg, vmCID) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+type AuditLog interface {
+	Record(AuditEntry)
+}
+
+type NoopAuditLog struct{}
+
+func (NoopAuditLog) Record(AuditEntry) {}
+
+// AuditLogImpl appends entries as JSON lines to a file on the VirtualBox host
+// so that it is shared by all CPI processes. Failures to record are only logged.
+type AuditLogImpl struct {
+	runner    Runner
+	path      string
+	method    string
+	requestID string
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewAuditLogImpl(runner Runner, path, method, requestID string, logger boshlog.Logger) AuditLogImpl {
+	return AuditLogImpl{
+		runner:    runner,
+		path:      path,
+		method:    method,
+		requestID: requestID,
+
+		logTag: "driver.AuditLogImpl",
+		logger: logger,
+	}
+}
+
+func (l AuditLogImpl) Record(entry AuditEntry) {
+	entry.Method = l.method
+	entry.RequestID = l.requestID
+	entry.Output = trimAuditOutput(entry.Output)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		l.logger.Error(l.logTag, "Failed to serialize audit entry: %s", err)
+		return
+	}
+
+	err = l.runner.Append(l.path, append(line, '\n'))
+	if err != nil {
+		// Directory is only created when first entry cannot be written
+		mkdirErr := l.runner.MkdirAll(filepath.Dir(l.path))
+		if mkdirErr == nil {
+			err = l.runner.Append(l.path, append(line, '\n'))
+		}
+	}
+
+	if err != nil {
+		l.logger.Error(l.logTag, "Failed to record audit entry: %s", err)
+	}
+}
+
+// Entries returns recorded entries skipping lines that cannot be parsed
+func (l AuditLogImpl) Entries() ([]AuditEntry, error) {
+	contents, err := l.runner.Get(l.path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading audit log '%s'", l.path)
+	}
+
+	var entries []AuditEntry
+
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			l.logger.Debug(l.logTag, "Skipping malformed audit entry: %s", err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// trimAuditOutput keeps the end of the output since that is where VBoxManage prints errors
+func trimAuditOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > auditLogMaxOutput {
+		output = "..." + output[len(output)-auditLogMaxOutput:]
+	}
+	return output
+}
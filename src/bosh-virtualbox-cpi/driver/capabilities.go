@@ -0,0 +1,178 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var (
+	// Covers `7.0.10r158379`, `6.1.38_Ubuntur153438` and `7.1.0_BETA2r160000`
+	versionMatch = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(\S*?)(?:r(\d+))?$`)
+
+	// Covers `Operating system: Darwin`
+	hostInfoOSMatch = regexp.MustCompile(`(?m)^Operating system:\s*(.+?)\s*$`)
+)
+
+const (
+	HostOSLinux   = "linux"
+	HostOSDarwin  = "darwin"
+	HostOSWindows = "windows"
+)
+
+// Storage controller types as named in CPI configuration
+const (
+	StorageControllerIDE        = "ide"
+	StorageControllerSCSI       = "scsi"
+	StorageControllerSATA       = "sata"
+	StorageControllerNVMe       = "nvme"
+	StorageControllerVirtioSCSI = "virtio-scsi"
+)
+
+// Version of VirtualBox as printed by `VBoxManage --version`
+type Version struct {
+	Major    int
+	Minor    int
+	Patch    int
+	Suffix   string // e.g. _Ubuntu, _BETA2
+	Revision int    // e.g. 158379
+}
+
+func ParseVersion(output string) (Version, error) {
+	for _, line := range strings.Split(output, "\n") {
+		// Warnings (e.g. about missing kernel modules) might be printed first
+		matches := versionMatch.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) != 6 {
+			continue
+		}
+
+		var ver Version
+
+		ver.Major, _ = strconv.Atoi(matches[1])
+		ver.Minor, _ = strconv.Atoi(matches[2])
+		ver.Patch, _ = strconv.Atoi(matches[3])
+		ver.Suffix = matches[4]
+		ver.Revision, _ = strconv.Atoi(matches[5])
+
+		return ver, nil
+	}
+
+	return Version{}, bosherr.Errorf("Unexpected VirtualBox version '%s'", strings.TrimSpace(output))
+}
+
+// AtLeast compares version ignoring its suffix and revision
+func (v Version) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Capabilities describes features of VirtualBox installation on the host
+// (which is not necessarily the machine CPI is running on)
+type Capabilities struct {
+	Version Version
+	HostOS  string // e.g. linux, darwin, windows
+}
+
+func NewCapabilities(versionOutput, hostInfoOutput string) (Capabilities, error) {
+	ver, err := ParseVersion(versionOutput)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	matches := hostInfoOSMatch.FindStringSubmatch(hostInfoOutput)
+	if len(matches) != 2 {
+		return Capabilities{}, bosherr.Error("Expected host information to include operating system")
+	}
+
+	// Windows hosts report e.g. `Windows 10`
+	hostOS := strings.ToLower(strings.Fields(matches[1])[0])
+
+	return Capabilities{Version: ver, HostOS: hostOS}, nil
+}
+
+// SupportedVersion is true for VirtualBox 5+
+func (c Capabilities) SupportedVersion() bool { return c.Version.AtLeast(5, 0, 0) }
+
+// HostOnlyNets is true when host-only networks are managed via `hostonlynet`
+// since VirtualBox 7 on macOS does not support host-only interfaces (`hostonlyif`)
+func (c Capabilities) HostOnlyNets() bool {
+	return c.HostOS == HostOSDarwin && c.Version.AtLeast(7, 0, 0)
+}
+
+// SATAHotPlug is true when SATA ports can be marked hot-pluggable via `storageattach --hotpluggable`
+func (c Capabilities) SATAHotPlug() bool { return c.Version.AtLeast(5, 0, 0) }
+
+func (c Capabilities) NVMeController() bool { return c.Version.AtLeast(5, 1, 0) }
+
+func (c Capabilities) VirtioSCSIController() bool { return c.Version.AtLeast(6, 1, 0) }
+
+// CloudInitUnattended is true when `unattended install` can provision guests via cloud-init
+func (c Capabilities) CloudInitUnattended() bool { return c.Version.AtLeast(7, 0, 0) }
+
+// StorageControllers lists controller types that can be added to VMs
+func (c Capabilities) StorageControllers() []string {
+	ctrls := []string{StorageControllerIDE, StorageControllerSCSI, StorageControllerSATA}
+
+	if c.NVMeController() {
+		ctrls = append(ctrls, StorageControllerNVMe)
+	}
+
+	if c.VirtioSCSIController() {
+		ctrls = append(ctrls, StorageControllerVirtioSCSI)
+	}
+
+	return ctrls
+}
+
+func (c Capabilities) SupportsStorageController(ctrl string) bool {
+	return capabilitiesContain(c.StorageControllers(), ctrl)
+}
+
+// StemcellStorageControllers lists controllers stemcells can boot from;
+// since VirtualBox 6.1 stemcells only boot from SATA controller
+func (c Capabilities) StemcellStorageControllers() []string {
+	if c.Version.AtLeast(6, 1, 0) {
+		return []string{StorageControllerSATA}
+	}
+	return []string{StorageControllerIDE, StorageControllerSCSI, StorageControllerSATA}
+}
+
+func (c Capabilities) SupportsStemcellStorageController(ctrl string) bool {
+	return capabilitiesContain(c.StemcellStorageControllers(), ctrl)
+}
+
+// HostOnlyIPRanges is true when host-only networks can only use IP ranges
+// allowed by /etc/vbox/networks.conf (VirtualBox 6.1.28+ on Linux and macOS)
+func (c Capabilities) HostOnlyIPRanges() bool {
+	return c.HostOS != HostOSWindows && c.Version.AtLeast(6, 1, 28)
+}
+
+// Firmwares lists values accepted by `modifyvm --firmware`
+func (c Capabilities) Firmwares() []string {
+	return []string{"bios", "efi", "efi32", "efi64"}
+}
+
+func (c Capabilities) SupportsFirmware(firmware string) bool {
+	return capabilitiesContain(c.Firmwares(), strings.ToLower(firmware))
+}
+
+func capabilitiesContain(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package driver_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+)
+
+var _ = Describe("Capabilities", func() {
+	hostInfo := func(os string) string {
+		return "Host Information:\n\nProcessor count: 8\nOperating system: " + os + "\nOperating system version: 22.5.0\n"
+	}
+
+	Describe("ParseVersion", func() {
+		It("parses versions with distribution suffixes and revisions", func() {
+			ver, err := ParseVersion("6.1.38_Ubuntur153438\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ver).To(Equal(Version{Major: 6, Minor: 1, Patch: 38, Suffix: "_Ubuntu", Revision: 153438}))
+
+			ver, err = ParseVersion("7.0.10r158379")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ver).To(Equal(Version{Major: 7, Minor: 0, Patch: 10, Revision: 158379}))
+		})
+
+		It("skips warnings printed before version", func() {
+			ver, err := ParseVersion("WARNING: The vboxdrv kernel module is not loaded.\n\n7.1.4r165100\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ver.String()).To(Equal("7.1.4"))
+		})
+
+		It("returns an error instead of panicking for unexpected output", func() {
+			_, err := ParseVersion("1.2.3.4.5 beta")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unexpected VirtualBox version"))
+		})
+	})
+
+	Describe("NewCapabilities", func() {
+		It("uses host-only networks only with VirtualBox 7 on macOS", func() {
+			caps, err := NewCapabilities("7.0.10r158379", hostInfo("Darwin"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caps.HostOS).To(Equal(HostOSDarwin))
+			Expect(caps.HostOnlyNets()).To(BeTrue())
+
+			caps, err = NewCapabilities("7.0.10r158379", hostInfo("Linux"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caps.HostOnlyNets()).To(BeFalse())
+
+			caps, err = NewCapabilities("6.1.38r153438", hostInfo("Darwin"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caps.HostOnlyNets()).To(BeFalse())
+		})
+
+		It("determines features based on version", func() {
+			caps, err := NewCapabilities("6.0.24r139119", hostInfo("Windows 10"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caps.HostOS).To(Equal(HostOSWindows))
+			Expect(caps.SATAHotPlug()).To(BeTrue())
+			Expect(caps.SupportsStorageController(StorageControllerNVMe)).To(BeTrue())
+			Expect(caps.SupportsStorageController(StorageControllerVirtioSCSI)).To(BeFalse())
+			Expect(caps.CloudInitUnattended()).To(BeFalse())
+			Expect(caps.SupportsFirmware("EFI64")).To(BeTrue())
+			Expect(caps.SupportsFirmware("uefi")).To(BeFalse())
+		})
+
+		It("requires host operating system", func() {
+			_, err := NewCapabilities("7.0.10r158379", "Host Information:\n")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,196 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var (
+	// Covers `VBoxManage: error: Details: code VBOX_E_OBJECT_NOT_FOUND (0x80bb0001), component ...`
+	vboxErrDetailsMatch = regexp.MustCompile(`Details: code (\w+) \((0x[0-9a-fA-F]+)\)`)
+
+	// Covers `VBoxManage: error: Could not find a registered machine named 'vm-1'`
+	vboxErrMessageMatch = regexp.MustCompile(`(?m)^VBoxManage: error: (.+)$`)
+)
+
+// https://www.virtualbox.org/sdkref/_virtual_box_8idl.html
+const (
+	VBoxErrObjectNotFound     = "VBOX_E_OBJECT_NOT_FOUND"
+	VBoxErrInvalidVMState     = "VBOX_E_INVALID_VM_STATE"
+	VBoxErrVMError            = "VBOX_E_VM_ERROR"
+	VBoxErrFileError          = "VBOX_E_FILE_ERROR"
+	VBoxErrIPRTError          = "VBOX_E_IPRT_ERROR"
+	VBoxErrInvalidObjectState = "VBOX_E_INVALID_OBJECT_STATE"
+	VBoxErrHostError          = "VBOX_E_HOST_ERROR"
+	VBoxErrNotSupported       = "VBOX_E_NOT_SUPPORTED"
+	VBoxErrObjectInUse        = "VBOX_E_OBJECT_IN_USE"
+	VBoxErrAccessDenied       = "E_ACCESSDENIED"
+)
+
+// VBoxError represents failed VBoxManage invocation.
+// Code is empty if VBoxManage did not print error details.
+type VBoxError struct {
+	Code    string // e.g. VBOX_E_OBJECT_NOT_FOUND
+	Result  string // e.g. 0x80bb0001
+	Message string // first error line printed by VBoxManage
+
+	Args   []string
+	Status int
+	Output string
+}
+
+func NewVBoxError(args []string, status int, output string) VBoxError {
+	err := VBoxError{Args: args, Status: status, Output: output}
+
+	matches := vboxErrDetailsMatch.FindStringSubmatch(output)
+	if len(matches) == 3 {
+		err.Code = matches[1]
+		err.Result = strings.ToLower(matches[2])
+	}
+
+	matches = vboxErrMessageMatch.FindStringSubmatch(output)
+	if len(matches) == 2 {
+		err.Message = strings.TrimSpace(matches[1])
+	}
+
+	return err
+}
+
+func (e VBoxError) Error() string {
+	return fmt.Sprintf("Error executing command:\nCommand: '%v'\nExit code: %d\nOutput: '%s'", e.Args, e.Status, e.Output)
+}
+
+// Retryable decides whether failure is likely transient, for example
+// when VM session is still being closed by another VBoxManage process.
+func (e VBoxError) Retryable() bool {
+	switch e.Code {
+	case VBoxErrInvalidObjectState:
+		return true
+	case VBoxErrAccessDenied:
+		// Happens while VM is being registered or its session is being released
+		return strings.Contains(e.Message, "The object is not ready")
+	case "":
+		// Some failures (and older VBoxManage versions) do not print error details
+		return strings.Contains(e.Output, "The object is not ready")
+	default:
+		return false
+	}
+}
+
+// CommandTimeoutError represents VBoxManage invocation that did not finish
+// in time and was killed.
+type CommandTimeoutError struct {
+	Args    []string
+	Timeout time.Duration
+}
+
+func (e CommandTimeoutError) Error() string {
+	return fmt.Sprintf("VBoxManage '%s' timed out after %s", strings.Join(e.Args, " "), e.Timeout)
+}
+
+// CommandOutcomeUnknownError represents command whose exit status was never
+// received (e.g. connection was lost while it was running). Command might
+// have taken effect, hence it must not be blindly retried.
+type CommandOutcomeUnknownError struct {
+	Cmd string
+	Err error
+}
+
+func (e CommandOutcomeUnknownError) Error() string {
+	return fmt.Sprintf("Outcome of '%s' is unknown: %s", e.Cmd, e.Err)
+}
+
+func IsObjectNotFoundErr(err error) bool { return hasVBoxErrCode(err, VBoxErrObjectNotFound) }
+func IsInvalidVMStateErr(err error) bool { return hasVBoxErrCode(err, VBoxErrInvalidVMState) }
+func IsObjectInUseErr(err error) bool    { return hasVBoxErrCode(err, VBoxErrObjectInUse) }
+func IsFileErr(err error) bool           { return hasVBoxErrCode(err, VBoxErrFileError) }
+func IsAccessDeniedErr(err error) bool   { return hasVBoxErrCode(err, VBoxErrAccessDenied) }
+func IsHostErr(err error) bool           { return hasVBoxErrCode(err, VBoxErrHostError) }
+func IsNotSupportedErr(err error) bool   { return hasVBoxErrCode(err, VBoxErrNotSupported) }
+
+// AsVBoxError finds VBoxError in the chain of wrapped errors
+// (bosh-utils' ComplexError does not support errors.Unwrap).
+func AsVBoxError(err error) (VBoxError, bool) {
+	for err != nil {
+		switch typedErr := err.(type) {
+		case VBoxError:
+			return typedErr, true
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		case RetryableErrorImpl:
+			err = typedErr.Err
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return VBoxError{}, false
+}
+
+// IsCommandTimeoutErr checks chain of wrapped errors for CommandTimeoutError
+func IsCommandTimeoutErr(err error) bool {
+	for err != nil {
+		switch typedErr := err.(type) {
+		case CommandTimeoutError:
+			return true
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		case RetryableErrorImpl:
+			err = typedErr.Err
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return false
+}
+
+// IsCommandOutcomeUnknownErr checks chain of wrapped errors for CommandOutcomeUnknownError
+func IsCommandOutcomeUnknownErr(err error) bool {
+	for err != nil {
+		switch typedErr := err.(type) {
+		case CommandOutcomeUnknownError:
+			return true
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		case RetryableErrorImpl:
+			err = typedErr.Err
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return false
+}
+
+// IsNotExistErr checks chain of wrapped errors for a missing file
+// as reported by Runner's file operations
+func IsNotExistErr(err error) bool {
+	for err != nil {
+		if os.IsNotExist(err) {
+			return true
+		}
+
+		switch typedErr := err.(type) {
+		case bosherr.ComplexError:
+			err = typedErr.Cause
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return false
+}
+
+// newNotExistErr is returned by runners so that callers
+// can rely on os.IsNotExist regardless of the transport
+func newNotExistErr(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: os.ErrNotExist}
+}
+
+func hasVBoxErrCode(err error, code string) bool {
+	vboxErr, ok := AsVBoxError(err)
+	return ok && vboxErr.Code == code
+}
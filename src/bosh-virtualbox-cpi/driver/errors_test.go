@@ -0,0 +1,74 @@
+package driver_test
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+)
+
+var _ = Describe("VBoxError", func() {
+	missingVMOutput := `
+VBoxManage: error: Could not find a registered machine named 'vm-8b33e9d9'
+VBoxManage: error: Details: code VBOX_E_OBJECT_NOT_FOUND (0x80bb0001), component VirtualBoxWrap, interface IVirtualBox, callee nsISupports
+VBoxManage: error: Context: "FindMachine(Bstr(VMNameOrUuid).raw(), machine.asOutParam())" at line 2781 of file VBoxManageInfo.cpp
+`
+
+	Describe("NewVBoxError", func() {
+		It("parses error code, result and message", func() {
+			err := NewVBoxError([]string{"showvminfo", "vm-8b33e9d9"}, 1, missingVMOutput)
+			Expect(err.Code).To(Equal(VBoxErrObjectNotFound))
+			Expect(err.Result).To(Equal("0x80bb0001"))
+			Expect(err.Message).To(Equal("Could not find a registered machine named 'vm-8b33e9d9'"))
+			Expect(err.Retryable()).To(BeFalse())
+		})
+
+		It("leaves code empty when details are missing", func() {
+			err := NewVBoxError([]string{"list", "vms"}, 1, "VBoxManage: error: Something went wrong")
+			Expect(err.Code).To(BeEmpty())
+			Expect(err.Message).To(Equal("Something went wrong"))
+		})
+
+		It("considers not ready objects retryable", func() {
+			output := `VBoxManage: error: The object is not ready
+VBoxManage: error: Details: code E_ACCESSDENIED (0x80070005), component SessionMachine, interface IMachine`
+
+			err := NewVBoxError([]string{"modifyvm", "vm-1"}, 1, output)
+			Expect(err.Code).To(Equal(VBoxErrAccessDenied))
+			Expect(err.Retryable()).To(BeTrue())
+		})
+
+		It("considers not ready objects retryable even without error details", func() {
+			err := NewVBoxError([]string{"modifyvm", "vm-1"}, 1, "VBoxManage: error: The object is not ready")
+			Expect(err.Code).To(BeEmpty())
+			Expect(err.Retryable()).To(BeTrue())
+
+			err = NewVBoxError([]string{"modifyvm", "vm-1"}, 1, "VBoxManage: error: Something went wrong")
+			Expect(err.Retryable()).To(BeFalse())
+		})
+
+		It("considers locked sessions retryable", func() {
+			output := `VBoxManage: error: The machine 'vm-1' is already locked for a session (or being unlocked)
+VBoxManage: error: Details: code VBOX_E_INVALID_OBJECT_STATE (0x80bb0007), component MachineWrap, interface IMachine`
+
+			Expect(NewVBoxError(nil, 1, output).Retryable()).To(BeTrue())
+		})
+	})
+
+	Describe("IsObjectNotFoundErr", func() {
+		It("finds typed error through wrapped errors", func() {
+			var err error = NewVBoxError(nil, 1, missingVMOutput)
+			err = bosherr.WrapError(RetryableErrorImpl{Err: err}, "Retried '30' times")
+			err = bosherr.WrapError(err, "Determining controller name")
+
+			Expect(IsObjectNotFoundErr(err)).To(BeTrue())
+			Expect(IsInvalidVMStateErr(err)).To(BeFalse())
+		})
+
+		It("returns false for other errors", func() {
+			Expect(IsObjectNotFoundErr(bosherr.Error("other"))).To(BeFalse())
+			Expect(IsObjectNotFoundErr(nil)).To(BeFalse())
+		})
+	})
+})
@@ -1,64 +1,142 @@
 package driver
 
 import (
+	"context"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 var (
-	execDriverNotReadyErr = regexp.MustCompile("VBoxManage: error: The object is not ready")
-	execDriverDevCtlErr   = regexp.MustCompile(`failed to open \/dev\/vboxnetctl`)
-	execDriverGenericErr  = regexp.MustCompile("VBoxManage: error:")
+	execDriverDevCtlErr  = regexp.MustCompile(`failed to open \/dev\/vboxnetctl`)
+	execDriverGenericErr = regexp.MustCompile("VBoxManage: error:")
 )
 
 type ExecDriver struct {
 	runner  Runner
 	retrier Retrier
 	binPath string
+	audit   AuditLog
+
+	cache *ExecDriverCache
 
 	logTag string
 	logger boshlog.Logger
 }
 
-func NewExecDriver(runner Runner, retrier Retrier, binPath string, logger boshlog.Logger) ExecDriver {
+func NewExecDriver(runner Runner, retrier Retrier, binPath string, audit AuditLog, logger boshlog.Logger) ExecDriver {
 	return ExecDriver{
 		runner:  runner,
 		retrier: retrier,
 		binPath: binPath,
+		audit:   audit,
+
+		cache: NewExecDriverCache(0),
 
 		logTag: "driver.ExecDriver",
 		logger: logger,
 	}
 }
 
+// WithCache returns driver that shares given cache, for example
+// with drivers of previous CPI calls served by the same process
+func (d ExecDriver) WithCache(cache *ExecDriverCache) ExecDriver {
+	d.cache = cache
+	return d
+}
+
 func (d ExecDriver) Execute(args ...string) (string, error) {
 	return d.ExecuteComplex(args, ExecuteOpts{})
 }
 
 func (d ExecDriver) ExecuteComplex(args []string, opts ExecuteOpts) (string, error) {
+	return d.ExecuteContext(context.Background(), args, opts)
+}
+
+func (d ExecDriver) ExecuteContext(ctx context.Context, args []string, opts ExecuteOpts) (string, error) {
+	if len(args) > 0 && !execDriverReadOnlyCmds[args[0]] {
+		// Results of read-only commands executed concurrently
+		// (e.g. by other CPI calls) might be already outdated
+		d.cache.Clear()
+		defer d.cache.Clear()
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = execDriverTimeout(args)
+	}
+
 	var output string
 	var status int
+	var attempts int
+
+	startedAt := time.Now()
 
 	execFunc := func() error {
+		attempts++
+
+		if ctx.Err() != nil {
+			return bosherr.WrapErrorf(ctx.Err(), "Executing VBoxManage '%s'", strings.Join(args, " "))
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
 		var err error
 
-		output, status, err = d.runner.Execute(d.binPath, args...)
-		if err != nil {
-			return RetryableErrorImpl{err}
+		output, status, err = d.runner.ExecuteContext(attemptCtx, d.binPath, args...)
+		output = strings.Replace(output, "\r\n", "\n", -1)
+
+		if attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			// Hung commands are not retried since they most likely hang again
+			return CommandTimeoutError{Args: args, Timeout: timeout}
+		}
+
+		if IsCommandOutcomeUnknownErr(err) {
+			// Read-only commands are safe to repeat unlike commands that might have taken effect
+			if execDriverReadOnlyCmds[args[0]] {
+				return RetryableErrorImpl{Err: err}
+			}
+			return err
+		}
+
+		if err != nil && status <= 0 {
+			// Command could not be executed at all (e.g. SSH connection failure)
+			return RetryableErrorImpl{Err: err}
 		}
 
-		if status != 0 && execDriverNotReadyErr.MatchString(output) {
-			return RetryableErrorImpl{err}
+		if status != 0 {
+			vboxErr := NewVBoxError(args, status, output)
+			if vboxErr.Retryable() {
+				return RetryableErrorImpl{Err: vboxErr}
+			}
 		}
 
 		return nil
 	}
 
 	err := d.retrier.Retry(execFunc)
-	output = strings.Replace(output, "\r\n", "\n", -1)
+
+	output, err = d.checkResult(args, opts, output, status, err)
+
+	d.audit.Record(AuditEntry{
+		Time:       startedAt.UTC(),
+		Args:       args,
+		Status:     status,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		Retries:    attempts - 1,
+		Output:     output,
+		Error:      errString(err),
+	})
+
+	return output, err
+}
+
+func (d ExecDriver) checkResult(args []string, opts ExecuteOpts, output string, status int, err error) (string, error) {
 	if err != nil {
 		return output, err
 	}
@@ -89,13 +167,212 @@ func (d ExecDriver) ExecuteComplex(args []string, opts ExecuteOpts) (string, err
 	}
 
 	if errored {
-		return output, bosherr.Errorf("Error executing command:\nCommand: '%v'\nExit code: %d\nOutput: '%s'", args, status, output)
+		return output, NewVBoxError(args, status, output)
 	}
 
 	return output, nil
 }
 
-func (d ExecDriver) IsMissingVMErr(output string) bool {
-	// todo better check for VBOX_E_OBJECT_NOT_FOUND?
-	return strings.Contains(output, "Could not find a registered machine")
+func errString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// MachineInfo is cached until next command that might modify VirtualBox state.
+// Its extra data is only fetched when first asked for (most callers never need it).
+func (d ExecDriver) MachineInfo(nameOrID string) (MachineInfo, error) {
+	if info, found := d.cache.machineInfo(nameOrID); found {
+		return info, nil
+	}
+
+	output, err := d.Execute("showvminfo", nameOrID, "--machinereadable")
+	if err != nil {
+		return MachineInfo{}, err
+	}
+
+	info := NewMachineInfo(output)
+
+	// Extra data of inaccessible VM cannot be read
+	if info.State() != MachineStateInaccessible {
+		info = info.withExtraDataLoader(func() (string, error) {
+			return d.Execute("getextradata", nameOrID, "enumerate")
+		})
+	}
+
+	d.cache.setMachineInfo(nameOrID, info)
+
+	return info, nil
+}
+
+// Capabilities are not affected by VBoxManage commands hence kept
+// for the whole lifetime of the cache, unlike MachineInfo.
+func (d ExecDriver) Capabilities() (Capabilities, error) {
+	if caps, found := d.cache.capabilities(); found {
+		return caps, nil
+	}
+
+	versionOutput, err := d.Execute("--version")
+	if err != nil {
+		return Capabilities{}, bosherr.WrapError(err, "Determining VirtualBox version")
+	}
+
+	hostInfoOutput, err := d.Execute("list", "hostinfo")
+	if err != nil {
+		return Capabilities{}, bosherr.WrapError(err, "Determining VirtualBox host information")
+	}
+
+	caps, err := NewCapabilities(versionOutput, hostInfoOutput)
+	if err != nil {
+		return Capabilities{}, bosherr.WrapError(err, "Determining VirtualBox capabilities")
+	}
+
+	d.logger.Debug(d.logTag, "Determined VirtualBox %s on '%s'", caps.Version, caps.HostOS)
+
+	d.cache.setCapabilities(caps)
+
+	return caps, nil
+}
+
+const execDriverDefaultTimeout = 10 * time.Minute
+
+// execDriverTimeouts are generous to accommodate slow hosts;
+// they are meant to catch hung VBoxManage processes only.
+var execDriverTimeouts = map[string]time.Duration{
+	"--version":      1 * time.Minute,
+	"list":           2 * time.Minute,
+	"showvminfo":     2 * time.Minute,
+	"showmediuminfo": 2 * time.Minute,
+	"getextradata":   1 * time.Minute,
+	"setextradata":   1 * time.Minute,
+	"startvm":        5 * time.Minute,
+	"controlvm":      5 * time.Minute,
+	"import":         60 * time.Minute,
+	"clonevm":        60 * time.Minute,
+	"clonemedium":    60 * time.Minute,
+	"clonehd":        60 * time.Minute,
+	"createmedium":   30 * time.Minute,
+	"createhd":       30 * time.Minute,
+	"snapshot":       30 * time.Minute,
+}
+
+func execDriverTimeout(args []string) time.Duration {
+	if len(args) > 0 {
+		if timeout, found := execDriverTimeouts[args[0]]; found {
+			return timeout
+		}
+	}
+	return execDriverDefaultTimeout
+}
+
+var execDriverReadOnlyCmds = map[string]bool{
+	"--version":      true,
+	"list":           true,
+	"showvminfo":     true,
+	"showmediuminfo": true,
+	"getextradata":   true,
+}
+
+// ExecDriverCache keeps results of read-only commands until a command that
+// might modify VirtualBox state is executed. It is safe for concurrent use
+// so that drivers of concurrent CPI calls can share it (see daemon mode).
+type ExecDriverCache struct {
+	ttl time.Duration // 0 keeps entries until cleared
+
+	mu    sync.Mutex
+	infos map[string]execDriverCachedInfo
+
+	shared *ExecDriverCache // optional; consulted when entry is not found
+
+	caps *execDriverCachedCaps // not affected by VBoxManage commands
+}
+
+type execDriverCachedCaps struct {
+	mu   sync.Mutex
+	caps *Capabilities
+}
+
+type execDriverCachedInfo struct {
+	info     MachineInfo
+	cachedAt time.Time
+}
+
+func NewExecDriverCache(ttl time.Duration) *ExecDriverCache {
+	c := &ExecDriverCache{ttl: ttl, caps: &execDriverCachedCaps{}}
+	c.Clear()
+	return c
+}
+
+// ForCall returns cache to be used by a single CPI call. Results of read-only
+// commands are only shared with other calls when they expire (i.e. ttl > 0)
+// since VirtualBox state might be changed outside of CPI in the meantime.
+// Clearing call's cache also clears shared results.
+func (c *ExecDriverCache) ForCall() *ExecDriverCache {
+	callCache := NewExecDriverCache(0)
+	callCache.caps = c.caps
+
+	if c.ttl > 0 {
+		callCache.shared = c
+	}
+
+	return callCache
+}
+
+func (c *ExecDriverCache) Clear() {
+	c.mu.Lock()
+	c.infos = map[string]execDriverCachedInfo{}
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		c.shared.Clear()
+	}
+}
+
+func (c *ExecDriverCache) machineInfo(nameOrID string) (MachineInfo, bool) {
+	c.mu.Lock()
+	cached, found := c.infos[nameOrID]
+	c.mu.Unlock()
+
+	if found && !c.expired(cached.cachedAt) {
+		return cached.info, true
+	}
+
+	if c.shared != nil {
+		return c.shared.machineInfo(nameOrID)
+	}
+
+	return MachineInfo{}, false
+}
+
+func (c *ExecDriverCache) setMachineInfo(nameOrID string, info MachineInfo) {
+	c.mu.Lock()
+	c.infos[nameOrID] = execDriverCachedInfo{info, time.Now()}
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		c.shared.setMachineInfo(nameOrID, info)
+	}
+}
+
+func (c *ExecDriverCache) capabilities() (Capabilities, bool) {
+	c.caps.mu.Lock()
+	defer c.caps.mu.Unlock()
+
+	if c.caps.caps == nil {
+		return Capabilities{}, false
+	}
+
+	return *c.caps.caps, true
+}
+
+func (c *ExecDriverCache) setCapabilities(caps Capabilities) {
+	c.caps.mu.Lock()
+	defer c.caps.mu.Unlock()
+
+	c.caps.caps = &caps
+}
+
+func (c *ExecDriverCache) expired(cachedAt time.Time) bool {
+	return c.ttl > 0 && time.Since(cachedAt) > c.ttl
 }
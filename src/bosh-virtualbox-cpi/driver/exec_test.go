@@ -0,0 +1,177 @@
+package driver_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+	"bosh-virtualbox-cpi/driver/fakes"
+)
+
+var _ = Describe("ExecDriver", func() {
+	var (
+		vb     *fakes.VirtualBox
+		driver Driver
+	)
+
+	BeforeEach(func() {
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+		driver = vb.Driver(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("ExecuteComplex", func() {
+		It("returns error naming subcommand that timed out without retrying it", func() {
+			vb.HangOn("showvminfo")
+
+			_, err := driver.ExecuteComplex(
+				[]string{"showvminfo", "vm-1", "--machinereadable"}, ExecuteOpts{Timeout: 50 * time.Millisecond})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("VBoxManage 'showvminfo vm-1 --machinereadable' timed out after 50ms"))
+			Expect(IsCommandTimeoutErr(err)).To(BeTrue())
+
+			Expect(vb.Invocations()).To(HaveLen(1))
+		})
+	})
+
+	Describe("commands with unknown outcome", func() {
+		var runner *hookRunner
+
+		BeforeEach(func() {
+			runner = &hookRunner{Runner: vb.Runner(), lostCmds: map[string]bool{"modifyvm": true, "showvminfo": true}}
+			driver = NewExecDriver(runner, fakes.Retrier{}, "VBoxManage", NoopAuditLog{},
+				boshlog.NewLogger(boshlog.LevelNone)).WithCache(NewExecDriverCache(0))
+		})
+
+		It("does not retry commands that might have taken effect", func() {
+			_, err := driver.Execute("modifyvm", "vm-1", "--memory", "1024")
+			Expect(err).To(HaveOccurred())
+			Expect(IsCommandOutcomeUnknownErr(err)).To(BeTrue())
+			Expect(runner.modifies).To(Equal(1))
+		})
+
+		It("retries read-only commands", func() {
+			_, err := driver.Execute("showvminfo", "vm-1", "--machinereadable")
+			Expect(err).To(HaveOccurred())
+			Expect(runner.infos).To(Equal(3))
+		})
+	})
+
+	Describe("MachineInfo", func() {
+		It("fetches extra data only when asked for and only once", func() {
+			runner := &hookRunner{Runner: vb.Runner()}
+			driver = NewExecDriver(runner, fakes.Retrier{}, "VBoxManage", NoopAuditLog{},
+				boshlog.NewLogger(boshlog.LevelNone)).WithCache(NewExecDriverCache(0))
+
+			info, err := driver.MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.extraDatas).To(Equal(0))
+
+			val, found, err := info.ExtraData("VBoxInternal2/SilentReconfigureWhilePaused")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(val).To(Equal("1"))
+
+			info, err = driver.MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = info.ExtraData("GUI/LastCloseAction")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.infos).To(Equal(1))
+			Expect(runner.extraDatas).To(Equal(1))
+		})
+	})
+
+	Describe("MachineInfo with shared cache", func() {
+		var (
+			runner *hookRunner
+			shared *ExecDriverCache
+		)
+
+		BeforeEach(func() {
+			runner = &hookRunner{Runner: vb.Runner()}
+			shared = NewExecDriverCache(time.Minute)
+		})
+
+		newDriver := func() Driver {
+			return NewExecDriver(runner, fakes.Retrier{}, "VBoxManage", NoopAuditLog{},
+				boshlog.NewLogger(boshlog.LevelNone)).WithCache(shared.ForCall())
+		}
+
+		It("reuses machine info of other calls until VM is modified", func() {
+			_, err := newDriver().MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = newDriver().MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.infos).To(Equal(1))
+
+			_, err = newDriver().Execute("modifyvm", "vm-1", "--memory", "1024")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = newDriver().MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.infos).To(Equal(2))
+		})
+
+		It("drops machine info cached by other calls while VM was being modified", func() {
+			runner.duringModify = func() {
+				_, err := newDriver().MachineInfo("vm-1")
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, err := newDriver().Execute("modifyvm", "vm-1", "--memory", "1024")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.infos).To(Equal(1))
+
+			_, err = newDriver().MachineInfo("vm-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runner.infos).To(Equal(2))
+		})
+	})
+})
+
+// hookRunner reports a single VM and runs given func while it is being modified
+type hookRunner struct {
+	fakes.Runner
+
+	infos        int
+	extraDatas   int
+	modifies     int
+	duringModify func()
+
+	// Commands whose connection is lost before exit status is received
+	lostCmds map[string]bool
+}
+
+func (r *hookRunner) ExecuteContext(_ context.Context, _ string, args ...string) (string, int, error) {
+	lost := CommandOutcomeUnknownError{Cmd: args[0], Err: errors.New("connection lost")}
+
+	switch args[0] {
+	case "showvminfo":
+		r.infos++
+		if r.lostCmds[args[0]] {
+			return "", -1, lost
+		}
+		return "name=\"vm-1\"\nVMState=\"poweroff\"\n", 0, nil
+
+	case "getextradata":
+		r.extraDatas++
+		return "Key: VBoxInternal2/SilentReconfigureWhilePaused, Value: 1\n", 0, nil
+
+	case "modifyvm":
+		r.modifies++
+		if r.lostCmds[args[0]] {
+			return "", -1, lost
+		}
+		if r.duringModify != nil {
+			r.duringModify()
+		}
+	}
+
+	return "", 0, nil
+}
@@ -1,8 +1,12 @@
 package driver
 
 import (
+	"context"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -10,30 +14,37 @@ var (
 )
 
 type ExpandingPathRunner struct {
-	other           RawRunner
+	other RawRunner
+
+	// Guards home dir since runner might be shared by concurrent CPI calls
+	mu              sync.Mutex
 	resolvedHomeDir string
 }
 
 func NewExpandingPathRunner(other RawRunner) *ExpandingPathRunner {
-	return &ExpandingPathRunner{other, ""}
+	return &ExpandingPathRunner{other: other}
 }
 
 func (r *ExpandingPathRunner) Execute(path string, args ...string) (string, int, error) {
+	return r.ExecuteContext(context.Background(), path, args...)
+}
+
+func (r *ExpandingPathRunner) ExecuteContext(ctx context.Context, path string, args ...string) (string, int, error) {
 	args, err := r.expandPaths(args)
 	if err != nil {
 		return "", 0, err
 	}
 
-	return r.other.Execute(path, args...)
+	return r.other.ExecuteContext(ctx, path, args...)
 }
 
 func (r *ExpandingPathRunner) Upload(srcPath, dstPath string) error {
-	srcPath, err := r.expandPath(srcPath)
+	srcPath, err := r.ExpandPath(srcPath)
 	if err != nil {
 		return err
 	}
 
-	dstPath, err = r.expandPath(dstPath)
+	dstPath, err = r.ExpandPath(dstPath)
 	if err != nil {
 		return err
 	}
@@ -42,7 +53,7 @@ func (r *ExpandingPathRunner) Upload(srcPath, dstPath string) error {
 }
 
 func (r *ExpandingPathRunner) Put(path string, contents []byte) error {
-	path, err := r.expandPath(path)
+	path, err := r.ExpandPath(path)
 	if err != nil {
 		return err
 	}
@@ -50,18 +61,80 @@ func (r *ExpandingPathRunner) Put(path string, contents []byte) error {
 }
 
 func (r *ExpandingPathRunner) Get(path string) ([]byte, error) {
-	path, err := r.expandPath(path)
+	path, err := r.ExpandPath(path)
 	if err != nil {
 		return nil, err
 	}
 	return r.other.Get(path)
 }
 
+func (r *ExpandingPathRunner) Append(path string, contents []byte) error {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	return r.other.Append(path, contents)
+}
+
+func (r *ExpandingPathRunner) MkdirAll(path string) error {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	return r.other.MkdirAll(path)
+}
+
+func (r *ExpandingPathRunner) List(path string) ([]string, error) {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.other.List(path)
+}
+
+func (r *ExpandingPathRunner) Stat(path string) (os.FileInfo, error) {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.other.Stat(path)
+}
+
+func (r *ExpandingPathRunner) RemoveAll(path string) error {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	return r.other.RemoveAll(path)
+}
+
+func (r *ExpandingPathRunner) Rename(oldPath, newPath string) error {
+	oldPath, err := r.ExpandPath(oldPath)
+	if err != nil {
+		return err
+	}
+
+	newPath, err = r.ExpandPath(newPath)
+	if err != nil {
+		return err
+	}
+
+	return r.other.Rename(oldPath, newPath)
+}
+
+func (r *ExpandingPathRunner) Lock(path string, timeout time.Duration) (Lock, error) {
+	path, err := r.ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.other.Lock(path, timeout)
+}
+
 func (r *ExpandingPathRunner) expandPaths(args []string) ([]string, error) {
 	var expandedArgs []string
 	var err error
 	for _, arg := range args {
-		arg, err = r.expandPath(arg)
+		arg, err = r.ExpandPath(arg)
 		if err != nil {
 			return nil, err
 		}
@@ -70,7 +143,8 @@ func (r *ExpandingPathRunner) expandPaths(args []string) ([]string, error) {
 	return expandedArgs, nil
 }
 
-func (r *ExpandingPathRunner) expandPath(arg string) (string, error) {
+// ExpandPath resolves leading ~ to home directory on the host
+func (r *ExpandingPathRunner) ExpandPath(arg string) (string, error) {
 	if strings.HasPrefix(arg, homeMarker) {
 		homeDir, err := r.homeDir()
 		if err != nil {
@@ -82,6 +156,9 @@ func (r *ExpandingPathRunner) expandPath(arg string) (string, error) {
 }
 
 func (r *ExpandingPathRunner) homeDir() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if len(r.resolvedHomeDir) > 0 {
 		return r.resolvedHomeDir, nil
 	}
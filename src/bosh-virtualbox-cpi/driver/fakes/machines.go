@@ -0,0 +1,367 @@
+package fakes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ovfEnvelope covers parts of OVF descriptor used for import
+type ovfEnvelope struct {
+	References []struct {
+		ID   string `xml:"id,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"References>File"`
+
+	Disks []struct {
+		DiskID   string `xml:"diskId,attr"`
+		FileRef  string `xml:"fileRef,attr"`
+		Capacity string `xml:"capacity,attr"`
+		Units    string `xml:"capacityAllocationUnits,attr"`
+	} `xml:"DiskSection>Disk"`
+
+	VirtualSystem struct {
+		ID    string `xml:"id,attr"`
+		Items []struct {
+			InstanceID      string   `xml:"InstanceID"`
+			ResourceType    string   `xml:"ResourceType"`
+			Parent          string   `xml:"Parent"`
+			AddressOnParent string   `xml:"AddressOnParent"`
+			HostResources   []string `xml:"HostResource"`
+		} `xml:"VirtualHardwareSection>Item"`
+	} `xml:"VirtualSystem"`
+}
+
+func (vb *VirtualBox) importCmd(args vboxManageArgs) vboxManageResult {
+	ovfPath := filepath.Clean(args.Positional(0))
+
+	contents, found := vb.files[ovfPath]
+	if !found {
+		return vboxErr("VBOX_E_FILE_ERROR", "Appliance",
+			"Appliance read failed\nVBoxManage: error: Could not open the file '%s' (VERR_FILE_NOT_FOUND)", ovfPath)
+	}
+
+	var env ovfEnvelope
+
+	err := xml.Unmarshal(contents, &env)
+	if err != nil {
+		return vboxErr("VBOX_E_FILE_ERROR", "Appliance",
+			"Appliance read failed\nVBoxManage: error: Error reading \"%s\": %s", ovfPath, err)
+	}
+
+	name := env.VirtualSystem.ID
+	if len(name) == 0 {
+		name = "vm"
+	}
+
+	for i := 1; vb.findVM(name) != nil; i++ {
+		name = fmt.Sprintf("%s_%d", env.VirtualSystem.ID, i)
+	}
+
+	files := map[string]string{}
+	for _, ref := range env.References {
+		files[ref.ID] = ref.Href
+	}
+
+	type ovfDisk struct {
+		href       string
+		capacityMB int
+	}
+
+	disks := map[string]ovfDisk{}
+	for _, disk := range env.Disks {
+		disks[disk.DiskID] = ovfDisk{files[disk.FileRef], vb.ovfCapacityMB(disk.Capacity, disk.Units)}
+	}
+
+	// Check referenced files before creating anything
+	for _, href := range files {
+		if _, found := vb.files[filepath.Join(filepath.Dir(ovfPath), href)]; !found {
+			return vboxErr("VBOX_E_FILE_ERROR", "Appliance",
+				"Could not find file '%s' (VERR_FILE_NOT_FOUND)", filepath.Join(filepath.Dir(ovfPath), href))
+		}
+	}
+
+	vm := vb.newVM(name, "")
+	controllers := map[string]*fakeController{}
+
+	for _, item := range env.VirtualSystem.Items {
+		var bus string
+
+		switch item.ResourceType {
+		case "5":
+			bus = "IDE"
+		case "6":
+			bus = "SCSI"
+		case "20":
+			bus = "SATA"
+		default:
+			continue
+		}
+
+		ctrl := newFakeController(bus, vb.isV7())
+		controllers[item.InstanceID] = ctrl
+		vm.Controllers = append(vm.Controllers, ctrl)
+	}
+
+	for _, item := range env.VirtualSystem.Items {
+		if item.ResourceType != "17" {
+			continue
+		}
+
+		ctrl := controllers[item.Parent]
+		if ctrl == nil {
+			vb.removeVM(vm)
+			return vboxErr("VBOX_E_FILE_ERROR", "Appliance",
+				"Appliance import failed\nVBoxManage: error: Invalid parent '%s' for disk item '%s'", item.Parent, item.InstanceID)
+		}
+
+		for _, res := range item.HostResources {
+			disk, found := disks[strings.TrimPrefix(strings.TrimPrefix(res, "ovf:/disk/"), "/disk/")]
+			if !found {
+				continue
+			}
+
+			srcPath := filepath.Join(filepath.Dir(ovfPath), disk.href)
+			dstPath := filepath.Join(vb.machineFolder(name), disk.href)
+			vb.writeFile(dstPath, append([]byte{}, vb.files[srcPath]...))
+
+			medium := vb.newMedium(mediumKindHDD, dstPath, "VMDK", "Standard", disk.capacityMB)
+
+			port := item.AddressOnParent
+			if len(port) == 0 {
+				port = "0"
+			}
+
+			device := "0"
+			if ctrl.Bus == "IDE" {
+				p, _ := strconv.Atoi(port)
+				port, device = strconv.Itoa(p/2), strconv.Itoa(p%2)
+			}
+
+			ctrl.Attachments[ctrl.slot(port, device)] = &fakeAttachment{Type: "hdd", MediumUUID: medium.UUID}
+		}
+	}
+
+	vm.NICs[0] = fakeNIC{Type: "nat", MAC: fmt.Sprintf("080027%06X", vb.lastID)}
+
+	out := fmt.Sprintf(`0%%...10%%...20%%...30%%...40%%...50%%...60%%...70%%...80%%...90%%...100%%
+Interpreting %s...
+OK.
+Disks:
+  vmdisk1
+Virtual system 0:
+ 0: Suggested OS type: "Ubuntu_64"
+    (change with "--vsys 0 --ostype <type>"; use "list ostypes" to list all possible values)
+ 1: Suggested VM name "%s"
+    (change with "--vsys 0 --vmname <name>")
+ 2: Number of CPUs: 1
+    (change with "--vsys 0 --cpus <n>")
+ 3: Guest memory: 512 MB
+    (change with "--vsys 0 --memory <MB>")
+%s
+Successfully imported the appliance.
+`, ovfPath, name, vboxManageProgress)
+
+	return vboxOK(out)
+}
+
+func (vb *VirtualBox) ovfCapacityMB(capacity, units string) int {
+	size, err := strconv.Atoi(capacity)
+	if err != nil || size <= 0 {
+		return 5120
+	}
+
+	switch {
+	case strings.Contains(units, "2^30"):
+		return size * 1024
+	case strings.Contains(units, "2^20"):
+		return size
+	default:
+		return size / (1024 * 1024)
+	}
+}
+
+func (vb *VirtualBox) snapshotCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	name := args.Positional(2)
+
+	switch args.Positional(1) {
+	case "take":
+		snap := &fakeSnapshot{
+			Name:        name,
+			UUID:        vb.newUUID(),
+			Attachments: map[string]map[string]fakeAttachment{},
+		}
+
+		for _, ctrl := range vm.Controllers {
+			snap.Attachments[ctrl.Name] = map[string]fakeAttachment{}
+
+			for slot, att := range ctrl.Attachments {
+				snap.Attachments[ctrl.Name][slot] = *att
+
+				if att.Type != "hdd" || len(att.MediumUUID) == 0 {
+					continue
+				}
+
+				// Current state continues writing into a new differencing image
+				parent := vb.findMedium(att.MediumUUID)
+				diffUUID := vb.newUUID()
+				diffPath := filepath.Join(vb.machineFolder(vm.Name), "Snapshots", "{"+diffUUID+"}"+filepath.Ext(parent.Path))
+
+				diff := vb.newMedium(mediumKindHDD, diffPath, parent.Format, "Diff", parent.CapacityMB)
+				diff.UUID = diffUUID
+				diff.ParentUUID = parent.UUID
+
+				att.MediumUUID = diff.UUID
+			}
+		}
+
+		vm.Snapshots = append(vm.Snapshots, snap)
+
+		return vboxOK(fmt.Sprintf("%s\nSnapshot taken. UUID: %s\n", vboxManageProgress, snap.UUID))
+
+	case "delete":
+		snap := vm.snapshot(name)
+		if snap == nil {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Machine",
+				"Could not find a snapshot named '%s'", name)
+		}
+
+		// Merge differencing images created by the snapshot back into their parents
+		for _, ctrl := range vm.Controllers {
+			for slot, att := range ctrl.Attachments {
+				snapAtt, found := snap.Attachments[ctrl.Name][slot]
+				if !found || att.Type != "hdd" || len(att.MediumUUID) == 0 {
+					continue
+				}
+
+				diff := vb.findMedium(att.MediumUUID)
+				if diff == nil || diff.ParentUUID != snapAtt.MediumUUID {
+					continue
+				}
+
+				if len(vb.mediumChildren(diff.UUID)) > 0 {
+					return vboxErr("VBOX_E_INVALID_OBJECT_STATE", "Machine",
+						"Snapshot '%s' of the machine '%s' cannot be deleted because medium '%s' has more than one child", name, vm.Name, diff.Path)
+				}
+
+				if base := vb.findMedium(snapAtt.MediumUUID); base != nil && base.CapacityMB < diff.CapacityMB {
+					base.CapacityMB = diff.CapacityMB
+				}
+
+				att.MediumUUID = snapAtt.MediumUUID
+				vb.removeAll(diff.Path)
+				vb.removeMedium(diff)
+			}
+		}
+
+		for i, s := range vm.Snapshots {
+			if s == snap {
+				vm.Snapshots = append(vm.Snapshots[:i], vm.Snapshots[i+1:]...)
+				break
+			}
+		}
+
+		return vboxOK(vboxManageProgress + "\n")
+
+	default:
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(1))
+	}
+}
+
+func (vb *VirtualBox) cloneVMCmd(args vboxManageArgs) vboxManageResult {
+	src, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	name := args.Get("--name")
+	if len(name) == 0 {
+		name = src.Name + " Clone"
+	}
+
+	if vb.findVM(name) != nil || vb.dirs[vb.machineFolder(name)] {
+		return vboxErr("VBOX_E_FILE_ERROR", "VirtualBox",
+			"Machine settings file '%s' already exists", filepath.Join(vb.machineFolder(name), name+".vbox"))
+	}
+
+	if uuid := args.Get("--uuid"); len(uuid) > 0 && vb.findVM(uuid) != nil {
+		return vboxErr("VBOX_E_OBJECT_IN_USE", "VirtualBox",
+			"Machine with UUID {%s} already exists", uuid)
+	}
+
+	linked := strings.Contains(args.Get("--options"), "link")
+
+	var snap *fakeSnapshot
+
+	if args.Has("--snapshot") {
+		snap = src.snapshot(args.Get("--snapshot"))
+		if snap == nil {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Machine",
+				"Could not find a snapshot named '%s'", args.Get("--snapshot"))
+		}
+	} else if linked {
+		return vboxErr("E_INVALIDARG", "Machine",
+			"Linked clone can only be created from a snapshot")
+	}
+
+	vm := vb.newVM(name, args.Get("--uuid"))
+
+	for k, v := range src.Settings {
+		vm.Settings[k] = v
+	}
+
+	for i, nic := range src.NICs {
+		vm.NICs[i] = nic
+		if nic.Type != "none" {
+			vm.NICs[i].MAC = fmt.Sprintf("080027%06X", vb.lastID+i)
+		}
+	}
+
+	for _, srcCtrl := range src.Controllers {
+		ctrl := *srcCtrl
+		ctrl.Attachments = map[string]*fakeAttachment{}
+
+		atts := map[string]fakeAttachment{}
+		if snap != nil {
+			atts = snap.Attachments[srcCtrl.Name]
+		} else {
+			for slot, att := range srcCtrl.Attachments {
+				atts[slot] = *att
+			}
+		}
+
+		for slot, att := range atts {
+			att := att
+
+			if att.Type == "hdd" && len(att.MediumUUID) > 0 {
+				base := vb.findMedium(att.MediumUUID)
+				diffUUID := vb.newUUID()
+
+				if linked {
+					diffPath := filepath.Join(vb.machineFolder(name), "Snapshots", "{"+diffUUID+"}"+filepath.Ext(base.Path))
+					diff := vb.newMedium(mediumKindHDD, diffPath, base.Format, "Diff", base.CapacityMB)
+					diff.UUID = diffUUID
+					diff.ParentUUID = base.UUID
+					att.MediumUUID = diff.UUID
+				} else {
+					clonePath := filepath.Join(vb.machineFolder(name), name+"-disk"+slot+filepath.Ext(base.Path))
+					clone := vb.newMedium(mediumKindHDD, clonePath, base.Format, "Standard", base.CapacityMB)
+					att.MediumUUID = clone.UUID
+				}
+			}
+
+			ctrl.Attachments[slot] = &att
+		}
+
+		vm.Controllers = append(vm.Controllers, &ctrl)
+	}
+
+	return vboxOK(fmt.Sprintf("%s\nMachine has been successfully cloned as \"%s\"\n", vboxManageProgress, name))
+}
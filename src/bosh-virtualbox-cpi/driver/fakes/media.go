@@ -0,0 +1,474 @@
+package fakes
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediumKindHDD = "hdd"
+	mediumKindDVD = "dvd"
+)
+
+type fakeMedium struct {
+	UUID       string
+	ParentUUID string
+	Kind       string // hdd or dvd
+
+	Path        string
+	Format      string // e.g. VMDK, VDI, VHD, RAW
+	Variant     string // e.g. Standard, Fixed, Split2G, Diff
+	CapacityMB  int
+	Description string
+}
+
+func (vb *VirtualBox) findMedium(pathOrID string) *fakeMedium {
+	for _, m := range vb.media {
+		if m.UUID == pathOrID || m.Path == filepath.Clean(pathOrID) {
+			return m
+		}
+	}
+	return nil
+}
+
+func (vb *VirtualBox) removeMedium(medium *fakeMedium) {
+	for i, m := range vb.media {
+		if m == medium {
+			vb.media = append(vb.media[:i], vb.media[i+1:]...)
+			return
+		}
+	}
+}
+
+func (vb *VirtualBox) mediumChildren(uuid string) []*fakeMedium {
+	var children []*fakeMedium
+	for _, m := range vb.media {
+		if m.ParentUUID == uuid {
+			children = append(children, m)
+		}
+	}
+	return children
+}
+
+// mediumUsers returns VMs that use medium in current state or in snapshots
+func (vb *VirtualBox) mediumUsers(uuid string) []*fakeVM {
+	var vms []*fakeVM
+
+	for _, vm := range vb.vms {
+		for _, used := range append(vm.attachedMediumUUIDs(mediumKindHDD), append(vm.attachedMediumUUIDs("dvddrive"), vm.snapshotMediumUUIDs()...)...) {
+			if used == uuid {
+				vms = append(vms, vm)
+				break
+			}
+		}
+	}
+
+	return vms
+}
+
+// lockedBy returns running VM that has medium directly attached
+func (vb *VirtualBox) lockedBy(uuid string) *fakeVM {
+	for _, vm := range vb.vms {
+		if !vm.isRunning() {
+			continue
+		}
+		for _, used := range vm.attachedMediumUUIDs(mediumKindHDD) {
+			if used == uuid {
+				return vm
+			}
+		}
+	}
+	return nil
+}
+
+func (vb *VirtualBox) newMedium(kind, path, format, variant string, capacityMB int) *fakeMedium {
+	medium := &fakeMedium{
+		UUID:       vb.newUUID(),
+		Kind:       kind,
+		Path:       filepath.Clean(path),
+		Format:     format,
+		Variant:    variant,
+		CapacityMB: capacityMB,
+	}
+
+	vb.media = append(vb.media, medium)
+
+	if _, found := vb.files[medium.Path]; !found {
+		vb.writeFile(medium.Path, []byte{})
+	}
+
+	return medium
+}
+
+func (vb *VirtualBox) mediumFileNotFound(path string) vboxManageResult {
+	return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+		"Could not find file for the medium '%s' (VERR_FILE_NOT_FOUND)", path)
+}
+
+// lookupMedium finds registered medium or opens one from existing file
+func (vb *VirtualBox) lookupMedium(kind, pathOrID string) (*fakeMedium, *vboxManageResult) {
+	if m := vb.findMedium(pathOrID); m != nil {
+		return m, nil
+	}
+
+	path := filepath.Clean(pathOrID)
+
+	if _, found := vb.files[path]; !found {
+		res := vb.mediumFileNotFound(path)
+		return nil, &res
+	}
+
+	format := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+	if kind == mediumKindDVD {
+		format = "RAW"
+	}
+
+	// Opened existing images report some reasonable size
+	return vb.newMedium(kind, path, format, "Standard", 5120), nil
+}
+
+func (vb *VirtualBox) createMediumCmd(args vboxManageArgs) vboxManageResult {
+	path := filepath.Clean(args.Get("--filename"))
+
+	size, err := strconv.Atoi(args.Get("--size"))
+	if err != nil {
+		return vboxSyntaxErr("Invalid size '%s'", args.Get("--size"))
+	}
+
+	format := strings.ToUpper(args.Get("--format"))
+	if len(format) == 0 {
+		format = "VDI"
+	}
+
+	variant := args.Get("--variant")
+	if len(variant) == 0 {
+		variant = "Standard"
+	}
+
+	switch format {
+	case "VDI", "VMDK", "VHD":
+	default:
+		return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "SystemProperties",
+			"Could not find a media format '%s'", format)
+	}
+
+	if strings.Contains(variant, "Split2G") && format != "VMDK" {
+		return vboxErr("VBOX_E_NOT_SUPPORTED", "Medium",
+			"Failed to create medium\nVBoxManage: error: Could not create the medium storage unit '%s'.\nVBoxManage: error: VD: The given medium format does not support variant 'Split2G' (VERR_VD_INVALID_TYPE)", path)
+	}
+
+	if _, found := vb.files[path]; found || vb.findMedium(path) != nil {
+		return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+			"Failed to create medium\nVBoxManage: error: Could not create the medium storage unit '%s'.\nVBoxManage: error: VD: cannot create image '%s', file already exists (VERR_ALREADY_EXISTS)", path, path)
+	}
+
+	if !vb.dirs[filepath.Dir(path)] {
+		return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+			"Failed to create medium\nVBoxManage: error: Could not create the medium storage unit '%s'.\nVBoxManage: error: VD: cannot create image '%s' (VERR_PATH_NOT_FOUND)", path, path)
+	}
+
+	medium := vb.newMedium(mediumKindHDD, path, format, variant, size)
+
+	return vboxOK(fmt.Sprintf("%s\nMedium created. UUID: %s\n", vboxManageProgress, medium.UUID))
+}
+
+func (vb *VirtualBox) showMediumInfoCmd(args vboxManageArgs) vboxManageResult {
+	medium, errRes := vb.lookupMedium(mediumKindHDD, args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	return vboxOK(vb.mediumInfo(medium))
+}
+
+func (vb *VirtualBox) mediumInfo(m *fakeMedium) string {
+	parent := "base"
+	typ := "normal (base)"
+
+	if len(m.ParentUUID) > 0 {
+		parent = m.ParentUUID
+		typ = "normal (differencing)"
+	}
+
+	variant := "dynamic default"
+	switch m.Variant {
+	case "Fixed":
+		variant = "fixed default"
+	case "Split2G":
+		variant = "dynamic split2G"
+	case "Diff":
+		variant = "dynamic diff"
+	}
+
+	out := fmt.Sprintf(`UUID:           %s
+Parent UUID:    %s
+State:          created
+Type:           %s
+Location:       %s
+Storage format: %s
+Format variant: %s
+Capacity:       %d MBytes
+Size on disk:   1 MBytes
+Encryption:     disabled
+Property:       AllocationBlockSize=1048576
+`, m.UUID, parent, typ, m.Path, m.Format, variant, m.CapacityMB)
+
+	for i, vm := range vb.mediumUsers(m.UUID) {
+		prefix := "In use by VMs:  "
+		if i > 0 {
+			prefix = "                "
+		}
+		out += fmt.Sprintf("%s%s (UUID: %s)\n", prefix, vm.Name, vm.UUID)
+	}
+
+	for i, child := range vb.mediumChildren(m.UUID) {
+		prefix := "Child UUIDs:    "
+		if i > 0 {
+			prefix = "                "
+		}
+		out += prefix + child.UUID + "\n"
+	}
+
+	if len(m.Description) > 0 {
+		out += fmt.Sprintf("Description:    %s\n", m.Description)
+	}
+
+	return out
+}
+
+func (vb *VirtualBox) listHDDs() string {
+	var chunks []string
+
+	for _, m := range vb.media {
+		if m.Kind != mediumKindHDD {
+			continue
+		}
+
+		parent := "base"
+		if len(m.ParentUUID) > 0 {
+			parent = m.ParentUUID
+		}
+
+		chunks = append(chunks, fmt.Sprintf(`UUID:           %s
+Parent UUID:    %s
+State:          created
+Type:           normal (base)
+Location:       %s
+Storage format: %s
+Capacity:       %d MBytes
+Encryption:     disabled
+`, m.UUID, parent, m.Path, m.Format, m.CapacityMB))
+	}
+
+	return strings.Join(chunks, "\n")
+}
+
+func (vb *VirtualBox) cloneMediumCmd(args vboxManageArgs) vboxManageResult {
+	src, errRes := vb.lookupMedium(mediumKindHDD, args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	dstPath := filepath.Clean(args.Positional(1))
+
+	if vm := vb.lockedBy(src.UUID); vm != nil {
+		return vboxErr("VBOX_E_INVALID_OBJECT_STATE", "Medium",
+			"Failed to clone medium\nVBoxManage: error: Medium '%s' is locked for writing by another task", src.Path)
+	}
+
+	if _, found := vb.files[dstPath]; found {
+		return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+			"Failed to clone medium\nVBoxManage: error: Could not create the clone medium '%s' (VERR_ALREADY_EXISTS)", dstPath)
+	}
+
+	if !vb.dirs[filepath.Dir(dstPath)] {
+		return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+			"Failed to clone medium\nVBoxManage: error: Could not create the clone medium '%s' (VERR_PATH_NOT_FOUND)", dstPath)
+	}
+
+	format := strings.ToUpper(args.Get("--format"))
+	if len(format) == 0 {
+		format = src.Format
+	}
+
+	variant := args.Get("--variant")
+	if len(variant) == 0 {
+		variant = "Standard"
+	}
+
+	dst := vb.newMedium(mediumKindHDD, dstPath, format, variant, src.CapacityMB)
+
+	return vboxOK(fmt.Sprintf("%s\nClone medium created in format '%s'. UUID: %s\n", vboxManageProgress, format, dst.UUID))
+}
+
+func (vb *VirtualBox) closeMediumCmd(args vboxManageArgs) vboxManageResult {
+	medium, errRes := vb.lookupMedium(mediumKindHDD, args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if users := vb.mediumUsers(medium.UUID); len(users) > 0 {
+		return vboxErr("VBOX_E_OBJECT_IN_USE", "Medium",
+			"Cannot close medium '%s' because it is still attached to %d virtual machines", medium.Path, len(users))
+	}
+
+	if children := vb.mediumChildren(medium.UUID); len(children) > 0 {
+		return vboxErr("VBOX_E_OBJECT_IN_USE", "Medium",
+			"Cannot close medium '%s' because it has %d child media", medium.Path, len(children))
+	}
+
+	vb.removeMedium(medium)
+
+	if args.Has("--delete") {
+		vb.removeAll(medium.Path)
+		return vboxOK(vboxManageProgress + "\n")
+	}
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) modifyMediumCmd(args vboxManageArgs) vboxManageResult {
+	medium, errRes := vb.lookupMedium(mediumKindHDD, args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if args.Has("--description") {
+		medium.Description = args.Get("--description")
+	}
+
+	if args.Has("--resize") {
+		size, err := strconv.Atoi(args.Get("--resize"))
+		if err != nil {
+			return vboxSyntaxErr("Invalid size '%s'", args.Get("--resize"))
+		}
+
+		if vb.lockedBy(medium.UUID) != nil {
+			return vboxErr("VBOX_E_INVALID_OBJECT_STATE", "Medium",
+				"Medium '%s' is locked for writing by another task", medium.Path)
+		}
+
+		// VirtualBox 6.x cannot resize VMDK images
+		if medium.Format == "VMDK" && !vb.isV7() {
+			return vboxErr("VBOX_E_NOT_SUPPORTED", "Medium",
+				"Failed to resize medium\nVBoxManage: error: Resize medium operation for this format is not implemented yet!")
+		}
+
+		if size < medium.CapacityMB {
+			return vboxErr("VBOX_E_NOT_SUPPORTED", "Medium",
+				"Failed to resize medium\nVBoxManage: error: Medium '%s' cannot be shrunk", medium.Path)
+		}
+
+		medium.CapacityMB = size
+	}
+
+	if args.Has("--move") {
+		dstPath := filepath.Clean(args.Get("--move"))
+
+		if _, found := vb.files[dstPath]; found {
+			return vboxErr("VBOX_E_FILE_ERROR", "Medium",
+				"Failed to move medium\nVBoxManage: error: Cannot move medium '%s' to '%s' because destination already exists", medium.Path, dstPath)
+		}
+
+		vb.renamePath(medium.Path, dstPath)
+		medium.Path = dstPath
+
+		return vboxOK(fmt.Sprintf("%s\nMove medium with UUID %s finished\n", vboxManageProgress, medium.UUID))
+	}
+
+	if args.Has("--resize") {
+		return vboxOK(vboxManageProgress + "\n")
+	}
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) storageAttachCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	ctrl := vm.controller(args.Get("--storagectl"))
+	if ctrl == nil {
+		return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Machine",
+			"Could not find a controller named '%s'", args.Get("--storagectl"))
+	}
+
+	port, device := args.Get("--port"), args.Get("--device")
+	if len(device) == 0 {
+		device = "0"
+	}
+
+	if !ctrl.inRange(port, device) {
+		return vboxErr("E_INVALIDARG", "Machine",
+			"The port and/or device parameter are out of range: port=%s (must be in range [0, %d]), device=%s (must be in range [0, %d])",
+			port, ctrl.Ports-1, device, ctrl.Devices-1)
+	}
+
+	typ := args.Get("--type")
+	mediumArg := args.Get("--medium")
+	slot := ctrl.slot(port, device)
+
+	// Only removable media can be changed while VM is running,
+	// unless VM is paused with silent reconfiguration enabled
+	if vm.isRunning() && typ != "dvddrive" {
+		silent := vm.State == "paused" && vm.ExtraData["VBoxInternal2/SilentReconfigureWhilePaused"] == "1"
+		if !silent {
+			return vboxErr("VBOX_E_INVALID_VM_STATE", "SessionMachine",
+				"The machine is not mutable (state is %s)", strings.Title(vm.State))
+		}
+	}
+
+	switch mediumArg {
+	case "none":
+		if ctrl.Attachments[slot] == nil {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Machine",
+				"No storage device attached to device slot %s on port %s of controller '%s'", device, port, ctrl.Name)
+		}
+		delete(ctrl.Attachments, slot)
+		return vboxOK("")
+
+	case "emptydrive":
+		ctrl.Attachments[slot] = &fakeAttachment{Type: typ}
+		return vboxOK("")
+	}
+
+	kind := mediumKindHDD
+	if typ == "dvddrive" {
+		kind = mediumKindDVD
+	}
+
+	medium, errRes := vb.lookupMedium(kind, mediumArg)
+	if errRes != nil {
+		return *errRes
+	}
+
+	if kind == mediumKindHDD && args.Get("--mtype") != "shareable" {
+		for _, user := range vb.mediumUsers(medium.UUID) {
+			if user != vm {
+				return vboxErr("VBOX_E_OBJECT_IN_USE", "Medium",
+					"Medium '%s' is already attached to virtual machine '%s'", medium.Path, user.Name)
+			}
+		}
+	}
+
+	hotPluggable := args.Get("--hotpluggable") == "on"
+
+	if hotPluggable && ctrl.Bus != "SATA" {
+		return vboxErr("E_INVALIDARG", "Machine",
+			"Controller '%s' does not support hot plugging", ctrl.Name)
+	}
+
+	ctrl.Attachments[slot] = &fakeAttachment{
+		Type:          typ,
+		MediumUUID:    medium.UUID,
+		NonRotational: args.Get("--nonrotational") == "on",
+		Discard:       args.Get("--discard") == "on",
+		HotPluggable:  hotPluggable,
+	}
+
+	return vboxOK("")
+}
@@ -0,0 +1,308 @@
+package fakes
+
+import (
+	"fmt"
+	"strings"
+)
+
+type fakeHostOnly struct {
+	Name        string
+	GUID        string
+	DHCP        bool
+	IPAddress   string
+	NetworkMask string
+
+	// Only used by host-only networks (VirtualBox 7.x on macOS)
+	LowerIP  string
+	UpperIP  string
+	Disabled bool
+}
+
+type fakeNATNetwork struct {
+	Name    string
+	Network string
+	DHCP    bool
+	Enabled bool
+}
+
+type fakeBridgedIf struct {
+	Name        string
+	IPAddress   string
+	NetworkMask string
+}
+
+func (vb *VirtualBox) findHostOnly(name string) *fakeHostOnly {
+	for _, net := range vb.hostOnlys {
+		if net.Name == name {
+			return net
+		}
+	}
+	return nil
+}
+
+func (vb *VirtualBox) removeHostOnly(name string) bool {
+	for i, net := range vb.hostOnlys {
+		if net.Name == name {
+			vb.hostOnlys = append(vb.hostOnlys[:i], vb.hostOnlys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (vb *VirtualBox) hostOnlyIfCmd(args vboxManageArgs) vboxManageResult {
+	if vb.usesHostOnlyNets() {
+		return vboxErr("NS_ERROR_FAILURE", "HostNetworkInterface",
+			"Failed to create the host-only adapter\nVBoxManage: error: VBoxNetAdpCtl: Error while adding new interface: "+
+				"VBoxNetAdpCtl: ioctl failed for /dev/vboxnetctl: Inappropriate ioctl for device")
+	}
+
+	switch args.Positional(0) {
+	case "create":
+		var name string
+		for i := 0; ; i++ {
+			name = fmt.Sprintf("vboxnet%d", i)
+			if vb.findHostOnly(name) == nil {
+				break
+			}
+		}
+
+		idx := len(vb.hostOnlys)
+
+		vb.hostOnlys = append(vb.hostOnlys, &fakeHostOnly{
+			Name:        name,
+			GUID:        fmt.Sprintf("786f6276-656e-%d074-8000-0a00270000%02x", idx, idx),
+			IPAddress:   fmt.Sprintf("192.168.%d.1", 56+idx),
+			NetworkMask: "255.255.255.0",
+		})
+
+		return vboxOK(fmt.Sprintf("%s\nInterface '%s' was successfully created\n", vboxManageProgress, name))
+
+	case "ipconfig":
+		net := vb.findHostOnly(args.Positional(1))
+		if net == nil {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Host",
+				"Could not find interface '%s'", args.Positional(1))
+		}
+
+		if args.Has("--dhcp") {
+			net.DHCP = true
+		} else {
+			net.DHCP = false
+			net.IPAddress = args.Get("--ip")
+			net.NetworkMask = args.Get("--netmask")
+		}
+
+		return vboxOK("")
+
+	case "remove":
+		if !vb.removeHostOnly(args.Positional(1)) {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "Host",
+				"Could not find interface '%s'", args.Positional(1))
+		}
+
+		return vboxOK(vboxManageProgress + "\n")
+
+	default:
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(0))
+	}
+}
+
+func (vb *VirtualBox) hostOnlyNetCmd(args vboxManageArgs) vboxManageResult {
+	if !vb.isV7() {
+		return vboxSyntaxErr("Invalid command 'hostonlynet'")
+	}
+
+	name := args.Get("--name")
+	net := vb.findHostOnly(name)
+
+	switch args.Positional(0) {
+	case "add":
+		if net != nil {
+			return vboxErr("E_INVALIDARG", "VirtualBox",
+				"Host-only network '%s' already exists", name)
+		}
+
+		vb.hostOnlys = append(vb.hostOnlys, &fakeHostOnly{
+			Name:        name,
+			GUID:        fmt.Sprintf("%08x-0000-4000-8000-000000000000", len(vb.hostOnlys)+1),
+			NetworkMask: args.Get("--netmask"),
+			LowerIP:     args.Get("--lower-ip"),
+			UpperIP:     args.Get("--upper-ip"),
+			Disabled:    args.Has("--disable"),
+		})
+
+		return vboxOK("")
+
+	case "modify":
+		if net == nil {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "VirtualBox",
+				"Could not find host-only network '%s'", name)
+		}
+
+		if args.Has("--enable") {
+			net.Disabled = false
+		}
+		if args.Has("--disable") {
+			net.Disabled = true
+		}
+
+		return vboxOK("")
+
+	case "remove":
+		if !vb.removeHostOnly(name) {
+			return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "VirtualBox",
+				"Could not find host-only network '%s'", name)
+		}
+
+		return vboxOK("")
+
+	default:
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(0))
+	}
+}
+
+func (vb *VirtualBox) natNetworkCmd(args vboxManageArgs) vboxManageResult {
+	name := args.Get("--netname")
+
+	switch args.Positional(0) {
+	case "add":
+		for _, net := range vb.natNets {
+			if net.Name == name {
+				return vboxErr("E_INVALIDARG", "VirtualBox",
+					"NATNetwork server already exists")
+			}
+		}
+
+		vb.natNets = append(vb.natNets, &fakeNATNetwork{
+			Name:    name,
+			Network: args.Get("--network"),
+			DHCP:    args.Get("--dhcp") == "on",
+			Enabled: !args.Has("--disable"),
+		})
+
+		return vboxOK("")
+
+	case "remove":
+		for i, net := range vb.natNets {
+			if net.Name == name {
+				vb.natNets = append(vb.natNets[:i], vb.natNets[i+1:]...)
+				return vboxOK("")
+			}
+		}
+
+		return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "VirtualBox",
+			"Could not find NAT network '%s'", name)
+
+	default:
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(0))
+	}
+}
+
+func (vb *VirtualBox) listHostOnlyIfs() string {
+	if vb.usesHostOnlyNets() {
+		return ""
+	}
+
+	var chunks []string
+
+	for i, net := range vb.hostOnlys {
+		chunks = append(chunks, fmt.Sprintf(`Name:            %s
+GUID:            %s
+DHCP:            %s
+IPAddress:       %s
+NetworkMask:     %s
+IPV6Address:
+IPV6NetworkMaskPrefixLength: 0
+HardwareAddress: 0a:00:27:00:00:%02x
+MediumType:      Ethernet
+Wireless:        No
+Status:          Up
+VBoxNetworkName: HostInterfaceNetworking-%s
+`, net.Name, net.GUID, yesNo(net.DHCP, "Enabled", "Disabled"), net.IPAddress, net.NetworkMask, i, net.Name))
+	}
+
+	return strings.Join(chunks, "\n")
+}
+
+func (vb *VirtualBox) listHostOnlyNets() string {
+	var chunks []string
+
+	for _, net := range vb.hostOnlys {
+		chunks = append(chunks, fmt.Sprintf(`Name:            %s
+GUID:            %s
+
+State:           %s
+NetworkMask:     %s
+LowerIP:         %s
+UpperIP:         %s
+VBoxNetworkName: hostonly-%s
+`, net.Name, net.GUID, yesNo(!net.Disabled, "Enabled", "Disabled"), net.NetworkMask, net.LowerIP, net.UpperIP, net.Name))
+	}
+
+	return strings.Join(chunks, "\n")
+}
+
+func (vb *VirtualBox) listNATNetworks() string {
+	var chunks []string
+
+	for _, net := range vb.natNets {
+		gateway := strings.TrimSuffix(strings.SplitN(net.Network, "/", 2)[0], "0") + "1"
+
+		if vb.isV7() {
+			chunks = append(chunks, fmt.Sprintf(`Name:         %s
+Network:      %s
+Gateway:      %s
+DHCP Server:  %s
+IPv6:         No
+IPv6 Prefix:  fd17:625c:f037:2::/64
+IPv6 Default: No
+Enabled:      %s
+loopback mappings (ipv4)
+        127.0.0.1=2
+`, net.Name, net.Network, gateway, yesNo(net.DHCP, "Yes", "No"), yesNo(net.Enabled, "Yes", "No")))
+		} else {
+			chunks = append(chunks, fmt.Sprintf(`NetworkName:    %s
+IP:             %s
+Network:        %s
+IPv6 Enabled:   No
+IPv6 Prefix:    fd17:625c:f037:2::/64
+DHCP Enabled:   %s
+Enabled:        %s
+loopback mappings (ipv4)
+        127.0.0.1=2
+`, net.Name, gateway, net.Network, yesNo(net.DHCP, "Yes", "No"), yesNo(net.Enabled, "Yes", "No")))
+		}
+	}
+
+	return strings.Join(chunks, "\n")
+}
+
+func (vb *VirtualBox) listBridgedIfs() string {
+	var chunks []string
+
+	for i, net := range vb.bridgedIfs {
+		chunks = append(chunks, fmt.Sprintf(`Name:            %s
+GUID:            %08x-0000-4000-8000-a45e60000000
+DHCP:            Disabled
+IPAddress:       %s
+NetworkMask:     %s
+IPV6Address:
+IPV6NetworkMaskPrefixLength: 0
+HardwareAddress: a4:5e:60:00:00:%02x
+MediumType:      Ethernet
+Wireless:        No
+Status:          Up
+VBoxNetworkName: HostInterfaceNetworking-%s
+`, net.Name, i+1, net.IPAddress, net.NetworkMask, i, net.Name))
+	}
+
+	return strings.Join(chunks, "\n")
+}
+
+func yesNo(b bool, yes, no string) string {
+	if b {
+		return yes
+	}
+	return no
+}
@@ -0,0 +1,275 @@
+package fakes
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+// Runner executes commands against simulated VirtualBox host.
+// Similar to LocalRunner it returns an error for non-zero exit statuses.
+type Runner struct {
+	vb *VirtualBox
+}
+
+var _ driver.RawRunner = Runner{}
+
+func (r Runner) HomeDir() (string, error) { return r.vb.opts.HomeDir, nil }
+
+func (r Runner) Execute(path string, args ...string) (string, int, error) {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	var stdout, stderr string
+	var status int
+
+	switch filepath.Base(path) {
+	case filepath.Base(r.vb.opts.BinPath):
+		r.vb.invocations = append(r.vb.invocations, append([]string{}, args...))
+		stdout, stderr, status = r.vb.vboxManage(args)
+	case "sha1sum":
+		stdout, stderr, status = r.sha1sum(args)
+	case "df":
+		stdout, stderr, status = r.df(args)
+	default:
+		stderr, status = fmt.Sprintf("sh: 1: %s: not found\n", path), 127
+	}
+
+	output := stdout + "\n" + stderr
+
+	if status != 0 {
+		cmd := strings.Join(append([]string{path}, args...), " ")
+		return output, status, boshsys.NewExecError(cmd, stdout, stderr)
+	}
+
+	return output, 0, nil
+}
+
+// ExecuteContext blocks hung VBoxManage subcommands (see HangOn) until ctx is done
+func (r Runner) ExecuteContext(ctx context.Context, path string, args ...string) (string, int, error) {
+	if filepath.Base(path) == filepath.Base(r.vb.opts.BinPath) && len(args) > 0 && r.vb.hangs(args[0]) {
+		r.vb.mu.Lock()
+		r.vb.invocations = append(r.vb.invocations, append([]string{}, args...))
+		r.vb.mu.Unlock()
+
+		<-ctx.Done()
+
+		return "\n", -1, bosherr.WrapErrorf(ctx.Err(), "Running '%s'", path)
+	}
+
+	return r.Execute(path, args...)
+}
+
+// Upload moves source file like LocalRunner does
+func (r Runner) Upload(srcPath, dstPath string) error {
+	contents, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return bosherr.WrapError(err, "Opening source file for upload")
+	}
+
+	err = r.Put(dstPath, contents)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(srcPath)
+}
+
+func (r Runner) Put(path string, contents []byte) error {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	r.vb.writeFile(path, append([]byte{}, contents...))
+
+	return nil
+}
+
+func (r Runner) Get(path string) ([]byte, error) {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	contents, found := r.vb.files[filepath.Clean(path)]
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	return append([]byte{}, contents...), nil
+}
+
+func (r Runner) Append(path string, contents []byte) error {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	existing := r.vb.files[filepath.Clean(path)]
+	r.vb.writeFile(path, append(append([]byte{}, existing...), contents...))
+
+	return nil
+}
+
+func (r Runner) MkdirAll(path string) error {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	r.vb.mkdirAll(path)
+
+	return nil
+}
+
+func (r Runner) List(path string) ([]string, error) {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	names, found := r.vb.list(path)
+	if !found {
+		return nil, &os.PathError{Op: "list", Path: path, Err: os.ErrNotExist}
+	}
+
+	var visible []string
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, ".") {
+			visible = append(visible, name)
+		}
+	}
+
+	return visible, nil
+}
+
+func (r Runner) Stat(path string) (os.FileInfo, error) {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	path = filepath.Clean(path)
+
+	if contents, found := r.vb.files[path]; found {
+		return fileInfo{name: filepath.Base(path), size: int64(len(contents))}, nil
+	}
+
+	if r.vb.dirs[path] {
+		return fileInfo{name: filepath.Base(path), dir: true}, nil
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+}
+
+func (r Runner) RemoveAll(path string) error {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	r.vb.removeAll(path)
+
+	return nil
+}
+
+func (r Runner) Rename(oldPath, newPath string) error {
+	r.vb.mu.Lock()
+	defer r.vb.mu.Unlock()
+
+	if !r.vb.rename(oldPath, newPath) {
+		return bosherr.Errorf("Renaming '%s' to '%s': no such file or directory", oldPath, newPath)
+	}
+
+	return nil
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.dir }
+func (i fileInfo) Sys() interface{}   { return nil }
+
+func (i fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// Lock only coordinates runners of the same simulator and waits without timeout
+func (r Runner) Lock(path string, _ time.Duration) (driver.Lock, error) {
+	mu := r.vb.lockMutex(filepath.Clean(path))
+	mu.Lock()
+
+	r.vb.recordLockEvent("lock " + path)
+
+	return fakeLock{r.vb, mu, path}, nil
+}
+
+type fakeLock struct {
+	vb   *VirtualBox
+	mu   *sync.Mutex
+	path string
+}
+
+func (l fakeLock) Unlock() error {
+	l.vb.recordLockEvent("unlock " + l.path)
+	l.mu.Unlock()
+	return nil
+}
+
+func (r Runner) sha1sum(args []string) (string, string, int) {
+	var stdout, stderr string
+	var status int
+
+	for _, arg := range args {
+		contents, found := r.vb.files[filepath.Clean(arg)]
+		if !found {
+			stderr += fmt.Sprintf("sha1sum: %s: No such file or directory\n", arg)
+			status = 1
+			continue
+		}
+
+		stdout += fmt.Sprintf("%x  %s\n", sha1.Sum(contents), arg)
+	}
+
+	return stdout, stderr, status
+}
+
+// df reports the same free space for all paths (in 1K blocks as with -P -k)
+func (r Runner) df(args []string) (string, string, int) {
+	path := args[len(args)-1]
+
+	if !r.vb.dirs[filepath.Clean(path)] {
+		return "", fmt.Sprintf("df: %s: No such file or directory\n", path), 1
+	}
+
+	freeKB := r.vb.opts.FreeDiskMB * 1024
+
+	return fmt.Sprintf(`Filesystem     1024-blocks      Used Available Capacity Mounted on
+/dev/sda1        %d %d %d      50%% /
+`, 2*freeKB, freeKB, freeKB), "", 0
+}
+
+// Retrier does not sleep between attempts to keep tests fast
+type Retrier struct{}
+
+var _ driver.Retrier = Retrier{}
+
+func (r Retrier) Policy() driver.RetryPolicy { return driver.RetryPolicy{MaxAttempts: 3} }
+
+func (r Retrier) Retry(actionFunc func() error) error {
+	return r.RetryComplex(actionFunc, r.Policy())
+}
+
+func (Retrier) RetryComplex(actionFunc func() error, policy driver.RetryPolicy) error {
+	retrier := driver.NewRetrierImpl(policy, boshlog.NewLogger(boshlog.LevelNone))
+	return retrier.RetryComplex(actionFunc, driver.RetryPolicy{MaxAttempts: policy.MaxAttempts})
+}
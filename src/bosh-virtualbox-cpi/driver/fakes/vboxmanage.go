@@ -0,0 +1,264 @@
+package fakes
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	vboxManageProgress = "0%...10%...20%...30%...40%...50%...60%...70%...80%...90%...100%"
+)
+
+var (
+	vboxManageResultCodes = map[string]string{
+		"VBOX_E_OBJECT_NOT_FOUND":     "0x80bb0001",
+		"VBOX_E_INVALID_VM_STATE":     "0x80bb0002",
+		"VBOX_E_FILE_ERROR":           "0x80bb0004",
+		"VBOX_E_INVALID_OBJECT_STATE": "0x80bb0007",
+		"VBOX_E_NOT_SUPPORTED":        "0x80bb0009",
+		"VBOX_E_OBJECT_IN_USE":        "0x80bb000c",
+		"E_ACCESSDENIED":              "0x80070005",
+		"E_INVALIDARG":                "0x80070057",
+		"NS_ERROR_FAILURE":            "0x80004005",
+	}
+
+	// Flags that do not take a value
+	vboxManageBoolFlags = map[string]bool{
+		"--register":        true,
+		"--live":            true,
+		"--delete":          true,
+		"--disable":         true,
+		"--enable":          true,
+		"--machinereadable": true,
+		"--dhcp":            true,
+		"--long":            true,
+		"-l":                true,
+		"--transient":       true,
+		"--automount":       true,
+	}
+)
+
+// vboxManageResult holds stdout, stderr and exit status
+type vboxManageResult struct {
+	stdout string
+	stderr string
+	status int
+}
+
+func vboxOK(stdout string) vboxManageResult {
+	return vboxManageResult{stdout: stdout}
+}
+
+// vboxErr produces error output in the same format as VBoxManage does
+func vboxErr(code, component, msg string, args ...interface{}) vboxManageResult {
+	stderr := fmt.Sprintf("VBoxManage: error: "+msg+"\n", args...)
+	if len(code) > 0 {
+		stderr += fmt.Sprintf("VBoxManage: error: Details: code %s (%s), component %s, interface I%s\n",
+			code, vboxManageResultCodes[code], component+"Wrap", component)
+	}
+	return vboxManageResult{stderr: stderr, status: 1}
+}
+
+func vboxSyntaxErr(msg string, args ...interface{}) vboxManageResult {
+	return vboxManageResult{
+		stderr: fmt.Sprintf("Usage:\n\nVBoxManage: error: "+msg+"\n", args...),
+		status: 2,
+	}
+}
+
+type vboxManageArgs struct {
+	positional []string
+	flags      map[string]string
+	order      []string
+}
+
+func newVBoxManageArgs(args []string) vboxManageArgs {
+	parsed := vboxManageArgs{flags: map[string]string{}}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if !strings.HasPrefix(arg, "-") {
+			parsed.positional = append(parsed.positional, arg)
+			continue
+		}
+
+		var val string
+
+		if pieces := strings.SplitN(arg, "=", 2); len(pieces) == 2 {
+			arg, val = pieces[0], pieces[1]
+		} else if !vboxManageBoolFlags[arg] && i+1 < len(args) {
+			i++
+			val = args[i]
+		}
+
+		parsed.flags[arg] = val
+		parsed.order = append(parsed.order, arg)
+	}
+
+	return parsed
+}
+
+func (a vboxManageArgs) Has(flag string) bool {
+	_, found := a.flags[flag]
+	return found
+}
+
+func (a vboxManageArgs) Get(flag string) string { return a.flags[flag] }
+
+func (a vboxManageArgs) Positional(i int) string {
+	if i < len(a.positional) {
+		return a.positional[i]
+	}
+	return ""
+}
+
+func (vb *VirtualBox) vboxManage(args []string) (string, string, int) {
+	var result vboxManageResult
+
+	if len(args) == 0 {
+		result = vboxSyntaxErr("No command specified")
+		return result.stdout, result.stderr, result.status
+	}
+
+	if vb.fails(args) {
+		vb.leavePartialOutput(args)
+		result = vboxErr("VBOX_E_FILE_ERROR", "Medium", "Simulated failure of '%s'", strings.Join(args, " "))
+		return result.stdout, result.stderr, result.status
+	}
+
+	rest := newVBoxManageArgs(args[1:])
+
+	switch args[0] {
+	case "--version":
+		result = vboxOK(vb.fullVersion() + "\n")
+	case "list":
+		result = vb.listCmd(rest)
+	case "import":
+		result = vb.importCmd(rest)
+	case "clonevm":
+		result = vb.cloneVMCmd(rest)
+	case "modifyvm":
+		result = vb.modifyVMCmd(rest)
+	case "showvminfo":
+		result = vb.showVMInfoCmd(rest)
+	case "snapshot":
+		result = vb.snapshotCmd(rest)
+	case "storageattach":
+		result = vb.storageAttachCmd(rest)
+	case "startvm":
+		result = vb.startVMCmd(rest)
+	case "controlvm":
+		result = vb.controlVMCmd(rest)
+	case "discardstate":
+		result = vb.discardStateCmd(rest)
+	case "unregistervm":
+		result = vb.unregisterVMCmd(rest)
+	case "setextradata":
+		result = vb.setExtraDataCmd(rest)
+	case "getextradata":
+		result = vb.getExtraDataCmd(rest)
+	case "sharedfolder":
+		result = vb.sharedFolderCmd(rest)
+	case "createhd":
+		result = vb.createMediumCmd(rest)
+	case "createmedium":
+		result = vb.createMediumCmd(vb.withoutMediumType(rest))
+	case "showmediuminfo", "showhdinfo":
+		result = vb.showMediumInfoCmd(vb.withoutMediumType(rest))
+	case "clonemedium", "clonehd":
+		result = vb.cloneMediumCmd(vb.withoutMediumType(rest))
+	case "closemedium":
+		result = vb.closeMediumCmd(vb.withoutMediumType(rest))
+	case "modifymedium", "modifyhd":
+		result = vb.modifyMediumCmd(vb.withoutMediumType(rest))
+	case "hostonlyif":
+		result = vb.hostOnlyIfCmd(rest)
+	case "hostonlynet":
+		result = vb.hostOnlyNetCmd(rest)
+	case "natnetwork":
+		result = vb.natNetworkCmd(rest)
+	default:
+		result = vboxSyntaxErr("Invalid command '%s'", args[0])
+	}
+
+	return result.stdout, result.stderr, result.status
+}
+
+func (vb *VirtualBox) fullVersion() string {
+	if vb.isV7() {
+		return vb.opts.Version + "r158379"
+	}
+	return vb.opts.Version + "r153438"
+}
+
+// withoutMediumType drops optional leading 'disk', 'dvd' or 'floppy' argument
+// leavePartialOutput simulates VBoxManage giving up half way through
+// writing a cloned medium: file is left behind but medium is not registered
+func (vb *VirtualBox) leavePartialOutput(args []string) {
+	switch args[0] {
+	case "clonemedium", "clonehd":
+		dstPath := vb.withoutMediumType(newVBoxManageArgs(args[1:])).Positional(1)
+		if len(dstPath) > 0 && vb.dirs[filepath.Dir(filepath.Clean(dstPath))] {
+			vb.writeFile(dstPath, []byte("partial"))
+		}
+	}
+}
+
+func (vb *VirtualBox) withoutMediumType(args vboxManageArgs) vboxManageArgs {
+	switch args.Positional(0) {
+	case "disk", "dvd", "floppy":
+		args.positional = args.positional[1:]
+	}
+	return args
+}
+
+func (vb *VirtualBox) listCmd(args vboxManageArgs) vboxManageResult {
+	switch args.Positional(0) {
+	case "vms":
+		return vboxOK(vb.listVMs(false))
+	case "runningvms":
+		return vboxOK(vb.listVMs(true))
+	case "hdds":
+		return vboxOK(vb.listHDDs())
+	case "hostonlyifs":
+		return vboxOK(vb.listHostOnlyIfs())
+	case "hostonlynets":
+		if !vb.isV7() {
+			return vboxSyntaxErr("Unknown subcommand 'hostonlynets'")
+		}
+		return vboxOK(vb.listHostOnlyNets())
+	case "natnetworks":
+		return vboxOK(vb.listNATNetworks())
+	case "bridgedifs":
+		return vboxOK(vb.listBridgedIfs())
+	case "hostinfo":
+		return vboxOK(vb.listHostInfo())
+	default:
+		return vboxSyntaxErr("Unknown subcommand '%s'", args.Positional(0))
+	}
+}
+
+func (vb *VirtualBox) listHostInfo() string {
+	osName := "Linux"
+	osVersion := "5.15.0-76-generic"
+
+	if vb.opts.HostOS == "darwin" {
+		osName = "Darwin"
+		osVersion = "22.5.0"
+	}
+
+	return fmt.Sprintf(`Host Information:
+
+Host time: 2023-06-08T15:07:15.000000000Z
+Processor online count: 8
+Processor count: 8
+Processor online core count: 8
+Processor core count: 8
+Memory size: 16384 MByte
+Memory available: 8192 MByte
+Operating system: %s
+Operating system version: %s
+`, osName, osVersion)
+}
@@ -0,0 +1,396 @@
+package fakes
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+type VirtualBoxOpts struct {
+	Version string // e.g. 6.1.38, 7.0.10
+	HostOS  string // e.g. linux, darwin
+
+	BinPath string // defaults to VBoxManage
+	HomeDir string // defaults to /home/vcap
+
+	FreeDiskMB int // reported by df; defaults to 100 GB
+}
+
+// VirtualBox simulates VirtualBox host (VBoxManage and host's file system)
+// keeping all VMs, media, networks and files in memory.
+type VirtualBox struct {
+	opts VirtualBoxOpts
+
+	vms        []*fakeVM
+	media      []*fakeMedium
+	hostOnlys  []*fakeHostOnly
+	natNets    []*fakeNATNetwork
+	bridgedIfs []*fakeBridgedIf
+
+	files map[string][]byte
+	dirs  map[string]bool
+
+	invocations [][]string
+	hung        map[string]bool
+	failing     []fakeFailure
+	locks       map[string]*sync.Mutex
+	lockEvents  []string
+	lastID      int
+
+	mu sync.Mutex
+}
+
+func NewVirtualBox(opts VirtualBoxOpts) *VirtualBox {
+	if len(opts.Version) == 0 {
+		opts.Version = "6.1.38"
+	}
+	if len(opts.HostOS) == 0 {
+		opts.HostOS = "linux"
+	}
+	if len(opts.BinPath) == 0 {
+		opts.BinPath = "VBoxManage"
+	}
+	if len(opts.HomeDir) == 0 {
+		opts.HomeDir = "/home/vcap"
+	}
+	if opts.FreeDiskMB == 0 {
+		opts.FreeDiskMB = 100 * 1024
+	}
+
+	vb := &VirtualBox{
+		opts:  opts,
+		files: map[string][]byte{},
+		dirs:  map[string]bool{"/": true},
+	}
+
+	vb.mkdirAll(opts.HomeDir)
+
+	// Devices of loaded kernel modules
+	vb.writeFile("/dev/vboxdrv", nil)
+	vb.writeFile("/dev/vboxnetctl", nil)
+
+	vb.bridgedIfs = append(vb.bridgedIfs, &fakeBridgedIf{
+		Name:        "en0",
+		IPAddress:   "192.168.1.10",
+		NetworkMask: "255.255.255.0",
+	})
+
+	return vb
+}
+
+// Runner returns runner that executes VBoxManage and basic file commands against the simulator
+func (vb *VirtualBox) Runner() Runner { return Runner{vb} }
+
+// Driver returns real ExecDriver that talks to the simulator
+func (vb *VirtualBox) Driver(logger boshlog.Logger) driver.Driver {
+	return driver.NewExecDriver(vb.Runner(), Retrier{}, vb.opts.BinPath, driver.NoopAuditLog{}, logger)
+}
+
+func (vb *VirtualBox) BinPath() string { return vb.opts.BinPath }
+
+// Invocations returns all VBoxManage invocations (without binary path)
+func (vb *VirtualBox) Invocations() [][]string {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	return append([][]string{}, vb.invocations...)
+}
+
+// VMNames returns names of all registered VMs
+func (vb *VirtualBox) VMNames() []string {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	var names []string
+	for _, vm := range vb.vms {
+		names = append(names, vm.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (vb *VirtualBox) VMState(nameOrID string) (string, bool) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	for _, vm := range vb.vms {
+		if vm.UUID == nameOrID || vm.Name == nameOrID {
+			return vm.State, true
+		}
+	}
+	return "", false
+}
+
+// SetVMState allows to simulate VMs that ended up in states like aborted or saved
+func (vb *VirtualBox) SetVMState(nameOrID, state string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vm := vb.mustFindVM(nameOrID)
+	vm.State = state
+}
+
+// MarkInaccessible simulates VM whose settings file cannot be read
+func (vb *VirtualBox) MarkInaccessible(nameOrID string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vm := vb.mustFindVM(nameOrID)
+	vm.Inaccessible = true
+}
+
+// IgnoreACPI simulates guest that does not react to ACPI power button
+func (vb *VirtualBox) IgnoreACPI(nameOrID string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vm := vb.mustFindVM(nameOrID)
+	vm.IgnoreACPI = true
+}
+
+// StopSlowly simulates guest that reacts to ACPI power button by stopping
+// and is reported as such by given number of subsequent showvminfo calls
+func (vb *VirtualBox) StopSlowly(nameOrID string, polls int) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vm := vb.mustFindVM(nameOrID)
+	vm.stoppingPolls = polls
+}
+
+// LockEvents returns acquired and released locks in order, e.g. 'lock /path' and 'unlock /path'
+func (vb *VirtualBox) LockEvents() []string {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	return append([]string{}, vb.lockEvents...)
+}
+
+func (vb *VirtualBox) lockMutex(path string) *sync.Mutex {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	if vb.locks == nil {
+		vb.locks = map[string]*sync.Mutex{}
+	}
+	if vb.locks[path] == nil {
+		vb.locks[path] = &sync.Mutex{}
+	}
+	return vb.locks[path]
+}
+
+func (vb *VirtualBox) recordLockEvent(event string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vb.lockEvents = append(vb.lockEvents, event)
+}
+
+// HangOn simulates VBoxManage subcommand that never finishes
+func (vb *VirtualBox) HangOn(subcommand string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	if vb.hung == nil {
+		vb.hung = map[string]bool{}
+	}
+	vb.hung[subcommand] = true
+}
+
+func (vb *VirtualBox) hangs(subcommand string) bool {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	return vb.hung[subcommand]
+}
+
+type fakeFailure struct {
+	subcommand string
+	arg        string
+}
+
+// FailOn simulates VBoxManage subcommand failing with a file error
+// whenever it is invoked with given argument (any arguments if empty)
+func (vb *VirtualBox) FailOn(subcommand, arg string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vb.failing = append(vb.failing, fakeFailure{subcommand, arg})
+}
+
+func (vb *VirtualBox) fails(args []string) bool {
+	for _, f := range vb.failing {
+		if args[0] != f.subcommand {
+			continue
+		}
+		if len(f.arg) == 0 {
+			return true
+		}
+		for _, arg := range args[1:] {
+			if arg == f.arg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Unregister removes VM from registry without deleting any of its files
+func (vb *VirtualBox) Unregister(nameOrID string) {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	vm := vb.mustFindVM(nameOrID)
+	vb.removeVM(vm)
+}
+
+// MediumPaths returns locations of all registered hard disks
+func (vb *VirtualBox) MediumPaths() []string {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	var paths []string
+	for _, m := range vb.media {
+		if m.Kind == mediumKindHDD {
+			paths = append(paths, m.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (vb *VirtualBox) FileExists(path string) bool {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+
+	path = filepath.Clean(path)
+	_, found := vb.files[path]
+	return found || vb.dirs[path]
+}
+
+func (vb *VirtualBox) majorVersion() string {
+	return strings.SplitN(vb.opts.Version, ".", 2)[0]
+}
+
+func (vb *VirtualBox) isV7() bool { return vb.majorVersion() == "7" }
+
+func (vb *VirtualBox) usesHostOnlyNets() bool {
+	return vb.isV7() && vb.opts.HostOS == "darwin"
+}
+
+func (vb *VirtualBox) newUUID() string {
+	vb.lastID++
+	return fmt.Sprintf("%08x-0000-4000-8000-%012x", vb.lastID, vb.lastID)
+}
+
+func (vb *VirtualBox) machineFolder(name string) string {
+	return filepath.Join(vb.opts.HomeDir, "VirtualBox VMs", name)
+}
+
+func (vb *VirtualBox) mkdirAll(path string) {
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		vb.dirs[path] = true
+		if path == "/" || path == "." {
+			return
+		}
+	}
+}
+
+func (vb *VirtualBox) writeFile(path string, contents []byte) {
+	path = filepath.Clean(path)
+	vb.mkdirAll(filepath.Dir(path))
+	vb.files[path] = contents
+}
+
+func (vb *VirtualBox) removeAll(path string) {
+	path = filepath.Clean(path)
+	prefix := path + "/"
+
+	for p := range vb.files {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(vb.files, p)
+		}
+	}
+
+	for p := range vb.dirs {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(vb.dirs, p)
+		}
+	}
+}
+
+// rename moves file or directory with all its contents replacing newPath
+func (vb *VirtualBox) rename(oldPath, newPath string) bool {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+
+	_, isFile := vb.files[oldPath]
+	if !isFile && !vb.dirs[oldPath] {
+		return false
+	}
+
+	vb.removeAll(newPath)
+	vb.mkdirAll(filepath.Dir(newPath))
+
+	prefix := oldPath + "/"
+	movedFiles := map[string][]byte{}
+	movedDirs := map[string]bool{}
+
+	for p, contents := range vb.files {
+		if p == oldPath || strings.HasPrefix(p, prefix) {
+			delete(vb.files, p)
+			movedFiles[newPath+strings.TrimPrefix(p, oldPath)] = contents
+		}
+	}
+
+	for p := range vb.dirs {
+		if p == oldPath || strings.HasPrefix(p, prefix) {
+			delete(vb.dirs, p)
+			movedDirs[newPath+strings.TrimPrefix(p, oldPath)] = true
+		}
+	}
+
+	for p, contents := range movedFiles {
+		vb.files[p] = contents
+	}
+
+	for p := range movedDirs {
+		vb.dirs[p] = true
+	}
+
+	return true
+}
+
+func (vb *VirtualBox) list(path string) ([]string, bool) {
+	path = filepath.Clean(path)
+	if !vb.dirs[path] {
+		return nil, false
+	}
+
+	names := map[string]bool{}
+
+	for p := range vb.files {
+		if filepath.Dir(p) == path {
+			names[filepath.Base(p)] = true
+		}
+	}
+
+	for p := range vb.dirs {
+		if p != path && filepath.Dir(p) == path {
+			names[filepath.Base(p)] = true
+		}
+	}
+
+	var result []string
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result, true
+}
@@ -0,0 +1,758 @@
+package fakes
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type fakeVM struct {
+	Name   string
+	UUID   string
+	OSType string
+
+	State        string // e.g. poweroff, running, paused, saved, aborted
+	SessionType  string
+	Inaccessible bool
+	IgnoreACPI   bool
+
+	stoppingPolls int // showvminfo calls reporting 'stopping' after ACPI shutdown
+
+	Settings      map[string]string // e.g. memory, cpus, firmware
+	Controllers   []*fakeController
+	NICs          [8]fakeNIC
+	SharedFolders []fakeSharedFolder
+	ExtraData     map[string]string
+	Snapshots     []*fakeSnapshot
+}
+
+type fakeController struct {
+	Name    string
+	Bus     string // e.g. IDE, SCSI, SATA
+	Type    string // e.g. PIIX4, LsiLogic, IntelAhci
+	Ports   int
+	Devices int
+
+	Attachments map[string]*fakeAttachment // keyed by port-device
+}
+
+type fakeAttachment struct {
+	Type          string // hdd or dvddrive
+	MediumUUID    string // empty for empty drive
+	NonRotational bool
+	Discard       bool
+	HotPluggable  bool
+}
+
+type fakeNIC struct {
+	Type       string // e.g. none, nat, hostonly, hostonlynet, bridged, natnetwork
+	Attachment string // name of the network
+	MAC        string
+}
+
+type fakeSharedFolder struct {
+	Name     string
+	HostPath string
+}
+
+type fakeSnapshot struct {
+	Name string
+	UUID string
+
+	// Media attached at the time snapshot was taken keyed by controller name and port-device
+	Attachments map[string]map[string]fakeAttachment
+}
+
+func newFakeController(bus string, v7 bool) *fakeController {
+	ctrl := &fakeController{Bus: bus, Attachments: map[string]*fakeAttachment{}}
+
+	switch bus {
+	case "IDE":
+		ctrl.Type, ctrl.Ports, ctrl.Devices = "PIIX4", 2, 2
+	case "SCSI":
+		ctrl.Type, ctrl.Ports, ctrl.Devices = "LsiLogic", 16, 1
+	case "SATA":
+		ctrl.Type, ctrl.Ports, ctrl.Devices = "IntelAhci", 30, 1
+	}
+
+	ctrl.Name = bus
+	if v7 {
+		ctrl.Name = bus + " Controller"
+	}
+
+	return ctrl
+}
+
+func (c *fakeController) slot(port, device string) string { return port + "-" + device }
+
+func (c *fakeController) inRange(port, device string) bool {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p >= c.Ports {
+		return false
+	}
+
+	d, err := strconv.Atoi(device)
+	if err != nil || d < 0 || d >= c.Devices {
+		return false
+	}
+
+	return true
+}
+
+func (vm *fakeVM) controller(name string) *fakeController {
+	for _, ctrl := range vm.Controllers {
+		if ctrl.Name == name {
+			return ctrl
+		}
+	}
+	return nil
+}
+
+// isRunning returns true when VM has a process that keeps it locked
+func (vm *fakeVM) isRunning() bool {
+	switch vm.State {
+	case "running", "paused", "stopping", "gurumeditation", "stuck":
+		return true
+	default:
+		return false
+	}
+}
+
+// attachedMediumUUIDs returns media used by current state of the VM
+func (vm *fakeVM) attachedMediumUUIDs(typ string) []string {
+	var uuids []string
+
+	for _, ctrl := range vm.Controllers {
+		for _, att := range ctrl.Attachments {
+			if att.Type == typ && len(att.MediumUUID) > 0 {
+				uuids = append(uuids, att.MediumUUID)
+			}
+		}
+	}
+
+	return uuids
+}
+
+// snapshotMediumUUIDs returns media that are referenced by VM snapshots
+func (vm *fakeVM) snapshotMediumUUIDs() []string {
+	var uuids []string
+
+	for _, snap := range vm.Snapshots {
+		for _, atts := range snap.Attachments {
+			for _, att := range atts {
+				if att.Type == "hdd" && len(att.MediumUUID) > 0 {
+					uuids = append(uuids, att.MediumUUID)
+				}
+			}
+		}
+	}
+
+	return uuids
+}
+
+func (vm *fakeVM) snapshot(nameOrID string) *fakeSnapshot {
+	for _, snap := range vm.Snapshots {
+		if snap.Name == nameOrID || snap.UUID == nameOrID {
+			return snap
+		}
+	}
+	return nil
+}
+
+// findVM finds VM the same way VBoxManage does;
+// inaccessible VMs can only be found by UUID.
+func (vb *VirtualBox) findVM(nameOrID string) *fakeVM {
+	for _, vm := range vb.vms {
+		if vm.UUID == nameOrID || (vm.Name == nameOrID && !vm.Inaccessible) {
+			return vm
+		}
+	}
+	return nil
+}
+
+func (vb *VirtualBox) mustFindVM(nameOrID string) *fakeVM {
+	for _, vm := range vb.vms {
+		if vm.UUID == nameOrID || vm.Name == nameOrID {
+			return vm
+		}
+	}
+	panic(fmt.Sprintf("Expected VM '%s' to exist", nameOrID))
+}
+
+func (vb *VirtualBox) removeVM(vm *fakeVM) {
+	for i, v := range vb.vms {
+		if v == vm {
+			vb.vms = append(vb.vms[:i], vb.vms[i+1:]...)
+			return
+		}
+	}
+}
+
+func (vb *VirtualBox) newVM(name, uuid string) *fakeVM {
+	if len(uuid) == 0 {
+		uuid = vb.newUUID()
+	}
+
+	vm := &fakeVM{
+		Name:   name,
+		UUID:   uuid,
+		OSType: "Ubuntu_64",
+		State:  "poweroff",
+		Settings: map[string]string{
+			"memory":           "512",
+			"cpus":             "1",
+			"firmware":         "BIOS",
+			"paravirtprovider": "default",
+			"audio":            "none",
+		},
+		ExtraData: map[string]string{},
+	}
+
+	for i := range vm.NICs {
+		vm.NICs[i].Type = "none"
+	}
+
+	vb.vms = append(vb.vms, vm)
+	vb.mkdirAll(vb.machineFolder(name))
+
+	return vm
+}
+
+func (vb *VirtualBox) vmNotFound(nameOrID string) vboxManageResult {
+	return vboxErr("VBOX_E_OBJECT_NOT_FOUND", "VirtualBox",
+		"Could not find a registered machine named '%s'", nameOrID)
+}
+
+// lookupVM finds VM that can be modified by a command
+func (vb *VirtualBox) lookupVM(nameOrID string) (*fakeVM, *vboxManageResult) {
+	vm := vb.findVM(nameOrID)
+	if vm == nil {
+		res := vb.vmNotFound(nameOrID)
+		return nil, &res
+	}
+
+	if vm.Inaccessible {
+		res := vboxErr("E_ACCESSDENIED", "SessionMachine", "The object is not ready")
+		return nil, &res
+	}
+
+	return vm, nil
+}
+
+func (vb *VirtualBox) listVMs(onlyRunning bool) string {
+	var out string
+
+	for _, vm := range vb.vms {
+		if onlyRunning && !vm.isRunning() {
+			continue
+		}
+
+		name := vm.Name
+		if vm.Inaccessible {
+			name = "<inaccessible>"
+		}
+
+		out += fmt.Sprintf("\"%s\" {%s}\n", name, vm.UUID)
+	}
+
+	return out
+}
+
+func (vb *VirtualBox) modifyVMCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if vm.isRunning() {
+		return vboxErr("VBOX_E_INVALID_VM_STATE", "SessionMachine",
+			"The machine '%s' is already locked for a session (or being unlocked)", vm.Name)
+	}
+
+	for _, flag := range args.order {
+		val := args.Get(flag)
+
+		switch {
+		case flag == "--name":
+			if other := vb.findVM(val); other != nil && other != vm {
+				return vboxErr("VBOX_E_FILE_ERROR", "Machine",
+					"Could not rename the directory '%s' to '%s' to save the settings file (VERR_ALREADY_EXISTS)",
+					vb.machineFolder(vm.Name), vb.machineFolder(val))
+			}
+			vb.renameMachineFolder(vm, val)
+			vm.Name = val
+
+		case strings.HasPrefix(flag, "--nic") && !strings.HasPrefix(flag, "--nictype"):
+			nic, res := vb.nicFromFlag(vm, flag, "--nic")
+			if res != nil {
+				return *res
+			}
+			switch val {
+			case "none", "null", "nat", "bridged", "intnet", "hostonly", "natnetwork", "generic":
+			case "hostonlynet":
+				if !vb.isV7() {
+					return vboxErr("", "", "Invalid type '%s' specfied for NIC %s", val, strings.TrimPrefix(flag, "--nic"))
+				}
+			default:
+				return vboxErr("", "", "Invalid type '%s' specfied for NIC %s", val, strings.TrimPrefix(flag, "--nic"))
+			}
+			nic.Type = val
+			if len(nic.MAC) == 0 {
+				nic.MAC = fmt.Sprintf("080027%06X", vb.lastID)
+			}
+
+		case strings.HasPrefix(flag, "--hostonlyadapter"),
+			strings.HasPrefix(flag, "--host-only-net"),
+			strings.HasPrefix(flag, "--bridgeadapter"),
+			strings.HasPrefix(flag, "--nat-network"),
+			strings.HasPrefix(flag, "--intnet"):
+			nic, res := vb.nicFromFlag(vm, flag, strings.TrimRight(flag, "0123456789"))
+			if res != nil {
+				return *res
+			}
+			nic.Attachment = val
+
+		case strings.HasPrefix(flag, "--macaddress"):
+			nic, res := vb.nicFromFlag(vm, flag, "--macaddress")
+			if res != nil {
+				return *res
+			}
+			if val == "auto" {
+				val = fmt.Sprintf("080027%06X", vb.lastID)
+			}
+			nic.MAC = strings.ToUpper(val)
+
+		default:
+			vm.Settings[strings.TrimPrefix(flag, "--")] = val
+		}
+	}
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) nicFromFlag(vm *fakeVM, flag, prefix string) (*fakeNIC, *vboxManageResult) {
+	idx, err := strconv.Atoi(strings.TrimPrefix(flag, prefix))
+	if err != nil || idx < 1 || idx > len(vm.NICs) {
+		res := vboxSyntaxErr("Invalid NIC number in '%s'", flag)
+		return nil, &res
+	}
+	return &vm.NICs[idx-1], nil
+}
+
+func (vb *VirtualBox) renameMachineFolder(vm *fakeVM, newName string) {
+	oldDir := vb.machineFolder(vm.Name)
+	newDir := vb.machineFolder(newName)
+
+	if oldDir == newDir {
+		return
+	}
+
+	vb.renamePath(oldDir, newDir)
+
+	for _, m := range vb.media {
+		if strings.HasPrefix(m.Path, oldDir+"/") {
+			m.Path = newDir + strings.TrimPrefix(m.Path, oldDir)
+		}
+	}
+}
+
+func (vb *VirtualBox) renamePath(oldPath, newPath string) {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	oldPrefix := oldPath + "/"
+
+	for p, contents := range vb.files {
+		if p == oldPath || strings.HasPrefix(p, oldPrefix) {
+			delete(vb.files, p)
+			vb.writeFile(newPath+strings.TrimPrefix(p, oldPath), contents)
+		}
+	}
+
+	for p := range vb.dirs {
+		if p == oldPath || strings.HasPrefix(p, oldPrefix) {
+			delete(vb.dirs, p)
+			vb.mkdirAll(newPath + strings.TrimPrefix(p, oldPath))
+		}
+	}
+}
+
+func (vb *VirtualBox) showVMInfoCmd(args vboxManageArgs) vboxManageResult {
+	vm := vb.findVM(args.Positional(0))
+	if vm == nil {
+		return vb.vmNotFound(args.Positional(0))
+	}
+
+	if vm.State == "stopping" {
+		if vm.stoppingPolls > 0 {
+			vm.stoppingPolls--
+		} else {
+			vm.State = "poweroff"
+		}
+	}
+
+	if vm.Inaccessible {
+		return vboxOK(fmt.Sprintf(`name="<inaccessible>"
+UUID="%s"
+CfgFile="%s"
+access_error="Runtime error opening '%s' for reading: -102 (File not found.)."
+`, vm.UUID, vb.settingsFile(vm), vb.settingsFile(vm)))
+	}
+
+	var lines []string
+	add := func(key string, val interface{}) {
+		lines = append(lines, fmt.Sprintf(`%s="%v"`, key, val))
+	}
+	addRaw := func(key string, val interface{}) {
+		lines = append(lines, fmt.Sprintf(`%s=%v`, key, val))
+	}
+
+	add("name", vm.Name)
+	add("groups", "/")
+	add("ostype", "Ubuntu (64-bit)")
+	add("UUID", vm.UUID)
+	add("CfgFile", vb.settingsFile(vm))
+	add("SnapFldr", filepath.Join(vb.machineFolder(vm.Name), "Snapshots"))
+	add("LogFldr", filepath.Join(vb.machineFolder(vm.Name), "Logs"))
+	addRaw("memory", vm.Settings["memory"])
+	addRaw("cpus", vm.Settings["cpus"])
+	add("firmware", strings.ToUpper(vm.Settings["firmware"]))
+	add("paravirtprovider", vm.Settings["paravirtprovider"])
+	add("VMState", vm.State)
+	add("VMStateChangeTime", "2023-06-08T15:07:15.000000000")
+
+	for i, ctrl := range vm.Controllers {
+		add(fmt.Sprintf("storagecontrollername%d", i), ctrl.Name)
+		add(fmt.Sprintf("storagecontrollertype%d", i), ctrl.Type)
+		add(fmt.Sprintf("storagecontrollerinstance%d", i), "0")
+		add(fmt.Sprintf("storagecontrollermaxportcount%d", i), ctrl.Ports)
+		add(fmt.Sprintf("storagecontrollerportcount%d", i), ctrl.Ports)
+		add(fmt.Sprintf("storagecontrollerbootable%d", i), "on")
+	}
+
+	for _, ctrl := range vm.Controllers {
+		for port := 0; port < ctrl.Ports; port++ {
+			for device := 0; device < ctrl.Devices; device++ {
+				key := fmt.Sprintf("%s-%d-%d", ctrl.Name, port, device)
+				att := ctrl.Attachments[ctrl.slot(strconv.Itoa(port), strconv.Itoa(device))]
+
+				switch {
+				case att == nil:
+					lines = append(lines, fmt.Sprintf(`"%s"="none"`, key))
+				case len(att.MediumUUID) == 0:
+					lines = append(lines, fmt.Sprintf(`"%s"="emptydrive"`, key))
+				default:
+					path := ""
+					if m := vb.findMedium(att.MediumUUID); m != nil {
+						path = m.Path
+					}
+					lines = append(lines, fmt.Sprintf(`"%s"="%s"`, key, path))
+					lines = append(lines, fmt.Sprintf(`"%s-ImageUUID-%d-%d"="%s"`, ctrl.Name, port, device, att.MediumUUID))
+					if att.NonRotational {
+						lines = append(lines, fmt.Sprintf(`"%s-nonrotational-%d-%d"="on"`, ctrl.Name, port, device))
+					}
+					if att.Discard {
+						lines = append(lines, fmt.Sprintf(`"%s-discard-%d-%d"="on"`, ctrl.Name, port, device))
+					}
+					if att.HotPluggable {
+						lines = append(lines, fmt.Sprintf(`"%s-hot-pluggable-%d-%d"="on"`, ctrl.Name, port, device))
+					}
+				}
+			}
+		}
+	}
+
+	for i, nic := range vm.NICs {
+		idx := i + 1
+
+		switch nic.Type {
+		case "none":
+			add(fmt.Sprintf("nic%d", idx), "none")
+			continue
+		case "hostonly":
+			add(fmt.Sprintf("hostonlyadapter%d", idx), nic.Attachment)
+		case "hostonlynet":
+			add(fmt.Sprintf("hostonly-network%d", idx), nic.Attachment)
+		case "bridged":
+			add(fmt.Sprintf("bridgeadapter%d", idx), nic.Attachment)
+		case "natnetwork":
+			add(fmt.Sprintf("nat-network%d", idx), nic.Attachment)
+		case "intnet":
+			add(fmt.Sprintf("intnet%d", idx), nic.Attachment)
+		case "nat":
+			add(fmt.Sprintf("natnet%d", idx), "nat")
+		}
+
+		add(fmt.Sprintf("macaddress%d", idx), nic.MAC)
+		add(fmt.Sprintf("cableconnected%d", idx), "on")
+		add(fmt.Sprintf("nic%d", idx), nic.Type)
+		add(fmt.Sprintf("nictype%d", idx), "82540EM")
+	}
+
+	for i, folder := range vm.SharedFolders {
+		add(fmt.Sprintf("SharedFolderNameMachineMapping%d", i+1), folder.Name)
+		add(fmt.Sprintf("SharedFolderPathMachineMapping%d", i+1), folder.HostPath)
+	}
+
+	if vm.isRunning() {
+		add("SessionName", vm.SessionType)
+	}
+
+	for i, snap := range vm.Snapshots {
+		suffix := strings.Repeat("-1", i)
+		add("SnapshotName"+suffix, snap.Name)
+		add("SnapshotUUID"+suffix, snap.UUID)
+	}
+
+	if len(vm.Snapshots) > 0 {
+		last := vm.Snapshots[len(vm.Snapshots)-1]
+		add("CurrentSnapshotName", last.Name)
+		add("CurrentSnapshotUUID", last.UUID)
+		add("CurrentSnapshotNode", "SnapshotName")
+	}
+
+	return vboxOK(strings.Join(lines, "\n") + "\n")
+}
+
+func (vb *VirtualBox) settingsFile(vm *fakeVM) string {
+	return filepath.Join(vb.machineFolder(vm.Name), vm.Name+".vbox")
+}
+
+func (vb *VirtualBox) startVMCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if vm.isRunning() {
+		return vboxErr("VBOX_E_INVALID_OBJECT_STATE", "Machine",
+			"The machine '%s' is already locked by a session (or being locked or unlocked)", vm.Name)
+	}
+
+	startType := args.Get("--type")
+	if len(startType) == 0 {
+		startType = "gui"
+	}
+
+	switch startType {
+	case "gui", "headless", "sdl", "separate":
+	default:
+		return vboxErr("E_INVALIDARG", "Machine", "Invalid session type '%s'", startType)
+	}
+
+	vm.State = "running"
+	vm.SessionType = startType
+
+	return vboxOK(fmt.Sprintf(
+		"Waiting for VM \"%s\" to power on...\nVM \"%s\" has been successfully started.\n", vm.Name, vm.Name))
+}
+
+func (vb *VirtualBox) controlVMCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	notRunning := vboxErr("", "", "Machine '%s' is not currently running", vm.Name)
+
+	switch args.Positional(1) {
+	case "poweroff":
+		if !vm.isRunning() {
+			return notRunning
+		}
+		vm.State = "poweroff"
+		return vboxOK(vboxManageProgress + "\n")
+
+	case "acpipowerbutton":
+		if !vm.isRunning() {
+			return notRunning
+		}
+		if vm.State == "running" && !vm.IgnoreACPI {
+			vm.State = "poweroff"
+			if vm.stoppingPolls > 0 {
+				vm.State = "stopping"
+			}
+		}
+		return vboxOK("")
+
+	case "pause":
+		if !vm.isRunning() {
+			return notRunning
+		}
+		vm.State = "paused"
+		return vboxOK("")
+
+	case "resume":
+		if !vm.isRunning() {
+			return notRunning
+		}
+		if vm.State != "paused" {
+			return vboxErr("VBOX_E_INVALID_VM_STATE", "ConsoleWrap",
+				"Cannot resume the machine as it is not paused (machine state: Running)")
+		}
+		vm.State = "running"
+		return vboxOK("")
+
+	case "savestate":
+		if !vm.isRunning() {
+			return notRunning
+		}
+		vm.State = "saved"
+		return vboxOK(vboxManageProgress + "\n")
+
+	default:
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(1))
+	}
+}
+
+func (vb *VirtualBox) discardStateCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if vm.State != "saved" {
+		return vboxErr("VBOX_E_INVALID_VM_STATE", "Machine",
+			"Cannot discard the saved state as the machine is not in the saved state (machine state: %s)", vm.State)
+	}
+
+	vm.State = "poweroff"
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) unregisterVMCmd(args vboxManageArgs) vboxManageResult {
+	vm := vb.findVM(args.Positional(0))
+	if vm == nil {
+		return vb.vmNotFound(args.Positional(0))
+	}
+
+	if vm.isRunning() {
+		return vboxErr("VBOX_E_INVALID_OBJECT_STATE", "Machine",
+			"Cannot unregister the machine '%s' while it is locked", vm.Name)
+	}
+
+	if args.Has("--delete") && !vm.Inaccessible {
+		owned := map[string]bool{}
+		for _, uuid := range append(vm.attachedMediumUUIDs("hdd"), vm.snapshotMediumUUIDs()...) {
+			owned[uuid] = true
+		}
+
+		// Differencing images created by snapshots of this VM are owned as well
+		for changed := true; changed; {
+			changed = false
+			for _, m := range vb.media {
+				if !owned[m.UUID] && len(m.ParentUUID) > 0 && owned[m.ParentUUID] && vb.mediumUsers(m.UUID) == nil {
+					owned[m.UUID], changed = true, true
+				}
+			}
+		}
+
+		for uuid := range owned {
+			for _, child := range vb.mediumChildren(uuid) {
+				if !owned[child.UUID] {
+					return vboxErr("VBOX_E_OBJECT_IN_USE", "Machine",
+						"Cannot unregister the machine '%s' because medium '%s' has linked clones", vm.Name, vb.findMedium(uuid).Path)
+				}
+			}
+		}
+
+		vb.removeVM(vm)
+
+		// Delete children before parents
+		for len(owned) > 0 {
+			for uuid := range owned {
+				if len(vb.mediumChildren(uuid)) == 0 {
+					if m := vb.findMedium(uuid); m != nil {
+						vb.removeAll(m.Path)
+						vb.removeMedium(m)
+					}
+					delete(owned, uuid)
+				}
+			}
+		}
+
+		vb.removeAll(vb.machineFolder(vm.Name))
+
+		return vboxOK(vboxManageProgress + "\n")
+	}
+
+	vb.removeVM(vm)
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) setExtraDataCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	key, val := args.Positional(1), args.Positional(2)
+	if len(val) == 0 {
+		delete(vm.ExtraData, key)
+	} else {
+		vm.ExtraData[key] = val
+	}
+
+	return vboxOK("")
+}
+
+func (vb *VirtualBox) getExtraDataCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(0))
+	if errRes != nil {
+		return *errRes
+	}
+
+	key := args.Positional(1)
+
+	if key != "enumerate" {
+		if val, found := vm.ExtraData[key]; found {
+			return vboxOK(fmt.Sprintf("Value: %s\n", val))
+		}
+		return vboxOK("No value set!\n")
+	}
+
+	var keys []string
+	for k := range vm.ExtraData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out string
+	for _, k := range keys {
+		out += fmt.Sprintf("Key: %s, Value: %s\n", k, vm.ExtraData[k])
+	}
+
+	return vboxOK(out)
+}
+
+func (vb *VirtualBox) sharedFolderCmd(args vboxManageArgs) vboxManageResult {
+	vm, errRes := vb.lookupVM(args.Positional(1))
+	if errRes != nil {
+		return *errRes
+	}
+
+	if args.Positional(0) != "add" {
+		return vboxSyntaxErr("Invalid parameter '%s'", args.Positional(0))
+	}
+
+	if vm.isRunning() && !args.Has("--transient") {
+		return vboxErr("VBOX_E_INVALID_VM_STATE", "SessionMachine",
+			"The machine '%s' is already locked for a session (or being unlocked)", vm.Name)
+	}
+
+	for _, folder := range vm.SharedFolders {
+		if folder.Name == args.Get("--name") {
+			return vboxErr("VBOX_E_OBJECT_IN_USE", "SharedFolder",
+				"Shared folder named '%s' already exists", folder.Name)
+		}
+	}
+
+	vm.SharedFolders = append(vm.SharedFolders, fakeSharedFolder{
+		Name:     args.Get("--name"),
+		HostPath: args.Get("--hostpath"),
+	})
+
+	return vboxOK("")
+}
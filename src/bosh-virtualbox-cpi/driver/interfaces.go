@@ -1,22 +1,67 @@
 package driver
 
+import (
+	"context"
+	"os"
+	"time"
+)
+
 type ExecuteOpts struct {
 	IgnoreNonZeroExitStatus bool
+
+	// Overrides default timeout for the VBoxManage subcommand
+	Timeout time.Duration
 }
 
 type Driver interface {
 	Execute(args ...string) (string, error)
 	ExecuteComplex(args []string, opts ExecuteOpts) (string, error)
-	IsMissingVMErr(output string) bool
+
+	// ExecuteContext stops retrying and kills running command when ctx is done
+	ExecuteContext(ctx context.Context, args []string, opts ExecuteOpts) (string, error)
+
+	MachineInfo(nameOrID string) (MachineInfo, error)
+
+	// Capabilities are determined once per driver
+	Capabilities() (Capabilities, error)
 }
 
 var _ Driver = ExecDriver{}
 
 type Runner interface {
 	Execute(path string, args ...string) (string, int, error)
+	// ExecuteContext kills command when ctx is done
+	ExecuteContext(ctx context.Context, path string, args ...string) (string, int, error)
 	Upload(srcDir, dstDir string) error
 	Put(path string, contents []byte) error
 	Get(path string) ([]byte, error)
+
+	// Append adds contents to the end of a file creating it if necessary
+	Append(path string, contents []byte) error
+
+	// MkdirAll creates directory with all missing parents
+	MkdirAll(path string) error
+
+	// List returns sorted names of directory entries skipping hidden ones
+	List(path string) ([]string, error)
+
+	// Stat returns error satisfying os.IsNotExist if path does not exist
+	Stat(path string) (os.FileInfo, error)
+
+	// RemoveAll removes path with all its contents; missing path is not an error
+	RemoveAll(path string) error
+
+	// Rename replaces newPath if it already exists
+	Rename(oldPath, newPath string) error
+
+	// Lock blocks until exclusive lock on a file at path (created if missing)
+	// is acquired or until timeout passes. Lock is shared with all CPI processes
+	// talking to the same host.
+	Lock(path string, timeout time.Duration) (Lock, error)
+}
+
+type Lock interface {
+	Unlock() error
 }
 
 var _ Runner = LocalRunner{}
@@ -1,8 +1,15 @@
 package driver
 
 import (
+	"context"
+	"os"
 	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshfu "github.com/cloudfoundry/bosh-utils/fileutil"
@@ -10,6 +17,14 @@ import (
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+const (
+	localRunnerKillGracePeriod = 5 * time.Second
+)
+
+var (
+	localGlobMeta = regexp.MustCompile(`([*?[\\])`)
+)
+
 type LocalRunner struct {
 	fs        boshsys.FileSystem
 	cmdRunner boshsys.CmdRunner
@@ -37,6 +52,10 @@ func (r LocalRunner) HomeDir() (string, error) {
 }
 
 func (r LocalRunner) Execute(path string, args ...string) (string, int, error) {
+	return r.ExecuteContext(context.Background(), path, args...)
+}
+
+func (r LocalRunner) ExecuteContext(ctx context.Context, path string, args ...string) (string, int, error) {
 	r.logger.Debug(r.logTag, "Execute '%s %s'", path, strings.Join(args, "' '"))
 
 	current_user, userErr := user.Current()
@@ -54,8 +73,29 @@ func (r LocalRunner) Execute(path string, args ...string) (string, int, error) {
 		},
 	}
 
-	stdout, stderr, status, err := r.cmdRunner.RunComplexCommand(cmd)
-	return stdout + "\n" + stderr, status, err
+	process, err := r.cmdRunner.RunComplexCommandAsync(cmd)
+	if err != nil {
+		return "", -1, err
+	}
+
+	resultCh := process.Wait()
+
+	select {
+	case result := <-resultCh:
+		return result.Stdout + "\n" + result.Stderr, result.ExitStatus, result.Error
+
+	case <-ctx.Done():
+		r.logger.Debug(r.logTag, "Terminating '%s': %s", path, ctx.Err())
+
+		err := process.TerminateNicely(localRunnerKillGracePeriod)
+		if err != nil {
+			r.logger.Error(r.logTag, "Failed to terminate '%s': %s", path, err)
+		}
+
+		result := <-resultCh
+
+		return result.Stdout + "\n" + result.Stderr, -1, bosherr.WrapErrorf(ctx.Err(), "Running '%s'", path)
+	}
 }
 
 func (r LocalRunner) Upload(srcPath, dstPath string) error {
@@ -72,3 +112,116 @@ func (r LocalRunner) Get(path string) ([]byte, error) {
 	r.logger.Debug(r.logTag, "Get '%s'", path)
 	return r.fs.ReadFile(path)
 }
+
+func (r LocalRunner) Append(path string, contents []byte) error {
+	r.logger.Debug(r.logTag, "Append to '%s' %d contents", path, len(contents))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", path)
+	}
+
+	defer file.Close()
+
+	_, err = file.Write(contents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Appending to '%s'", path)
+	}
+
+	return nil
+}
+
+func (r LocalRunner) MkdirAll(path string) error {
+	err := r.fs.MkdirAll(path, 0755)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory '%s'", path)
+	}
+
+	return nil
+}
+
+func (r LocalRunner) List(path string) ([]string, error) {
+	_, err := r.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Glob skips hidden entries similarly to ls(1)
+	matches, err := r.fs.Glob(filepath.Join(localGlobMeta.ReplaceAllString(path, `\$1`), "*"))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing '%s'", path)
+	}
+
+	var names []string
+
+	for _, match := range matches {
+		names = append(names, filepath.Base(match))
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Stat returns os errors as is so that os.IsNotExist works
+func (r LocalRunner) Stat(path string) (os.FileInfo, error) {
+	return r.fs.Stat(path)
+}
+
+func (r LocalRunner) RemoveAll(path string) error {
+	err := r.fs.RemoveAll(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing '%s'", path)
+	}
+
+	return nil
+}
+
+func (r LocalRunner) Rename(oldPath, newPath string) error {
+	err := r.fs.Rename(oldPath, newPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming '%s' to '%s'", oldPath, newPath)
+	}
+
+	return nil
+}
+
+func (r LocalRunner) Lock(path string, timeout time.Duration) (Lock, error) {
+	r.logger.Debug(r.logTag, "Lock '%s'", path)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening lock file '%s'", path)
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	// Blocking flock(2) cannot be interrupted hence lock is polled for
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return localLock{file}, nil
+		}
+
+		if err != syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, bosherr.WrapErrorf(err, "Locking '%s'", path)
+		}
+
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, bosherr.Errorf("Timed out after %s waiting for lock '%s'", timeout, path)
+		}
+
+		time.Sleep(localLockPollInterval)
+	}
+}
+
+const localLockPollInterval = 100 * time.Millisecond
+
+// localLock is released when file is closed
+type localLock struct {
+	file *os.File
+}
+
+func (l localLock) Unlock() error { return l.file.Close() }
@@ -1,6 +1,12 @@
 package driver_test
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	. "github.com/onsi/ginkgo"
@@ -31,4 +37,104 @@ var _ = Describe("LocalRunner", func() {
 			Expect(path).ToNot(ContainSubstring("~"))
 		})
 	})
+
+	Context("Lock", func() {
+		It("blocks until lock is released", func() {
+			dir, err := ioutil.TempDir("", "local-runner")
+			Expect(err).ToNot(HaveOccurred())
+
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "test.lock")
+
+			lock, err := runner.Lock(path, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+
+			acquired := make(chan Lock)
+
+			go func() {
+				defer GinkgoRecover()
+
+				lock2, err := runner.Lock(path, time.Minute)
+				Expect(err).ToNot(HaveOccurred())
+				acquired <- lock2
+			}()
+
+			Consistently(acquired, 200*time.Millisecond).ShouldNot(Receive())
+
+			Expect(lock.Unlock()).To(Succeed())
+
+			var lock2 Lock
+			Eventually(acquired).Should(Receive(&lock2))
+			Expect(lock2.Unlock()).To(Succeed())
+		})
+
+		It("gives up waiting for lock after timeout", func() {
+			dir, err := ioutil.TempDir("", "local-runner")
+			Expect(err).ToNot(HaveOccurred())
+
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "test.lock")
+
+			lock, err := runner.Lock(path, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+
+			defer lock.Unlock()
+
+			startedAt := time.Now()
+
+			_, err = runner.Lock(path, 300*time.Millisecond)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Timed out after 300ms waiting for lock"))
+			Expect(time.Since(startedAt)).To(BeNumerically("<", 2*time.Second))
+		})
+	})
+
+	Context("file operations", func() {
+		It("reports missing files as not existing", func() {
+			dir, err := ioutil.TempDir("", "local-runner")
+			Expect(err).ToNot(HaveOccurred())
+
+			defer os.RemoveAll(dir)
+
+			runner = NewLocalRunner(boshsys.NewOsFileSystem(logger), cmdRunner, logger)
+
+			dirPath := filepath.Join(dir, "a[1]", "b")
+
+			_, err = runner.Stat(dirPath)
+			Expect(IsNotExistErr(err)).To(BeTrue())
+
+			_, err = runner.List(dirPath)
+			Expect(IsNotExistErr(err)).To(BeTrue())
+
+			Expect(runner.MkdirAll(dirPath)).To(Succeed())
+			Expect(runner.Put(filepath.Join(dirPath, "file"), []byte("contents"))).To(Succeed())
+			Expect(runner.Rename(filepath.Join(dirPath, "file"), filepath.Join(dirPath, "renamed"))).To(Succeed())
+
+			names, err := runner.List(dirPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(Equal([]string{"renamed"}))
+
+			Expect(runner.RemoveAll(filepath.Join(dir, "a[1]"))).To(Succeed())
+
+			_, err = runner.Stat(dirPath)
+			Expect(IsNotExistErr(err)).To(BeTrue())
+		})
+	})
+
+	Context("ExecuteContext", func() {
+		It("kills command when context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			startedAt := time.Now()
+
+			_, status, err := runner.ExecuteContext(ctx, "sleep", "30")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Running 'sleep'"))
+			Expect(status).To(Equal(-1))
+			Expect(time.Since(startedAt)).To(BeNumerically("<", 10*time.Second))
+		})
+	})
 })
@@ -0,0 +1,90 @@
+package driver
+
+import (
+	"path/filepath"
+	"regexp"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var (
+	lockScopeUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+)
+
+const (
+	// Long enough to wait for other CPI processes cloning VMs from the same
+	// stemcell, but short enough to surface lock held by a stuck process
+	locksTimeout = 10 * time.Minute
+)
+
+// LockScope names a set of resources on the VirtualBox host
+// that must not be modified by multiple CPI processes at once.
+type LockScope string
+
+func VMLockScope(cid string) LockScope { return LockScope("vm-" + cid) }
+
+func NetworkLockScope(name string) LockScope { return LockScope("network-" + name) }
+
+func (s LockScope) fileName() string {
+	return lockScopeUnsafeChars.ReplaceAllString(string(s), "_") + ".lock"
+}
+
+// Locks coordinates concurrently running CPI processes
+// via lock files kept on the VirtualBox host
+type Locks struct {
+	runner  Runner
+	dirPath string
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewLocks(runner Runner, dirPath string, logger boshlog.Logger) Locks {
+	return Locks{
+		runner:  runner,
+		dirPath: dirPath,
+
+		logTag: "driver.Locks",
+		logger: logger,
+	}
+}
+
+// Lock blocks until other CPI processes release given scope
+// and returns error if that does not happen within locksTimeout
+func (l Locks) Lock(scope LockScope) (Lock, error) {
+	err := l.runner.MkdirAll(l.dirPath)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating locks directory")
+	}
+
+	startedAt := time.Now()
+
+	lock, err := l.runner.Lock(filepath.Join(l.dirPath, scope.fileName()), locksTimeout)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Acquiring lock '%s'", scope)
+	}
+
+	l.logger.Debug(l.logTag, "Acquired lock '%s' after %s", scope, time.Since(startedAt))
+
+	return scopedLock{lock, scope, l}, nil
+}
+
+type scopedLock struct {
+	lock  Lock
+	scope LockScope
+	locks Locks
+}
+
+func (l scopedLock) Unlock() error {
+	err := l.lock.Unlock()
+	if err != nil {
+		l.locks.logger.Error(l.locks.logTag, "Failed to release lock '%s': %s", l.scope, err)
+		return bosherr.WrapErrorf(err, "Releasing lock '%s'", l.scope)
+	}
+
+	l.locks.logger.Debug(l.locks.logTag, "Released lock '%s'", l.scope)
+
+	return nil
+}
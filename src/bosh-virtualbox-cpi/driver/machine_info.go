@@ -0,0 +1,341 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Covers `name="vm-1"`, `memory=512` and `"SATA-0-0"="/path/disk.vmdk"`
+	machineInfoKVMatch = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|[^=\s]+)=(.*)$`)
+
+	// Covers `"SATA-ImageUUID-0-0"` (name-ImageUUID-port-device)
+	machineInfoImageUUIDMatch = regexp.MustCompile(`^(.+)-ImageUUID-(\d+)-(\d+)$`)
+
+	// Covers `"SATA-0-0"` (name-port-device)
+	machineInfoPortDeviceMatch = regexp.MustCompile(`^(.+)-(\d+)-(\d+)$`)
+
+	// Covers `Key: GUI/LastCloseAction, Value: PowerOff`
+	extraDataMatch = regexp.MustCompile(`^Key: (.+?), Value: (.*)$`)
+)
+
+const (
+	MachineStateInaccessible = "inaccessible"
+)
+
+// MachineInfo is a parsed representation of `showvminfo --machinereadable` output.
+// Extra data is not part of that output and is only fetched when asked for (see ExtraData).
+type MachineInfo struct {
+	values    map[string]string
+	extraData *machineExtraData
+}
+
+// machineExtraData is loaded at most once and shared by copies of MachineInfo
+type machineExtraData struct {
+	once sync.Once
+	load func() (string, error)
+
+	values map[string]string
+	err    error
+}
+
+type StorageController struct {
+	Name string // e.g. SATA, IDE Controller
+	Type string // e.g. IntelAhci, PIIX4, LsiLogic
+
+	PortCount    int
+	MaxPortCount int
+
+	Attachments []StorageAttachment
+}
+
+type StorageAttachment struct {
+	Port   string // e.g. "0"
+	Device string // e.g. "1"
+
+	Medium    string // e.g. none, emptydrive, /path/disk.vmdk
+	ImageUUID string
+}
+
+func (a StorageAttachment) IsEmpty() bool { return a.Medium == "none" }
+
+type NIC struct {
+	Index int    // starts at 1
+	Type  string // e.g. none, nat, hostonly, hostonlynet, bridged, natnetwork
+	MAC   string // e.g. 080027A1B2C3
+
+	// Name of the network NIC is attached to, e.g. vboxnet0
+	Attachment string
+}
+
+type SharedFolder struct {
+	Name     string
+	HostPath string
+}
+
+// NewMachineInfo tolerates unknown keys and lines that do not look like key-value pairs.
+func NewMachineInfo(output string) MachineInfo {
+	info := MachineInfo{values: map[string]string{}}
+
+	lines := strings.Split(strings.Replace(output, "\r\n", "\n", -1), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		matches := machineInfoKVMatch.FindStringSubmatch(lines[i])
+		if len(matches) != 3 {
+			continue
+		}
+
+		key, val := matches[1], matches[2]
+
+		// Quoted values (e.g. description) may span multiple lines
+		if strings.HasPrefix(val, `"`) {
+			for !machineInfoClosedQuote(val) && i+1 < len(lines) {
+				i++
+				val += "\n" + lines[i]
+			}
+		}
+
+		info.values[machineInfoUnquote(key)] = machineInfoUnquote(val)
+	}
+
+	return info
+}
+
+func (i MachineInfo) Value(key string) (string, bool) {
+	val, found := i.values[key]
+	return val, found
+}
+
+func (i MachineInfo) Name() string { return i.values["name"] }
+func (i MachineInfo) UUID() string { return i.values["UUID"] }
+
+// State returns VMState (e.g. running, poweroff, saved, aborted)
+// or 'inaccessible' when VirtualBox cannot read VM's configuration.
+func (i MachineInfo) State() string {
+	if i.values["name"] == "<inaccessible>" {
+		return MachineStateInaccessible
+	}
+	return i.values["VMState"]
+}
+
+// StateChangeTime returns VMStateChangeTime (e.g. 2023-06-08T15:07:15.000000000) which is in UTC
+func (i MachineInfo) StateChangeTime() (time.Time, bool) {
+	t, err := time.Parse("2006-01-02T15:04:05.999999999", i.values["VMStateChangeTime"])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (i MachineInfo) StorageControllers() []StorageController {
+	var ctrls []StorageController
+
+	for idx := 0; ; idx++ {
+		name, found := i.values[fmt.Sprintf("storagecontrollername%d", idx)]
+		if !found {
+			break
+		}
+
+		ctrl := StorageController{
+			Name:         name,
+			Type:         i.values[fmt.Sprintf("storagecontrollertype%d", idx)],
+			PortCount:    i.intValue(fmt.Sprintf("storagecontrollerportcount%d", idx)),
+			MaxPortCount: i.intValue(fmt.Sprintf("storagecontrollermaxportcount%d", idx)),
+			Attachments:  i.storageAttachments(name),
+		}
+
+		ctrls = append(ctrls, ctrl)
+	}
+
+	return ctrls
+}
+
+// StorageController finds controller whose name matches given regexp
+func (i MachineInfo) StorageController(nameMatch *regexp.Regexp) (StorageController, bool) {
+	for _, ctrl := range i.StorageControllers() {
+		if nameMatch.MatchString(ctrl.Name) {
+			return ctrl, true
+		}
+	}
+	return StorageController{}, false
+}
+
+func (i MachineInfo) storageAttachments(ctrlName string) []StorageAttachment {
+	var atts []StorageAttachment
+
+	prefix := ctrlName + "-"
+
+	for key, val := range i.values {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		matches := machineInfoPortDeviceMatch.FindStringSubmatch(key)
+		if len(matches) != 4 || matches[1] != ctrlName {
+			continue
+		}
+
+		atts = append(atts, StorageAttachment{
+			Port:      matches[2],
+			Device:    matches[3],
+			Medium:    val,
+			ImageUUID: i.values[fmt.Sprintf("%s-ImageUUID-%s-%s", ctrlName, matches[2], matches[3])],
+		})
+	}
+
+	sort.Slice(atts, func(a, b int) bool {
+		portA, _ := strconv.Atoi(atts[a].Port)
+		portB, _ := strconv.Atoi(atts[b].Port)
+		if portA != portB {
+			return portA < portB
+		}
+		return atts[a].Device < atts[b].Device
+	})
+
+	return atts
+}
+
+// ImageUUIDs returns all media UUIDs attached to the VM
+func (i MachineInfo) ImageUUIDs() []string {
+	var uuids []string
+
+	for key, val := range i.values {
+		if machineInfoImageUUIDMatch.MatchString(key) {
+			uuids = append(uuids, val)
+		}
+	}
+
+	sort.Strings(uuids)
+
+	return uuids
+}
+
+func (i MachineInfo) NICs() []NIC {
+	var nics []NIC
+
+	for idx := 1; ; idx++ {
+		nicType, found := i.values[fmt.Sprintf("nic%d", idx)]
+		if !found {
+			break
+		}
+
+		nic := NIC{
+			Index: idx,
+			Type:  nicType,
+			MAC:   i.values[fmt.Sprintf("macaddress%d", idx)],
+		}
+
+		for _, key := range []string{"hostonlyadapter", "hostonly-network", "bridgeadapter", "nat-network", "intnet"} {
+			if name, found := i.values[fmt.Sprintf("%s%d", key, idx)]; found {
+				nic.Attachment = name
+				break
+			}
+		}
+
+		nics = append(nics, nic)
+	}
+
+	return nics
+}
+
+func (i MachineInfo) SharedFolders() []SharedFolder {
+	var folders []SharedFolder
+
+	for idx := 1; ; idx++ {
+		name, found := i.values[fmt.Sprintf("SharedFolderNameMachineMapping%d", idx)]
+		if !found {
+			break
+		}
+
+		folders = append(folders, SharedFolder{
+			Name:     name,
+			HostPath: i.values[fmt.Sprintf("SharedFolderPathMachineMapping%d", idx)],
+		})
+	}
+
+	return folders
+}
+
+func (i MachineInfo) intValue(key string) int {
+	val, _ := strconv.Atoi(i.values[key])
+	return val
+}
+
+// WithExtraData returns info that includes given `getextradata <vm> enumerate` output.
+func (i MachineInfo) WithExtraData(output string) MachineInfo {
+	return i.withExtraDataLoader(func() (string, error) { return output, nil })
+}
+
+// withExtraDataLoader returns info that runs `getextradata <vm> enumerate`
+// the first time any of its extra data is asked for.
+func (i MachineInfo) withExtraDataLoader(load func() (string, error)) MachineInfo {
+	i.extraData = &machineExtraData{load: load}
+	return i
+}
+
+// ExtraData returns value of extra data key; info without extra data has no keys.
+func (i MachineInfo) ExtraData(key string) (string, bool, error) {
+	if i.extraData == nil {
+		return "", false, nil
+	}
+
+	data := i.extraData
+
+	data.once.Do(func() {
+		var output string
+
+		output, data.err = data.load()
+		if data.err == nil {
+			data.values = parseExtraData(output)
+		}
+	})
+
+	if data.err != nil {
+		return "", false, data.err
+	}
+
+	val, found := data.values[key]
+
+	return val, found, nil
+}
+
+func parseExtraData(output string) map[string]string {
+	values := map[string]string{}
+
+	for _, line := range strings.Split(strings.Replace(output, "\r\n", "\n", -1), "\n") {
+		matches := extraDataMatch.FindStringSubmatch(line)
+		if len(matches) == 3 {
+			values[matches[1]] = matches[2]
+		}
+	}
+
+	return values
+}
+
+func machineInfoClosedQuote(val string) bool {
+	if len(val) < 2 || !strings.HasSuffix(val, `"`) {
+		return false
+	}
+
+	// Count escaping backslashes preceding closing quote
+	backslashes := 0
+	for j := len(val) - 2; j >= 0 && val[j] == '\\'; j-- {
+		backslashes++
+	}
+
+	return backslashes%2 == 0
+}
+
+func machineInfoUnquote(val string) string {
+	if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+		val = val[1 : len(val)-1]
+		val = strings.Replace(val, `\"`, `"`, -1)
+		val = strings.Replace(val, `\\`, `\`, -1)
+	}
+	return val
+}
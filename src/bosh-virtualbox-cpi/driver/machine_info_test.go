@@ -0,0 +1,105 @@
+package driver_test
+
+import (
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+)
+
+var _ = Describe("MachineInfo", func() {
+	output := `name="vm-1"
+groups="/"
+UUID="8b33e9d9-525f-49a9-6e1e-b156194ca0fe"
+memory=512
+VMState="running"
+description="multi
+line \"quoted\""
+storagecontrollername0="SCSI"
+storagecontrollertype0="LsiLogic"
+storagecontrollerportcount0="16"
+storagecontrollermaxportcount0="16"
+storagecontrollername1="SATA Controller"
+storagecontrollertype1="IntelAhci"
+storagecontrollerportcount1="2"
+storagecontrollermaxportcount1="30"
+"SCSI-0-0"="/store/vms/vm-1/env.iso"
+"SCSI-ImageUUID-0-0"="11111111-0000-0000-0000-000000000000"
+"SCSI-1-0"="none"
+"SATA Controller-0-0"="/store/stemcells/sc-1/image-disk1.vmdk"
+"SATA Controller-ImageUUID-0-0"="a840e5e0-947c-4e63-ac2f-678a86d13980"
+"SATA Controller-1-0"="none"
+nic1="hostonly"
+hostonlyadapter1="vboxnet0"
+macaddress1="0227A1B2C3D4"
+nic2="hostonlynet"
+hostonly-network2="vboxnet1"
+macaddress2="0227A1B2C3D5"
+nic3="none"
+SharedFolderNameMachineMapping1="folder-0"
+SharedFolderPathMachineMapping1="/Users/me/shared"
+not a key value line
+`
+
+	It("parses state and basic values", func() {
+		info := NewMachineInfo(output)
+		Expect(info.Name()).To(Equal("vm-1"))
+		Expect(info.UUID()).To(Equal("8b33e9d9-525f-49a9-6e1e-b156194ca0fe"))
+		Expect(info.State()).To(Equal("running"))
+
+		val, found := info.Value("memory")
+		Expect(found).To(BeTrue())
+		Expect(val).To(Equal("512"))
+
+		val, _ = info.Value("description")
+		Expect(val).To(Equal("multi\nline \"quoted\""))
+	})
+
+	It("reports inaccessible VMs", func() {
+		info := NewMachineInfo(`name="<inaccessible>"` + "\n" + `VMState="poweroff"`)
+		Expect(info.State()).To(Equal(MachineStateInaccessible))
+	})
+
+	It("parses storage controllers with attachments", func() {
+		ctrls := NewMachineInfo(output).StorageControllers()
+		Expect(ctrls).To(HaveLen(2))
+
+		Expect(ctrls[1].Name).To(Equal("SATA Controller"))
+		Expect(ctrls[1].Type).To(Equal("IntelAhci"))
+		Expect(ctrls[1].MaxPortCount).To(Equal(30))
+		Expect(ctrls[1].Attachments).To(Equal([]StorageAttachment{
+			{Port: "0", Device: "0", Medium: "/store/stemcells/sc-1/image-disk1.vmdk", ImageUUID: "a840e5e0-947c-4e63-ac2f-678a86d13980"},
+			{Port: "1", Device: "0", Medium: "none"},
+		}))
+
+		ctrl, found := NewMachineInfo(output).StorageController(regexp.MustCompile(`^(?i:SCSI)`))
+		Expect(found).To(BeTrue())
+		Expect(ctrl.Name).To(Equal("SCSI"))
+	})
+
+	It("parses NICs and shared folders", func() {
+		info := NewMachineInfo(output)
+		Expect(info.NICs()).To(Equal([]NIC{
+			{Index: 1, Type: "hostonly", MAC: "0227A1B2C3D4", Attachment: "vboxnet0"},
+			{Index: 2, Type: "hostonlynet", MAC: "0227A1B2C3D5", Attachment: "vboxnet1"},
+			{Index: 3, Type: "none"},
+		}))
+		Expect(info.SharedFolders()).To(Equal([]SharedFolder{{Name: "folder-0", HostPath: "/Users/me/shared"}}))
+	})
+
+	It("parses extra data", func() {
+		info := NewMachineInfo(output).WithExtraData(
+			"Key: GUI/LastCloseAction, Value: PowerOff\nKey: VBoxInternal2/SilentReconfigureWhilePaused, Value: 1\n")
+
+		val, found, err := info.ExtraData("VBoxInternal2/SilentReconfigureWhilePaused")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(val).To(Equal("1"))
+
+		_, found, err = info.ExtraData("GUI/Fullscreen")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+})
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Covers `"vm-1" {1a2b...}` and `"<inaccessible>" {1a2b...}`
+	registeredVMLine = regexp.MustCompile(`^"(.*)" \{([0-9a-fA-F-]+)\}$`)
+
+	// Covers `Capacity:       1024 MBytes`
+	mediumCapacityMatch = regexp.MustCompile(`^(\d+) MBytes$`)
+)
+
+// RegisteredVM is an entry of `list vms` output
+type RegisteredVM struct {
+	Name string // <inaccessible> when settings file cannot be read
+	UUID string
+}
+
+func (vm RegisteredVM) IsInaccessible() bool { return vm.Name == "<inaccessible>" }
+
+func NewRegisteredVMs(output string) []RegisteredVM {
+	var vms []RegisteredVM
+
+	for _, line := range strings.Split(output, "\n") {
+		matches := registeredVMLine.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) == 3 {
+			vms = append(vms, RegisteredVM{Name: matches[1], UUID: matches[2]})
+		}
+	}
+
+	return vms
+}
+
+// RegisteredMedium is an entry of `list hdds` output
+type RegisteredMedium struct {
+	UUID       string
+	ParentUUID string // empty for base media
+
+	Location   string
+	Format     string // e.g. VMDK, VDI
+	CapacityMB int
+}
+
+func NewRegisteredMedia(output string) []RegisteredMedium {
+	var media []RegisteredMedium
+
+	for _, chunk := range strings.Split(strings.TrimSpace(output), "\n\n") {
+		var medium RegisteredMedium
+
+		for _, line := range strings.Split(chunk, "\n") {
+			pieces := strings.SplitN(line, ":", 2)
+			if len(pieces) != 2 {
+				continue
+			}
+
+			val := strings.TrimSpace(pieces[1])
+
+			switch strings.TrimSpace(pieces[0]) {
+			case "UUID":
+				medium.UUID = val
+			case "Parent UUID":
+				if val != "base" {
+					medium.ParentUUID = val
+				}
+			case "Location":
+				medium.Location = val
+			case "Storage format":
+				medium.Format = val
+			case "Capacity":
+				if matches := mediumCapacityMatch.FindStringSubmatch(val); len(matches) == 2 {
+					medium.CapacityMB, _ = strconv.Atoi(matches[1])
+				}
+			}
+		}
+
+		if len(medium.UUID) > 0 {
+			media = append(media, medium)
+		}
+	}
+
+	return media
+}
@@ -0,0 +1,59 @@
+package driver_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+)
+
+var _ = Describe("Registry", func() {
+	It("parses registered VMs including inaccessible ones", func() {
+		vms := NewRegisteredVMs(`"sc-1" {11111111-0000-4000-8000-000000000001}
+"<inaccessible>" {22222222-0000-4000-8000-000000000002}
+`)
+
+		Expect(vms).To(Equal([]RegisteredVM{
+			{Name: "sc-1", UUID: "11111111-0000-4000-8000-000000000001"},
+			{Name: "<inaccessible>", UUID: "22222222-0000-4000-8000-000000000002"},
+		}))
+		Expect(vms[1].IsInaccessible()).To(BeTrue())
+	})
+
+	It("parses registered media", func() {
+		media := NewRegisteredMedia(`UUID:           a840e5e0-947c-4e63-ac2f-678a86d13980
+Parent UUID:    base
+State:          created
+Type:           normal (base)
+Location:       /store/disks/disk-1/disk.vmdk
+Storage format: VMDK
+Capacity:       1024 MBytes
+Encryption:     disabled
+
+UUID:           b840e5e0-947c-4e63-ac2f-678a86d13980
+Parent UUID:    a840e5e0-947c-4e63-ac2f-678a86d13980
+State:          created
+Type:           normal (differencing)
+Location:       /home/vcap/VirtualBox VMs/vm-1/Snapshots/{b840e5e0-947c-4e63-ac2f-678a86d13980}.vmdk
+Storage format: VMDK
+Capacity:       1024 MBytes
+Encryption:     disabled
+`)
+
+		Expect(media).To(Equal([]RegisteredMedium{
+			{
+				UUID:       "a840e5e0-947c-4e63-ac2f-678a86d13980",
+				Location:   "/store/disks/disk-1/disk.vmdk",
+				Format:     "VMDK",
+				CapacityMB: 1024,
+			},
+			{
+				UUID:       "b840e5e0-947c-4e63-ac2f-678a86d13980",
+				ParentUUID: "a840e5e0-947c-4e63-ac2f-678a86d13980",
+				Location:   "/home/vcap/VirtualBox VMs/vm-1/Snapshots/{b840e5e0-947c-4e63-ac2f-678a86d13980}.vmdk",
+				Format:     "VMDK",
+				CapacityMB: 1024,
+			},
+		}))
+	})
+})
@@ -1,9 +1,12 @@
 package driver
 
 import (
+	"math"
+	"math/rand"
 	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
 type RetryableError interface {
@@ -19,19 +22,90 @@ func (e RetryableErrorImpl) Error() string { return e.Err.Error() }
 
 type Retrier interface {
 	Retry(func() error) error
-	RetryComplex(func() error, int, time.Duration) error
+	RetryComplex(func() error, RetryPolicy) error
+
+	// Policy returns policy used by Retry
+	Policy() RetryPolicy
+}
+
+// RetryPolicy describes how many times and how long to wait
+// before retrying actions that failed with RetryableError.
+type RetryPolicy struct {
+	MaxAttempts int
+
+	// Backoff before n-th retry is InitialBackoff * Multiplier^(n-1) capped at MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Fraction (0..1) by which each backoff is randomly increased or decreased
+	Jitter float64
+
+	// Total time after which no more attempts are made (0 means no deadline)
+	Deadline time.Duration
+}
+
+// DefaultRetryPolicy waits the same time between attempts;
+// exponential backoff is enabled by Multiplier greater than 1.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    30,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     1,
+	}
+}
+
+// Backoff returns time to wait after given failed attempt (starting with 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+
+	if p.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(p.MaxBackoff))
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(backoff)
 }
 
-type RetrierImpl struct{}
+type RetrierImpl struct {
+	policy RetryPolicy
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewRetrierImpl(policy RetryPolicy, logger boshlog.Logger) RetrierImpl {
+	return RetrierImpl{
+		policy: policy,
+
+		logTag: "driver.RetrierImpl",
+		logger: logger,
+	}
+}
+
+func (r RetrierImpl) Policy() RetryPolicy { return r.policy }
 
 func (r RetrierImpl) Retry(actionFunc func() error) error {
-	return r.RetryComplex(actionFunc, 30, 2*time.Second)
+	return r.RetryComplex(actionFunc, r.policy)
 }
 
-func (RetrierImpl) RetryComplex(actionFunc func() error, times int, sleep time.Duration) error {
+func (r RetrierImpl) RetryComplex(actionFunc func() error, policy RetryPolicy) error {
 	var lastErr error
 
-	for i := 0; i < times; i++ {
+	startedAt := time.Now()
+	maxAttempts := policy.MaxAttempts
+
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
 		lastErr = actionFunc()
 		if lastErr == nil {
 			return nil
@@ -41,8 +115,18 @@ func (RetrierImpl) RetryComplex(actionFunc func() error, times int, sleep time.D
 			return bosherr.WrapError(lastErr, "Encountered non-retryable error")
 		}
 
-		time.Sleep(sleep)
-	}
+		if attempt >= maxAttempts {
+			return bosherr.WrapErrorf(lastErr, "Retried '%d' times", attempt)
+		}
+
+		backoff := policy.Backoff(attempt)
 
-	return bosherr.WrapErrorf(lastErr, "Retried '%d' times", times)
+		if policy.Deadline > 0 && time.Since(startedAt)+backoff > policy.Deadline {
+			return bosherr.WrapErrorf(lastErr, "Retried '%d' times until deadline of '%s'", attempt, policy.Deadline)
+		}
+
+		r.logger.Info(r.logTag, "Attempt %d of %d failed, retrying in %s: %s", attempt, maxAttempts, backoff, lastErr)
+
+		time.Sleep(backoff)
+	}
 }
@@ -0,0 +1,94 @@
+package driver_test
+
+import (
+	"errors"
+	"time"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "bosh-virtualbox-cpi/driver"
+)
+
+var _ = Describe("RetrierImpl", func() {
+	var (
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+	})
+
+	failingFunc := func(attempts *int) func() error {
+		return func() error {
+			*attempts++
+			return RetryableErrorImpl{Err: errors.New("fake-err")}
+		}
+	}
+
+	It("stops after max attempts", func() {
+		var attempts int
+
+		policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond}
+
+		err := NewRetrierImpl(policy, logger).Retry(failingFunc(&attempts))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Retried '4' times"))
+		Expect(attempts).To(Equal(4))
+	})
+
+	It("does not retry non-retryable errors", func() {
+		var attempts int
+
+		err := NewRetrierImpl(RetryPolicy{MaxAttempts: 4}, logger).Retry(func() error {
+			attempts++
+			return errors.New("fake-err")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(attempts).To(Equal(1))
+	})
+
+	It("stops when next attempt would happen after deadline", func() {
+		var attempts int
+
+		policy := RetryPolicy{
+			MaxAttempts:    100,
+			InitialBackoff: 20 * time.Millisecond,
+			Multiplier:     2,
+			Deadline:       100 * time.Millisecond,
+		}
+
+		err := NewRetrierImpl(policy, logger).Retry(failingFunc(&attempts))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("until deadline"))
+		Expect(attempts).To(Equal(3)) // waits 20ms and 40ms; 80ms would exceed deadline
+	})
+
+	Describe("RetryPolicy.Backoff", func() {
+		It("keeps default backoff fixed", func() {
+			policy := DefaultRetryPolicy()
+
+			Expect(policy.MaxAttempts).To(Equal(30))
+			Expect(policy.Backoff(1)).To(Equal(2 * time.Second))
+			Expect(policy.Backoff(10)).To(Equal(2 * time.Second))
+		})
+
+		It("grows exponentially up to max backoff", func() {
+			policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+
+			Expect(policy.Backoff(1)).To(Equal(1 * time.Second))
+			Expect(policy.Backoff(2)).To(Equal(2 * time.Second))
+			Expect(policy.Backoff(3)).To(Equal(4 * time.Second))
+			Expect(policy.Backoff(4)).To(Equal(5 * time.Second))
+		})
+
+		It("randomizes backoff within jitter", func() {
+			policy := RetryPolicy{InitialBackoff: time.Second, Jitter: 0.5}
+
+			for i := 0; i < 10; i++ {
+				Expect(policy.Backoff(1)).To(BeNumerically("~", time.Second, 500*time.Millisecond))
+			}
+		})
+	})
+})
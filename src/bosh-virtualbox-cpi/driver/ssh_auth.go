@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func (r *SSHRunner) authMethods(host SSHHostOpts) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	privateKey := host.PrivateKey
+
+	if len(host.PrivateKeyPath) > 0 {
+		path, err := r.fs.ExpandPath(host.PrivateKeyPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Expanding private key path '%s'", host.PrivateKeyPath)
+		}
+
+		privateKey, err = r.fs.ReadFileString(path)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading private key '%s'", path)
+		}
+	}
+
+	if len(privateKey) > 0 {
+		keySigner, err := r.parsePrivateKey([]byte(privateKey), host.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		methods = append(methods, ssh.PublicKeys(keySigner))
+	}
+
+	if host.UseAgent {
+		sockPath := os.Getenv("SSH_AUTH_SOCK")
+		if len(sockPath) == 0 {
+			return nil, bosherr.Error("Expected SSH_AUTH_SOCK to be set to use ssh-agent")
+		}
+
+		// Connection is left open for the lifetime of the runner
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Connecting to ssh-agent")
+		}
+
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if len(methods) == 0 {
+		return nil, bosherr.Error("Expected private key, private key path or ssh-agent to be configured")
+	}
+
+	return methods, nil
+}
+
+func (r *SSHRunner) parsePrivateKey(key []byte, passphrase string) (ssh.Signer, error) {
+	if len(passphrase) > 0 {
+		keySigner, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Parsing encrypted private key")
+		}
+
+		return keySigner, nil
+	}
+
+	keySigner, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, bosherr.WrapError(err, "Parsing private key (passphrase must be provided)")
+		}
+		return nil, bosherr.WrapError(err, "Parsing private key")
+	}
+
+	return keySigner, nil
+}
+
+// hostKeyCallback verifies host key against known_hosts file and/or pinned
+// fingerprint. Verification is skipped only when IgnoreHostKey is enabled.
+func (r *SSHRunner) hostKeyCallback(host SSHHostOpts) (ssh.HostKeyCallback, error) {
+	var callbacks []ssh.HostKeyCallback
+
+	if len(host.KnownHostsPath) > 0 {
+		path, err := r.fs.ExpandPath(host.KnownHostsPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Expanding known hosts path '%s'", host.KnownHostsPath)
+		}
+
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading known hosts '%s'", path)
+		}
+
+		callbacks = append(callbacks, callback)
+	}
+
+	if len(host.HostKeyFingerprint) > 0 {
+		callbacks = append(callbacks, sshFingerprintCallback(host.HostKeyFingerprint))
+	}
+
+	if len(callbacks) == 0 {
+		if !host.IgnoreHostKey {
+			return nil, bosherr.Errorf("Expected known hosts or host key fingerprint "+
+				"to be configured to verify host key of '%s'", host.Addr())
+		}
+
+		r.logger.Warn(r.logTag, "Host key verification for '%s' is disabled", host.Addr())
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, callback := range callbacks {
+			err := callback(hostname, remote, key)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Verifying host key of '%s'", hostname)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// sshFingerprintCallback accepts fingerprints as printed by ssh-keygen -l,
+// e.g. 'SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8' or 'MD5:16:27:ac:...'
+func sshFingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	fingerprint = strings.TrimSpace(fingerprint)
+	sha256 := strings.HasPrefix(fingerprint, "SHA256:")
+
+	if !sha256 {
+		fingerprint = strings.TrimPrefix(fingerprint, "MD5:")
+	}
+
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		actual := ssh.FingerprintLegacyMD5(key)
+		if sha256 {
+			actual = ssh.FingerprintSHA256(key)
+		}
+
+		if actual != fingerprint {
+			return bosherr.Errorf("Expected host key fingerprint '%s' but was '%s'", fingerprint, actual)
+		}
+
+		return nil
+	}
+}
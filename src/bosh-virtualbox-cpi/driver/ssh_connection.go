@@ -0,0 +1,204 @@
+package driver
+
+import (
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshDefaultKeepAliveInterval = 30 * time.Second
+)
+
+// session reconnects once if existing connection turns out to be dead.
+// Further attempts are left to the caller (e.g. Retrier used by ExecDriver).
+func (r *SSHRunner) session() (*ssh.Session, error) {
+	client, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := client.NewSession()
+	if err == nil {
+		return sess, nil
+	}
+
+	r.logger.Debug(r.logTag, "Reconnecting after failing to open SSH session: %s", err)
+	r.dropClient(client)
+
+	client, err = r.client()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err = client.NewSession()
+	if err != nil {
+		r.dropClient(client)
+		return nil, bosherr.WrapError(err, "Opening SSH session")
+	}
+
+	return sess, nil
+}
+
+// client dials immediately when there is no connection; backoff between
+// failed attempts is left to the caller's Retrier so that it is not doubled.
+func (r *SSHRunner) client() (*ssh.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.existingClient != nil {
+		return r.existingClient, nil
+	}
+
+	clients, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	r.hopClients = clients
+	r.existingClient = clients[len(clients)-1]
+
+	if interval := r.keepAliveInterval(); interval > 0 {
+		go r.keepAlive(r.existingClient, interval)
+	}
+
+	return r.existingClient, nil
+}
+
+// dial returns connections to all hops with the last one being the connection to the host
+func (r *SSHRunner) dial() ([]*ssh.Client, error) {
+	hops := append(append([]SSHHostOpts{}, r.opts.JumpHosts...), r.opts.SSHHostOpts)
+
+	var clients []*ssh.Client
+
+	closeAll := func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}
+
+	for _, hop := range hops {
+		config, err := r.clientConfig(hop)
+		if err != nil {
+			closeAll()
+			return nil, bosherr.WrapErrorf(err, "Configuring SSH connection to '%s'", hop.Addr())
+		}
+
+		if len(clients) == 0 {
+			client, err := ssh.Dial("tcp", hop.Addr(), config)
+			if err != nil {
+				return nil, bosherr.WrapErrorf(err, "Connecting via SSH to '%s'", hop.Addr())
+			}
+
+			clients = append(clients, client)
+			continue
+		}
+
+		// Previous hop's connection stays open since it carries this one
+		client, err := r.dialThrough(clients[len(clients)-1], hop.Addr(), config)
+		if err != nil {
+			closeAll()
+			return nil, bosherr.WrapErrorf(err, "Connecting via SSH to '%s' through jump host", hop.Addr())
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+func (r *SSHRunner) dialThrough(jumpClient *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := jumpClient.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// dropClient closes given connection (or current one if nil) so that
+// next use reconnects. Connections that were already dropped are ignored.
+func (r *SSHRunner) dropClient(client *ssh.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.existingClient == nil || (client != nil && client != r.existingClient) {
+		return
+	}
+
+	for i := len(r.hopClients) - 1; i >= 0; i-- {
+		r.hopClients[i].Close()
+	}
+
+	if r.sftp != nil {
+		r.sftp.Close()
+	}
+
+	r.existingClient = nil
+	r.hopClients = nil
+	r.sftp = nil
+	r.sftpMissing = false
+}
+
+func (r *SSHRunner) keepAliveInterval() time.Duration {
+	if r.opts.KeepAliveInterval == 0 {
+		return sshDefaultKeepAliveInterval
+	}
+	return r.opts.KeepAliveInterval
+}
+
+// keepAlive drops connection when it is closed or when server
+// does not reply to keepalive request within one interval
+func (r *SSHRunner) keepAlive(client *ssh.Client, interval time.Duration) {
+	closed := make(chan struct{})
+
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			r.dropClient(client)
+			return
+
+		case <-ticker.C:
+			replied := make(chan error, 1)
+
+			go func() {
+				// Servers reply with failure to unknown requests which is enough
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}()
+
+			select {
+			case err := <-replied:
+				if err != nil {
+					r.logger.Debug(r.logTag, "Dropping SSH connection after failed keepalive: %s", err)
+					r.dropClient(client)
+					return
+				}
+
+			case <-time.After(interval):
+				r.logger.Debug(r.logTag, "Dropping SSH connection that did not reply to keepalive within %s", interval)
+				r.dropClient(client)
+				return
+
+			case <-closed:
+				r.dropClient(client)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/pkg/sftp"
+)
+
+const (
+	// sshStatScript prints type and size of the file, exiting with
+	// sshStatNotExistStatus when it is missing; stat(1) differs between
+	// Linux and macOS hence only test(1) and wc(1) are used.
+	sshStatScript = `[ -e "$1" ] || exit 3; ` +
+		`if [ -d "$1" ]; then echo d 0; else echo f $(wc -c < "$1"); fi`
+
+	sshStatNotExistStatus = 3
+)
+
+func (r *SSHRunner) MkdirAll(path string) error {
+	r.logger.Debug(r.logTag, "MkdirAll '%s'", path)
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return err
+	}
+
+	if client != nil {
+		err = client.MkdirAll(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Creating directory '%s'", path)
+		}
+		return nil
+	}
+
+	_, _, err = r.execute(r.shCmd("mkdir", []string{"-p", path}, ""))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory '%s'", path)
+	}
+
+	return nil
+}
+
+func (r *SSHRunner) List(path string) ([]string, error) {
+	r.logger.Debug(r.logTag, "List '%s'", path)
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	if client != nil {
+		infos, err := client.ReadDir(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, newNotExistErr("list", path)
+			}
+			return nil, bosherr.WrapErrorf(err, "Listing '%s'", path)
+		}
+
+		for _, info := range infos {
+			if !strings.HasPrefix(info.Name(), ".") {
+				names = append(names, info.Name())
+			}
+		}
+	} else {
+		_, err := r.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		output, _, err := r.execute(r.shCmd("ls", []string{"-1", path}, ""))
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Listing '%s'", path)
+		}
+
+		for _, name := range strings.Split(output, "\n") {
+			if len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (r *SSHRunner) Stat(path string) (os.FileInfo, error) {
+	r.logger.Debug(r.logTag, "Stat '%s'", path)
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if client != nil {
+		info, err := client.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, newNotExistErr("stat", path)
+			}
+			return nil, bosherr.WrapErrorf(err, "Checking '%s'", path)
+		}
+		return info, nil
+	}
+
+	// Script is single-quoted since shCmd's escaping does not preserve '$'
+	output, status, err := r.execute(fmt.Sprintf("sh -c '%s' sh %s", sshStatScript, sshSingleQuote(path)))
+	if err != nil {
+		if status == sshStatNotExistStatus {
+			return nil, newNotExistErr("stat", path)
+		}
+		return nil, bosherr.WrapErrorf(err, "Checking '%s'", path)
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) < 2 {
+		return nil, bosherr.Errorf("Checking '%s': unexpected output '%s'", path, output)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Checking '%s': parsing size", path)
+	}
+
+	return sshFileInfo{name: filepath.Base(path), size: size, dir: fields[0] == "d"}, nil
+}
+
+// RemoveAll always uses shell since SFTP can only remove one entry at a time
+func (r *SSHRunner) RemoveAll(path string) error {
+	r.logger.Debug(r.logTag, "RemoveAll '%s'", path)
+
+	_, _, err := r.execute(r.shCmd("rm", []string{"-rf", path}, ""))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing '%s'", path)
+	}
+
+	return nil
+}
+
+func (r *SSHRunner) Rename(oldPath, newPath string) error {
+	r.logger.Debug(r.logTag, "Rename '%s' to '%s'", oldPath, newPath)
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return err
+	}
+
+	if client != nil {
+		return r.renameViaSFTP(client, oldPath, newPath)
+	}
+
+	_, _, err = r.execute(r.shCmd("mv", []string{"-f", oldPath, newPath}, ""))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming '%s' to '%s'", oldPath, newPath)
+	}
+
+	return nil
+}
+
+func (r *SSHRunner) renameViaSFTP(client *sftp.Client, oldPath, newPath string) error {
+	err := client.PosixRename(oldPath, newPath)
+	if err != nil {
+		// Server might not support posix-rename@openssh.com extension
+		r.logger.Debug(r.logTag, "Falling back to remove and rename: %s", err)
+
+		client.Remove(newPath)
+
+		err = client.Rename(oldPath, newPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Renaming '%s' to '%s'", oldPath, newPath)
+		}
+	}
+
+	return nil
+}
+
+// sshFileInfo is returned when SFTP is not available
+type sshFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i sshFileInfo) Name() string       { return i.name }
+func (i sshFileInfo) Size() int64        { return i.size }
+func (i sshFileInfo) ModTime() time.Time { return time.Time{} }
+func (i sshFileInfo) IsDir() bool        { return i.dir }
+func (i sshFileInfo) Sys() interface{}   { return nil }
+
+func (i sshFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshLockScript holds lock on a file for as long as its stdin is open,
+// so lock is also released when SSH connection is lost. Waiting for
+// the lock gives up after given number of seconds.
+// flock(1) is not available on macOS hence perl is used as a fallback.
+const sshLockScript = `exec 9>>"$1" || exit 1; ` +
+	`if command -v flock >/dev/null 2>&1; then flock -w "$2" 9; ` +
+	`else perl -MFcntl=:flock -MTime::HiRes=time -e "open(F, q(>&=9)) || die; \$d = time + \$ARGV[0]; ` +
+	`until (flock(F, LOCK_EX | LOCK_NB)) { time < \$d || exit 1; select(undef, undef, undef, 0.1) }" "$2"; fi || exit 1; ` +
+	`echo locked; cat >/dev/null`
+
+func (r *SSHRunner) Lock(path string, timeout time.Duration) (Lock, error) {
+	r.logger.Debug(r.logTag, "Lock '%s'", path)
+
+	sess, err := r.session()
+	if err != nil {
+		return nil, err
+	}
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		sess.Close()
+		return nil, bosherr.WrapError(err, "Opening stdin")
+	}
+
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		sess.Close()
+		return nil, bosherr.WrapError(err, "Opening stdout")
+	}
+
+	var stderr bytes.Buffer
+	sess.Stderr = &stderr
+
+	// Lock script is given timeout in whole seconds
+	timeoutSecs := int(math.Ceil(timeout.Seconds()))
+	if timeoutSecs < 1 {
+		timeoutSecs = 1
+	}
+
+	startedAt := time.Now()
+
+	// Script is single-quoted since shCmd's escaping does not preserve '$'
+	err = sess.Start(fmt.Sprintf("sh -c '%s' sh %s %d", sshLockScript, sshSingleQuote(path), timeoutSecs))
+	if err != nil {
+		sess.Close()
+		return nil, bosherr.WrapErrorf(err, "Locking '%s'", path)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "locked" {
+		stdin.Close()
+		sess.Wait()
+		sess.Close()
+
+		if time.Since(startedAt) >= time.Duration(timeoutSecs)*time.Second {
+			return nil, bosherr.Errorf("Timed out after %s waiting for lock '%s'", timeout, path)
+		}
+
+		return nil, bosherr.Errorf("Locking '%s' (Output: '%s')", path, line+stderr.String())
+	}
+
+	return sshLock{sess, stdin}, nil
+}
+
+func sshSingleQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+type sshLock struct {
+	sess  *ssh.Session
+	stdin io.WriteCloser
+}
+
+func (l sshLock) Unlock() error {
+	defer l.sess.Close()
+
+	err := l.stdin.Close()
+	if err != nil {
+		return bosherr.WrapError(err, "Closing lock stdin")
+	}
+
+	err = l.sess.Wait()
+	if err != nil {
+		return bosherr.WrapError(err, "Waiting for lock to be released")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+)
+
+var (
+	sshPID = regexp.MustCompile(`^\d+$`)
+)
+
+// sshPIDWriter strips the first line containing remote PID from command's stdout
+type sshPIDWriter struct {
+	w io.Writer
+
+	mu   sync.Mutex
+	buf  []byte
+	pid  string
+	done bool
+}
+
+func newSSHPIDWriter(w io.Writer) *sshPIDWriter {
+	return &sshPIDWriter{w: w}
+}
+
+func (w *sshPIDWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done {
+		return w.w.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+
+	idx := bytes.IndexByte(w.buf, '\n')
+	if idx < 0 {
+		return len(p), nil
+	}
+
+	w.done = true
+
+	if line := string(bytes.TrimSpace(w.buf[:idx])); sshPID.MatchString(line) {
+		w.pid = line
+	}
+
+	_, err := w.w.Write(w.buf[idx+1:])
+	w.buf = nil
+
+	return len(p), err
+}
+
+func (w *sshPIDWriter) PID() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pid
+}
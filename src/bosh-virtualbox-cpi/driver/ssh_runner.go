@@ -2,15 +2,21 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -22,17 +28,56 @@ type SSHRunner struct {
 	logTag string
 	logger boshlog.Logger
 
+	// Guards connection state since connections are checked in background
+	mu             sync.Mutex
 	existingClient *ssh.Client
+	hopClients     []*ssh.Client // includes existingClient
+
+	sftp        *sftp.Client
+	sftpMissing bool
 }
 
 type SSHRunnerOpts struct {
-	Host       string
-	Username   string
-	PrivateKey string
+	SSHHostOpts
+
+	// Connection to the host is tunneled through jump hosts
+	// in the given order, similarly to OpenSSH's ProxyJump
+	JumpHosts []SSHHostOpts
+
+	// Unresponsive connections are dropped and reestablished on next use.
+	// Defaults to 30 seconds; negative value disables keepalive requests.
+	KeepAliveInterval time.Duration
+}
+
+type SSHHostOpts struct {
+	Host     string
+	Port     int // defaults to 22
+	Username string
+
+	// At least one way to authenticate must be provided
+	PrivateKey           string
+	PrivateKeyPath       string
+	PrivateKeyPassphrase string
+	UseAgent             bool // uses SSH_AUTH_SOCK
+
+	// Host key is verified against both if both are provided
+	KnownHostsPath     string
+	HostKeyFingerprint string
+
+	// Host key is only left unverified when explicitly requested
+	IgnoreHostKey bool
+}
+
+func (o SSHHostOpts) Addr() string {
+	port := o.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
 }
 
 func NewSSHRunner(opts SSHRunnerOpts, fs boshsys.FileSystem, logger boshlog.Logger) *SSHRunner {
-	return &SSHRunner{opts, fs, "driver.SSHRunner", logger, nil}
+	return &SSHRunner{opts: opts, fs: fs, logTag: "driver.SSHRunner", logger: logger}
 }
 
 func (r *SSHRunner) HomeDir() (string, error) {
@@ -49,10 +94,20 @@ func (r *SSHRunner) HomeDir() (string, error) {
 }
 
 func (r *SSHRunner) Execute(path string, args ...string) (string, int, error) {
-	return r.execute(r.shCmd(path, args, ""))
+	return r.ExecuteContext(context.Background(), path, args...)
+}
+
+func (r *SSHRunner) ExecuteContext(ctx context.Context, path string, args ...string) (string, int, error) {
+	return r.executeContext(ctx, r.killableShCmd(path, args), true)
 }
 
 func (r *SSHRunner) execute(cmd string) (string, int, error) {
+	return r.executeContext(context.Background(), cmd, false)
+}
+
+// executeContext kills remote process when ctx is done if command is killable,
+// i.e. it prints its PID on the first line of its stdout (see killableShCmd)
+func (r *SSHRunner) executeContext(ctx context.Context, cmd string, killable bool) (string, int, error) {
 	r.logger.Debug(r.logTag, "Execute '%s'", cmd)
 
 	sess, err := r.session()
@@ -63,10 +118,29 @@ func (r *SSHRunner) execute(cmd string) (string, int, error) {
 	defer sess.Close()
 
 	var stderr, stdout bytes.Buffer
+	pidWriter := newSSHPIDWriter(&stdout)
+
 	sess.Stdout = &stdout
+	if killable {
+		sess.Stdout = pidWriter
+	}
 	sess.Stderr = &stderr
 
-	err = sess.Run(cmd)
+	errCh := make(chan error, 1)
+
+	go func() { errCh <- sess.Run(cmd) }()
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		r.kill(pidWriter.PID())
+		sess.Close()
+		<-errCh
+
+		output := stdout.String() + "\n" + stderr.String()
+		return output, -1, bosherr.WrapErrorf(ctx.Err(), "Running '%s'", cmd)
+	}
+
 	output := stdout.String() + "\n" + stderr.String()
 
 	if err == nil {
@@ -75,15 +149,37 @@ func (r *SSHRunner) execute(cmd string) (string, int, error) {
 
 	switch typedErr := err.(type) {
 	case *ssh.ExitMissingError:
-		return output, 0, bosherr.WrapError(typedErr, "Missing exit info")
+		// Usually happens when connection is lost while command is running
+		r.dropClient(nil)
+		return output, -1, CommandOutcomeUnknownError{Cmd: cmd, Err: typedErr}
 	case *ssh.ExitError:
 		status := typedErr.Waitmsg.ExitStatus()
 		return output, status, bosherr.WrapErrorf(typedErr, "Exit (Output: '%s')", output)
 	default:
+		r.dropClient(nil)
 		return output, 0, bosherr.WrapErrorf(typedErr, "Unknown SSH error (Output: '%s')", output)
 	}
 }
 
+// kill terminates remote process since SSH servers do not reliably
+// deliver signals nor kill processes when sessions are closed
+func (r *SSHRunner) kill(pid string) {
+	if len(pid) == 0 {
+		r.logger.Error(r.logTag, "Cannot kill remote process with unknown PID")
+		return
+	}
+
+	r.logger.Debug(r.logTag, "Killing remote process '%s'", pid)
+
+	script := fmt.Sprintf(
+		"kill -TERM %[1]s; for i in 1 2 3 4 5; do kill -0 %[1]s 2>/dev/null || exit 0; sleep 1; done; kill -KILL %[1]s", pid)
+
+	_, _, err := r.execute(r.shCmd("sh", []string{"-c", script}, ""))
+	if err != nil {
+		r.logger.Error(r.logTag, "Failed to kill remote process '%s': %s", pid, err)
+	}
+}
+
 func (r *SSHRunner) Upload(srcPath, dstPath string) error {
 	r.logger.Debug(r.logTag, "Upload from '%s' to '%s'", srcPath, dstPath)
 
@@ -94,14 +190,42 @@ func (r *SSHRunner) Upload(srcPath, dstPath string) error {
 
 	defer file.Close()
 
+	client, err := r.sftpClient()
+	if err != nil {
+		return err
+	}
+
+	if client != nil {
+		err = r.uploadViaSFTP(client, file, dstPath)
+		if err != nil {
+			r.dropClient(nil) // partial upload is continued on next attempt
+		}
+		return err
+	}
+
 	return r.putFromReader(dstPath, file)
 }
 
 func (r *SSHRunner) Put(path string, contents []byte) error {
 	r.logger.Debug(r.logTag, "Put to '%s' %d ", path, len(contents))
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return err
+	}
+
+	if client != nil {
+		err = r.putViaSFTP(client, path, bytes.NewReader(contents), 0644)
+		if err != nil {
+			r.dropClient(nil)
+		}
+		return err
+	}
+
 	return r.putFromReader(path, bytes.NewBuffer(contents))
 }
 
+// putFromReader is used when SFTP is not available
 func (r *SSHRunner) putFromReader(path string, in io.Reader) error {
 	sess, err := r.session()
 	if err != nil {
@@ -112,17 +236,28 @@ func (r *SSHRunner) putFromReader(path string, in io.Reader) error {
 
 	sess.Stdin = in
 
-	err = sess.Run(r.shCmd("cat", nil, path))
+	tmpPath := path + sshPartialFileSuffix
+
+	err = sess.Run(r.shCmd("cat", nil, tmpPath))
 	if err != nil {
 		return bosherr.WrapError(err, "Putting file")
 	}
 
-	return nil
+	return r.Rename(tmpPath, path)
 }
 
 func (r *SSHRunner) Get(path string) ([]byte, error) {
 	r.logger.Debug(r.logTag, "Get '%s'", path)
 
+	client, err := r.sftpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if client != nil {
+		return r.getViaSFTP(client, path)
+	}
+
 	sess, err := r.session()
 	if err != nil {
 		return nil, err
@@ -135,51 +270,61 @@ func (r *SSHRunner) Get(path string) ([]byte, error) {
 
 	err = sess.Run(r.shCmd("cat", []string{path}, ""))
 	if err != nil {
+		if _, statErr := r.Stat(path); IsNotExistErr(statErr) {
+			return nil, newNotExistErr("open", path)
+		}
 		return nil, bosherr.WrapError(err, "Getting file")
 	}
 
 	return stdout.Bytes(), err
 }
 
-func (r *SSHRunner) session() (*ssh.Session, error) {
-	client, err := r.client()
+// Append is done via shell even when SFTP is available since SFTP writes
+// carry explicit offsets and not all servers honor append flag, hence
+// concurrent appends (e.g. to audit log) could overwrite each other.
+func (r *SSHRunner) Append(path string, contents []byte) error {
+	r.logger.Debug(r.logTag, "Append to '%s' %d", path, len(contents))
+
+	sess, err := r.session()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	sess, err := client.NewSession()
+	defer sess.Close()
+
+	sess.Stdin = bytes.NewReader(contents)
+
+	err = sess.Run(fmt.Sprintf("cat >> %s", sshSingleQuote(path)))
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Opening SSH session")
+		return bosherr.WrapErrorf(err, "Appending to '%s'", path)
 	}
 
-	return sess, nil
+	return nil
 }
 
-func (r *SSHRunner) client() (*ssh.Client, error) {
-	if r.existingClient != nil {
-		return r.existingClient, nil
+func (r *SSHRunner) clientConfig(host SSHHostOpts) (*ssh.ClientConfig, error) {
+	authMethods, err := r.authMethods(host)
+	if err != nil {
+		return nil, err
 	}
 
-	keySigner, err := ssh.ParsePrivateKey([]byte(r.opts.PrivateKey))
+	hostKeyCallback, err := r.hostKeyCallback(host)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Parsing private key")
+		return nil, err
 	}
 
 	config := &ssh.ClientConfig{
-		User:            r.opts.Username,
-		Auth:            []ssh.AuthMethod{ssh.PublicKeys(keySigner)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	r.existingClient, err = ssh.Dial("tcp", fmt.Sprintf("%s:22", r.opts.Host), config)
-	if err != nil {
-		return nil, bosherr.WrapError(err, "Connecting via SSH")
+		User:            host.Username,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
 	}
 
-	return r.existingClient, nil
+	return config, nil
 }
 
-func (r SSHRunner) shCmd(path string, args []string, stdoutPath string) string {
+const sshEnvPath = "PATH=$PATH:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+
+func (r *SSHRunner) shCmd(path string, args []string, stdoutPath string) string {
 	escapedCmd := r.shellJoin(append([]string{path}, args...))
 	stdoutRedir := ""
 
@@ -187,12 +332,17 @@ func (r SSHRunner) shCmd(path string, args []string, stdoutPath string) string {
 		stdoutRedir = "> " + r.shellEscape(stdoutPath)
 	}
 
-	envPath := "PATH=$PATH:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+	return fmt.Sprintf(`sh -c "%s %s %s"`, sshEnvPath, escapedCmd, stdoutRedir)
+}
+
+// killableShCmd prints PID first so that command can be killed (exec keeps the same PID)
+func (r *SSHRunner) killableShCmd(path string, args []string) string {
+	escapedCmd := r.shellJoin(append([]string{path}, args...))
 
-	return fmt.Sprintf(`sh -c "%s %s %s"`, envPath, escapedCmd, stdoutRedir)
+	return fmt.Sprintf(`sh -c "echo \$\$; export %s; exec %s"`, sshEnvPath, escapedCmd)
 }
 
-func (r SSHRunner) shellJoin(args []string) string {
+func (r *SSHRunner) shellJoin(args []string) string {
 	var escapedArgs []string
 	for _, arg := range args {
 		escapedArgs = append(escapedArgs, r.shellEscape(arg))
@@ -206,7 +356,7 @@ var (
 )
 
 // http://ruby-doc.org/stdlib-2.0.0/libdoc/shellwords/rdoc/Shellwords.html#method-c-shelljoin
-func (SSHRunner) shellEscape(arg string) string {
+func (*SSHRunner) shellEscape(arg string) string {
 	if len(arg) == 0 {
 		return "''"
 	}
@@ -1,11 +1,28 @@
 package driver_test
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	. "bosh-virtualbox-cpi/driver"
 )
@@ -29,9 +46,13 @@ var _ = Describe("SSHRunner", func() {
 	Context("HomeDir", func() {
 		It("returns proper home directory", func() {
 			opts := SSHRunnerOpts{
-				Host:       testSSHRunnerHost,
-				Username:   testSSHRunnerUsername,
-				PrivateKey: testSSHRunnerPrivateKey,
+				SSHHostOpts: SSHHostOpts{
+					Host:       testSSHRunnerHost,
+					Username:   testSSHRunnerUsername,
+					PrivateKey: testSSHRunnerPrivateKey,
+
+					IgnoreHostKey: true,
+				},
 			}
 			logger := boshlog.NewLogger(boshlog.LevelNone)
 			runner := NewSSHRunner(opts, nil, logger)
@@ -43,3 +64,576 @@ var _ = Describe("SSHRunner", func() {
 		})
 	})
 })
+
+var _ = Describe("SSHRunner against local SSH server", func() {
+	var (
+		server   *sshServer
+		key      *rsa.PrivateKey
+		tmpDir   string
+		logger   boshlog.Logger
+		fs       boshsys.FileSystem
+		baseOpts SSHRunnerOpts
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		server = newSSHServer()
+
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+
+		pubKey, err := ssh.NewPublicKey(&key.PublicKey)
+		Expect(err).ToNot(HaveOccurred())
+
+		server.Authorize(pubKey)
+
+		tmpDir, err = ioutil.TempDir("", "ssh-runner")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		fs = boshsys.NewOsFileSystem(logger)
+
+		baseOpts = SSHRunnerOpts{
+			SSHHostOpts: SSHHostOpts{
+				Host:     server.Host(),
+				Port:     server.Port(),
+				Username: "vcap",
+
+				HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey()),
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	keyPEM := func() string {
+		block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		return string(pem.EncodeToMemory(block))
+	}
+
+	encryptedKeyPEM := func(passphrase string) string {
+		// Same format as produced by 'ssh-keygen -m PEM'
+		block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY",
+			x509.MarshalPKCS1PrivateKey(key), []byte(passphrase), x509.PEMCipherAES256)
+		Expect(err).ToNot(HaveOccurred())
+		return string(pem.EncodeToMemory(block))
+	}
+
+	expectExecutes := func(opts SSHRunnerOpts) {
+		output, status, err := NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(status).To(Equal(0))
+		Expect(output).To(ContainSubstring("hello"))
+	}
+
+	It("connects on configured port when host key matches pinned fingerprint", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		expectExecutes(opts)
+	})
+
+	It("accepts legacy MD5 fingerprint", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		opts.HostKeyFingerprint = "MD5:" + ssh.FingerprintLegacyMD5(server.HostKey())
+		expectExecutes(opts)
+	})
+
+	It("refuses to connect when host key does not match pinned fingerprint", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		opts.HostKeyFingerprint = "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+
+		_, _, err := NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected host key fingerprint"))
+		Expect(server.Execs()).To(BeEmpty())
+	})
+
+	It("refuses to connect when host key cannot be verified", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		opts.HostKeyFingerprint = ""
+
+		_, _, err := NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected known hosts or host key fingerprint"))
+		Expect(server.Execs()).To(BeEmpty())
+
+		opts.IgnoreHostKey = true
+		expectExecutes(opts)
+	})
+
+	It("verifies host key against known hosts file", func() {
+		knownHostsPath := filepath.Join(tmpDir, "known_hosts")
+		line := knownhosts.Line([]string{knownhosts.Normalize(baseOpts.Addr())}, server.HostKey())
+
+		err := ioutil.WriteFile(knownHostsPath, []byte(line+"\n"), 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		opts.HostKeyFingerprint = ""
+		opts.KnownHostsPath = knownHostsPath
+		expectExecutes(opts)
+
+		otherKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		Expect(err).ToNot(HaveOccurred())
+
+		otherPubKey, err := ssh.NewPublicKey(&otherKey.PublicKey)
+		Expect(err).ToNot(HaveOccurred())
+
+		line = knownhosts.Line([]string{knownhosts.Normalize(baseOpts.Addr())}, otherPubKey)
+
+		err = ioutil.WriteFile(knownHostsPath, []byte(line+"\n"), 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, err = NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Verifying host key"))
+	})
+
+	It("reads encrypted private key from file", func() {
+		keyPath := filepath.Join(tmpDir, "id_rsa")
+
+		err := ioutil.WriteFile(keyPath, []byte(encryptedKeyPEM("secret")), 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		opts := baseOpts
+		opts.PrivateKeyPath = keyPath
+		opts.PrivateKeyPassphrase = "secret"
+		expectExecutes(opts)
+
+		opts.PrivateKeyPassphrase = ""
+
+		_, _, err = NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("passphrase must be provided"))
+	})
+
+	It("tunnels connection through jump hosts in order", func() {
+		jumpServers := []*sshServer{newSSHServer(), newSSHServer()}
+
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+
+		for _, jumpServer := range jumpServers {
+			defer jumpServer.Close()
+
+			// Each jump host has its own credentials and host key
+			jumpKey, err := rsa.GenerateKey(rand.Reader, 1024)
+			Expect(err).ToNot(HaveOccurred())
+
+			jumpPubKey, err := ssh.NewPublicKey(&jumpKey.PublicKey)
+			Expect(err).ToNot(HaveOccurred())
+
+			jumpServer.Authorize(jumpPubKey)
+
+			block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(jumpKey)}
+
+			opts.JumpHosts = append(opts.JumpHosts, SSHHostOpts{
+				Host:     jumpServer.Host(),
+				Port:     jumpServer.Port(),
+				Username: "jump",
+
+				PrivateKey:         string(pem.EncodeToMemory(block)),
+				HostKeyFingerprint: ssh.FingerprintSHA256(jumpServer.HostKey()),
+			})
+		}
+
+		expectExecutes(opts)
+
+		Expect(jumpServers[0].Forwards()).To(Equal([]string{opts.JumpHosts[1].Addr()}))
+		Expect(jumpServers[1].Forwards()).To(Equal([]string{opts.Addr()}))
+		Expect(jumpServers[0].Execs()).To(BeEmpty())
+		Expect(server.Execs()).To(HaveLen(1))
+	})
+
+	It("refuses to connect through jump host with unexpected host key", func() {
+		jumpServer := newSSHServer()
+		defer jumpServer.Close()
+
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+		opts.JumpHosts = []SSHHostOpts{{
+			Host:     jumpServer.Host(),
+			Port:     jumpServer.Port(),
+			Username: "jump",
+
+			PrivateKey:         keyPEM(),
+			HostKeyFingerprint: ssh.FingerprintSHA256(server.HostKey()),
+		}}
+
+		_, _, err := NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Expected host key fingerprint"))
+		Expect(server.Execs()).To(BeEmpty())
+	})
+
+	It("strips remote PID from command output", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+
+		output, _, err := NewSSHRunner(opts, fs, logger).Execute("echo", "hello")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal("hello\n\n"))
+	})
+
+	It("kills remote command when context is done", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		startedAt := time.Now()
+
+		_, status, err := NewSSHRunner(opts, fs, logger).ExecuteContext(ctx, "sleep", "29.123")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("context deadline exceeded"))
+		Expect(status).To(Equal(-1))
+		Expect(time.Since(startedAt)).To(BeNumerically("<", 10*time.Second))
+
+		err = exec.Command("pgrep", "-f", "sleep 29.123").Run()
+		Expect(err).To(HaveOccurred()) // no matching processes
+	})
+
+	It("holds remote lock until it is released", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+
+		path := filepath.Join(tmpDir, "test.lock")
+
+		lock, err := NewSSHRunner(opts, fs, logger).Lock(path, time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+
+		acquired := make(chan Lock)
+
+		go func() {
+			defer GinkgoRecover()
+
+			lock2, err := NewSSHRunner(opts, fs, logger).Lock(path, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			acquired <- lock2
+		}()
+
+		Consistently(acquired, 300*time.Millisecond).ShouldNot(Receive())
+
+		Expect(lock.Unlock()).To(Succeed())
+
+		var lock2 Lock
+		Eventually(acquired, 5*time.Second).Should(Receive(&lock2))
+		Expect(lock2.Unlock()).To(Succeed())
+	})
+
+	It("gives up waiting for remote lock after timeout", func() {
+		opts := baseOpts
+		opts.PrivateKey = keyPEM()
+
+		path := filepath.Join(tmpDir, "test.lock")
+
+		lock, err := NewSSHRunner(opts, fs, logger).Lock(path, time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+
+		defer lock.Unlock()
+
+		_, err = NewSSHRunner(opts, fs, logger).Lock(path, time.Second)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Timed out after 1s waiting for lock"))
+	})
+
+	Describe("connection failures", func() {
+		newRunner := func() *SSHRunner {
+			opts := baseOpts
+			opts.PrivateKey = keyPEM()
+			opts.KeepAliveInterval = 50 * time.Millisecond
+			return NewSSHRunner(opts, fs, logger)
+		}
+
+		It("reconnects when connection was dropped", func() {
+			runner := newRunner()
+
+			_, _, err := runner.Execute("echo", "hello")
+			Expect(err).ToNot(HaveOccurred())
+
+			server.DropConnections()
+
+			output, _, err := runner.Execute("echo", "again")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(ContainSubstring("again"))
+			Expect(server.Connections()).To(Equal(2))
+		})
+
+		It("keeps using connection that replies to keepalive requests", func() {
+			runner := newRunner()
+
+			_, _, err := runner.Execute("echo", "hello")
+			Expect(err).ToNot(HaveOccurred())
+
+			time.Sleep(300 * time.Millisecond)
+
+			_, _, err = runner.Execute("echo", "again")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Connections()).To(Equal(1))
+		})
+
+		It("replaces connection that stops replying to keepalive requests", func() {
+			runner := newRunner()
+
+			_, _, err := runner.Execute("echo", "hello")
+			Expect(err).ToNot(HaveOccurred())
+
+			server.IgnoreKeepAlives = true
+			time.Sleep(300 * time.Millisecond)
+			server.IgnoreKeepAlives = false
+
+			_, _, err = runner.Execute("echo", "again")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Connections()).To(Equal(2))
+		})
+
+		It("returns error when host cannot be reached so that caller can retry later", func() {
+			runner := newRunner()
+
+			_, _, err := runner.Execute("echo", "hello")
+			Expect(err).ToNot(HaveOccurred())
+
+			server.Close()
+			server.DropConnections()
+
+			_, status, err := runner.Execute("echo", "again")
+			Expect(err).To(HaveOccurred())
+			Expect(status).To(Equal(0))
+		})
+
+		It("leaves waiting between connection attempts to the caller", func() {
+			runner := newRunner()
+
+			server.Close()
+
+			startedAt := time.Now()
+
+			for i := 0; i < 3; i++ {
+				_, _, err := runner.Execute("echo", "hello")
+				Expect(err).To(HaveOccurred())
+			}
+
+			Expect(time.Since(startedAt)).To(BeNumerically("<", time.Second))
+		})
+
+		It("reports unknown outcome of command whose connection was lost while it was running", func() {
+			runner := newRunner()
+
+			go func() {
+				time.Sleep(200 * time.Millisecond)
+				server.DropConnections()
+			}()
+
+			_, status, err := runner.Execute("sleep", "2")
+			Expect(err).To(HaveOccurred())
+			Expect(IsCommandOutcomeUnknownErr(err)).To(BeTrue())
+			Expect(status).To(Equal(-1))
+		})
+	})
+
+	Describe("file transfer", func() {
+		var (
+			runner  *SSHRunner
+			srcPath string
+			dstPath string
+		)
+
+		BeforeEach(func() {
+			srcPath = filepath.Join(tmpDir, "src")
+			dstPath = filepath.Join(tmpDir, "dst")
+
+			err := ioutil.WriteFile(srcPath, []byte("stemcell-contents"), 0640)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		newRunner := func() *SSHRunner {
+			opts := baseOpts
+			opts.PrivateKey = keyPEM()
+			return NewSSHRunner(opts, fs, logger)
+		}
+
+		expectTransfers := func() {
+			runner = newRunner()
+
+			err := runner.Upload(srcPath, dstPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("stemcell-contents"))
+
+			err = runner.Put(dstPath, []byte("put-contents"))
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err = runner.Get(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("put-contents"))
+
+			err = runner.Append(dstPath, []byte("-appended"))
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err = runner.Get(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("put-contents-appended"))
+
+			Expect(dstPath + ".part").ToNot(BeAnExistingFile())
+		}
+
+		It("transfers files via SFTP", func() {
+			expectTransfers()
+			Expect(server.Execs()).To(Equal([]string{"cat >> '" + dstPath + "'"}))
+
+			err := runner.Upload(srcPath, dstPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			info, err := os.Stat(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+		})
+
+		It("does not lose any of concurrent appends", func() {
+			runner = newRunner()
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					err := runner.Append(dstPath, []byte(fmt.Sprintf("line-%d\n", i)))
+					Expect(err).ToNot(HaveOccurred())
+				}(i)
+			}
+
+			wg.Wait()
+
+			contents, err := ioutil.ReadFile(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.Split(strings.TrimSpace(string(contents)), "\n")).To(HaveLen(10))
+		})
+
+		It("continues interrupted upload from partially uploaded file", func() {
+			err := ioutil.WriteFile(dstPath+".part", []byte("STEMCELL"), 0640)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = newRunner().Upload(srcPath, dstPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			// Only the remaining part was uploaded
+			contents, err := ioutil.ReadFile(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("STEMCELL-contents"))
+		})
+
+		It("starts upload from scratch when partially uploaded file is larger than source", func() {
+			err := ioutil.WriteFile(dstPath+".part", []byte("stemcell-contents-from-other-upload"), 0640)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = newRunner().Upload(srcPath, dstPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(dstPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("stemcell-contents"))
+		})
+
+		It("falls back to transferring files via cat when server does not have SFTP subsystem", func() {
+			server.NoSFTP = true
+			expectTransfers()
+			Expect(server.Execs()).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("file operations", func() {
+		expectFileOps := func() {
+			opts := baseOpts
+			opts.PrivateKey = keyPEM()
+			runner := NewSSHRunner(opts, fs, logger)
+
+			dirPath := filepath.Join(tmpDir, "a b", "c")
+
+			_, err := runner.Stat(dirPath)
+			Expect(os.IsNotExist(err)).To(BeTrue(), "%s", err)
+
+			_, err = runner.List(dirPath)
+			Expect(os.IsNotExist(err)).To(BeTrue(), "%s", err)
+
+			Expect(runner.MkdirAll(dirPath)).To(Succeed())
+			Expect(runner.Put(filepath.Join(dirPath, "file"), []byte("contents"))).To(Succeed())
+			Expect(runner.Put(filepath.Join(dirPath, ".hidden"), nil)).To(Succeed())
+
+			info, err := runner.Stat(dirPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.IsDir()).To(BeTrue())
+
+			info, err = runner.Stat(filepath.Join(dirPath, "file"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.IsDir()).To(BeFalse())
+			Expect(info.Size()).To(Equal(int64(8)))
+
+			Expect(runner.Rename(filepath.Join(dirPath, "file"), filepath.Join(dirPath, "renamed"))).To(Succeed())
+
+			names, err := runner.List(dirPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(names).To(Equal([]string{"renamed"}))
+
+			Expect(runner.RemoveAll(filepath.Join(tmpDir, "a b"))).To(Succeed())
+			Expect(filepath.Join(tmpDir, "a b")).ToNot(BeAnExistingFile())
+
+			Expect(runner.RemoveAll(filepath.Join(tmpDir, "a b"))).To(Succeed())
+		}
+
+		It("manages files via SFTP", func() {
+			expectFileOps()
+		})
+
+		It("falls back to shell when server does not have SFTP subsystem", func() {
+			server.NoSFTP = true
+			expectFileOps()
+		})
+	})
+
+	It("authenticates with keys from ssh-agent", func() {
+		keyring := agent.NewKeyring()
+
+		err := keyring.Add(agent.AddedKey{PrivateKey: key})
+		Expect(err).ToNot(HaveOccurred())
+
+		sockPath := filepath.Join(tmpDir, "agent.sock")
+
+		listener, err := net.Listen("unix", sockPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		defer listener.Close()
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go agent.ServeAgent(keyring, conn)
+			}
+		}()
+
+		prevSock := os.Getenv("SSH_AUTH_SOCK")
+		os.Setenv("SSH_AUTH_SOCK", sockPath)
+		defer os.Setenv("SSH_AUTH_SOCK", prevSock)
+
+		opts := baseOpts
+		opts.UseAgent = true
+		expectExecutes(opts)
+	})
+})
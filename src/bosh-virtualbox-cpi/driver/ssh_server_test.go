@@ -0,0 +1,259 @@
+package driver_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServer is a minimal SSH server that runs exec requests via local sh
+type sshServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	// Simulates servers without SFTP subsystem
+	NoSFTP bool
+	// Simulates connections that silently stop working
+	IgnoreKeepAlives bool
+
+	mu             sync.Mutex
+	authorizedKeys []ssh.PublicKey
+	execs          []string
+	forwards       []string
+	conns          []net.Conn
+}
+
+func newSSHServer() *sshServer {
+	_, hostPrivKey, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	hostKey, err := ssh.NewSignerFromKey(hostPrivKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	s := &sshServer{listener: listener, hostKey: hostKey}
+
+	go s.serve()
+
+	return s
+}
+
+func (s *sshServer) Authorize(key ssh.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizedKeys = append(s.authorizedKeys, key)
+}
+
+func (s *sshServer) Execs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.execs...)
+}
+
+func (s *sshServer) Forwards() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.forwards...)
+}
+
+// Connections returns number of accepted connections so far
+func (s *sshServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// DropConnections simulates network failure of established connections
+func (s *sshServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *sshServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+func (s *sshServer) Port() int { return s.listener.Addr().(*net.TCPAddr).Port }
+
+func (s *sshServer) HostKey() ssh.PublicKey { return s.hostKey.PublicKey() }
+
+func (s *sshServer) Close() { s.listener.Close() }
+
+func (s *sshServer) serve() {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			for _, authorizedKey := range s.authorizedKeys {
+				if string(authorizedKey.Marshal()) == string(key.Marshal()) {
+					return nil, nil
+				}
+			}
+
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handleConn(conn, config)
+	}
+}
+
+func (s *sshServer) handleConn(conn net.Conn, config *ssh.ServerConfig) {
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	go func() {
+		for req := range reqs {
+			if req.WantReply && !s.IgnoreKeepAlives {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	for newChan := range chans {
+		switch newChan.ChannelType() {
+		case "session":
+			ch, chReqs, err := newChan.Accept()
+			if err != nil {
+				continue
+			}
+
+			go s.handleSession(ch, chReqs)
+
+		case "direct-tcpip":
+			go s.handleForward(newChan)
+
+		default:
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+// handleForward makes server usable as a jump host
+func (s *sshServer) handleForward(newChan ssh.NewChannel) {
+	var payload struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	ssh.Unmarshal(newChan.ExtraData(), &payload)
+
+	addr := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+
+	s.mu.Lock()
+	s.forwards = append(s.forwards, addr)
+	s.mu.Unlock()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChan.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		io.Copy(ch, conn)
+		ch.Close()
+	}()
+
+	io.Copy(conn, ch)
+	conn.Close()
+}
+
+func (s *sshServer) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type == "subsystem" && !s.NoSFTP {
+			var payload struct{ Name string }
+			ssh.Unmarshal(req.Payload, &payload)
+
+			if payload.Name == "sftp" {
+				req.Reply(true, nil)
+
+				server, err := sftp.NewServer(ch)
+				if err == nil {
+					server.Serve()
+				}
+				return
+			}
+		}
+
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var payload struct{ Command string }
+		ssh.Unmarshal(req.Payload, &payload)
+		req.Reply(true, nil)
+
+		s.mu.Lock()
+		s.execs = append(s.execs, payload.Command)
+		s.mu.Unlock()
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+
+		// Like sshd, exit status is sent once command exits even if client keeps stdin open
+		stdin, _ := cmd.StdinPipe()
+		go func() {
+			io.Copy(stdin, ch)
+			stdin.Close()
+		}()
+
+		var status uint32
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = uint32(exitErr.ExitCode())
+			}
+		}
+
+		statusPayload := make([]byte, 4)
+		binary.BigEndian.PutUint32(statusPayload, status)
+		ch.SendRequest("exit-status", false, statusPayload)
+
+		return
+	}
+}
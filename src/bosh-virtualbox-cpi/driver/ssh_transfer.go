@@ -0,0 +1,165 @@
+package driver
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pkg/sftp"
+)
+
+const (
+	// Partially transferred files are kept under this suffix until
+	// transfer completes so that they are never mistaken for complete files
+	sshPartialFileSuffix = ".part"
+)
+
+// sftpClient returns nil client if server does not provide SFTP subsystem
+func (r *SSHRunner) sftpClient() (*sftp.Client, error) {
+	client, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client != r.existingClient {
+		return nil, bosherr.Error("SSH connection was dropped")
+	}
+
+	if r.sftp != nil || r.sftpMissing {
+		return r.sftp, nil
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		if !strings.Contains(err.Error(), "subsystem request failed") {
+			return nil, bosherr.WrapError(err, "Starting SFTP session")
+		}
+
+		r.logger.Debug(r.logTag, "Falling back to transferring files via cat since SFTP is not available: %s", err)
+		r.sftpMissing = true
+		return nil, nil
+	}
+
+	r.sftp = sftpClient
+
+	return r.sftp, nil
+}
+
+// uploadViaSFTP continues previously interrupted upload of the same file
+// and only makes file available under its final name once it is complete.
+func (r *SSHRunner) uploadViaSFTP(client *sftp.Client, src boshsys.File, dstPath string) error {
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return bosherr.WrapError(err, "Determining source file size")
+	}
+
+	tmpPath := dstPath + sshPartialFileSuffix
+
+	var offset int64
+
+	if tmpInfo, err := client.Stat(tmpPath); err == nil && tmpInfo.Size() <= srcInfo.Size() {
+		offset = tmpInfo.Size()
+		r.logger.Debug(r.logTag, "Resuming upload to '%s' from %d bytes", tmpPath, offset)
+	}
+
+	dst, err := client.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", tmpPath)
+	}
+
+	defer dst.Close()
+
+	if offset == 0 {
+		err = dst.Truncate(0)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Truncating '%s'", tmpPath)
+		}
+	}
+
+	_, err = src.Seek(offset, io.SeekStart)
+	if err != nil {
+		return bosherr.WrapError(err, "Seeking in source file")
+	}
+
+	_, err = dst.Seek(offset, io.SeekStart)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Seeking in '%s'", tmpPath)
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Uploading to '%s'", tmpPath)
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Closing '%s'", tmpPath)
+	}
+
+	return r.finishSFTPTransfer(client, tmpPath, dstPath, srcInfo.Size(), srcInfo.Mode().Perm())
+}
+
+func (r *SSHRunner) putViaSFTP(client *sftp.Client, path string, in io.Reader, mode os.FileMode) error {
+	tmpPath := path + sshPartialFileSuffix
+
+	dst, err := client.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening '%s'", tmpPath)
+	}
+
+	defer dst.Close()
+
+	size, err := io.Copy(dst, in)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Putting file '%s'", tmpPath)
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Closing '%s'", tmpPath)
+	}
+
+	return r.finishSFTPTransfer(client, tmpPath, path, size, mode)
+}
+
+func (r *SSHRunner) finishSFTPTransfer(client *sftp.Client, tmpPath, path string, size int64, mode os.FileMode) error {
+	info, err := client.Stat(tmpPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Checking size of '%s'", tmpPath)
+	}
+
+	if info.Size() != size {
+		// Start from scratch next time
+		client.Remove(tmpPath)
+		return bosherr.Errorf("Expected '%s' to have %d bytes but had %d bytes", tmpPath, size, info.Size())
+	}
+
+	err = client.Chmod(tmpPath, mode)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Changing permissions of '%s'", tmpPath)
+	}
+
+	return r.renameViaSFTP(client, tmpPath, path)
+}
+
+func (r *SSHRunner) getViaSFTP(client *sftp.Client, path string) ([]byte, error) {
+	file, err := client.Open(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting file '%s'", path)
+	}
+
+	defer file.Close()
+
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading file '%s'", path)
+	}
+
+	return contents, nil
+}
@@ -7,6 +7,7 @@ require (
 	github.com/cloudfoundry/bosh-utils v0.0.288
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.16.0
+	github.com/pkg/sftp v1.13.4
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 )
 
@@ -14,6 +15,7 @@ require (
 	github.com/bmatcuk/doublestar v1.3.4 // indirect
 	github.com/charlievieth/fs v0.0.2 // indirect
 	github.com/fsnotify/fsnotify v1.5.1 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/nu7hatch/gouuid v0.0.0-20131221200532-179d4d0c4d8d // indirect
 	github.com/nxadm/tail v1.4.8 // indirect
 	golang.org/x/net v0.0.0-20211029224645-99673261e6eb // indirect
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+const (
+	auditFollowInterval = 2 * time.Second
+)
+
+// runAuditCmd prints recorded VBoxManage invocations as JSON lines
+func runAuditCmd(cpiFactory cpi.Factory, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+
+	var filter cpi.AuditFilter
+
+	flags.StringVar(&filter.VMCID, "vm", "", "Only show invocations involving VM CID")
+	flags.StringVar(&filter.RequestID, "request", "", "Only show invocations made for Director request ID")
+	last := flags.Int("n", 0, "Only show last n matching invocations")
+	follow := flags.Bool("f", false, "Keep showing new invocations as they are recorded")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := cpiFactory.AuditEntries(filter)
+	if err != nil {
+		return err
+	}
+
+	seen := len(entries)
+
+	if *last > 0 && len(entries) > *last {
+		entries = entries[len(entries)-*last:]
+	}
+
+	encoder := json.NewEncoder(out)
+
+	for {
+		for _, entry := range entries {
+			err = encoder.Encode(entry)
+			if err != nil {
+				return bosherr.WrapError(err, "Writing audit entry")
+			}
+		}
+
+		if !*follow {
+			return nil
+		}
+
+		time.Sleep(auditFollowInterval)
+
+		entries, err = cpiFactory.AuditEntries(filter)
+		if err != nil {
+			return err
+		}
+
+		// Audit log is only ever appended to
+		if len(entries) >= seen {
+			entries, seen = entries[seen:], len(entries)
+		} else {
+			seen = len(entries)
+			entries = nil
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	clientDaemonStartTimeout = 10 * time.Second
+	clientDaemonDialInterval = 100 * time.Millisecond
+)
+
+// runClientCmd forwards request to a daemon (see runDaemonCmd) starting it
+// if necessary. Request is served in this process if daemon cannot be reached.
+func runClientCmd(
+	socketPath string,
+	configPath string,
+	reqBytes []byte,
+	serveLocally func([]byte) error,
+	out io.Writer,
+	logger boshlog.Logger,
+) error {
+	conn, err := dialDaemon(socketPath, configPath, logger)
+	if err != nil {
+		logger.Error("main", "Serving request without daemon: %s", err)
+		return serveLocally(reqBytes)
+	}
+
+	defer conn.Close()
+
+	// Once request is sent it is not retried locally since
+	// it might have already been (partially) served by daemon
+	_, err = conn.Write(reqBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Sending request to daemon")
+	}
+
+	err = conn.CloseWrite()
+	if err != nil {
+		return bosherr.WrapError(err, "Finishing request to daemon")
+	}
+
+	_, err = io.Copy(out, conn)
+	if err != nil {
+		return bosherr.WrapError(err, "Receiving response from daemon")
+	}
+
+	return nil
+}
+
+func dialDaemon(socketPath, configPath string, logger boshlog.Logger) (*net.UnixConn, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err == nil {
+		return conn.(*net.UnixConn), nil
+	}
+
+	logger.Debug("main", "Starting daemon since '%s' is not reachable: %s", socketPath, err)
+
+	err = startDaemon(socketPath, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(clientDaemonStartTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(clientDaemonDialInterval)
+
+		conn, err = net.Dial("unix", socketPath)
+		if err == nil {
+			return conn.(*net.UnixConn), nil
+		}
+	}
+
+	return nil, bosherr.WrapErrorf(err, "Waiting for daemon to listen on '%s'", socketPath)
+}
+
+// startDaemon runs daemon in its own session so that it outlives the client;
+// daemon logs are kept next to the socket
+func startDaemon(socketPath, configPath string) error {
+	logPath := socketPath + ".log"
+
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Opening daemon log '%s'", logPath)
+	}
+
+	defer logFile.Close()
+
+	binPath, err := os.Executable()
+	if err != nil {
+		binPath = os.Args[0]
+	}
+
+	cmd := exec.Command(binPath, "-configPath", configPath, "daemon", "-socket", socketPath)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	err = cmd.Start()
+	if err != nil {
+		return bosherr.WrapError(err, "Starting daemon")
+	}
+
+	return cmd.Process.Release()
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"crypto/sha1"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -24,6 +29,20 @@ func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
 		return config, bosherr.WrapError(err, "Unmarshalling config")
 	}
 
+	config.PrivateKeyPath = configRelativePath(path, config.PrivateKeyPath)
+	config.KnownHostsPath = configRelativePath(path, config.KnownHostsPath)
+
+	for i, jumpHost := range config.JumpHosts {
+		config.JumpHosts[i].PrivateKeyPath = configRelativePath(path, jumpHost.PrivateKeyPath)
+		config.JumpHosts[i].KnownHostsPath = configRelativePath(path, jumpHost.KnownHostsPath)
+	}
+
+	config.Daemon.SocketPath = configRelativePath(path, config.Daemon.SocketPath)
+
+	if len(config.Daemon.SocketPath) == 0 {
+		config.Daemon.SocketPath = defaultSocketPath(path)
+	}
+
 	err = cpi.FactoryOpts(config).Validate()
 	if err != nil {
 		return config, bosherr.WrapError(err, "Validating configuration")
@@ -31,3 +50,44 @@ func NewConfigFromPath(path string, fs boshsys.FileSystem) (Config, error) {
 
 	return config, nil
 }
+
+// configRelativePath allows job templates to render files (e.g. known_hosts, private keys)
+// next to configuration file without knowing where jobs are installed
+func configRelativePath(configPath, path string) string {
+	if len(path) == 0 || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath), path)
+}
+
+// defaultSocketPath is unique per configuration so that daemons
+// serving different VirtualBox hosts do not share a socket. Changing
+// configuration or binary (e.g. on redeploy) results in a new socket
+// so that clients do not talk to a daemon that is out of date.
+func defaultSocketPath(configPath string) string {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		absPath = configPath
+	}
+
+	binPath, err := os.Executable()
+	if err != nil {
+		binPath = os.Args[0]
+	}
+
+	identity := absPath + "\n" + fileIdentity(absPath) + "\n" + binPath + "\n" + fileIdentity(binPath)
+
+	sum := fmt.Sprintf("%x", sha1.Sum([]byte(identity)))
+
+	// Unix socket paths are limited to ~100 chars
+	return filepath.Join(os.TempDir(), "bosh-virtualbox-cpi-"+sum[:12]+".sock")
+}
+
+// fileIdentity changes whenever file is replaced or modified
+func fileIdentity(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+}
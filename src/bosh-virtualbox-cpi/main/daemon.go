@@ -0,0 +1,225 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+const (
+	// Clients send whole request before waiting for response
+	daemonRequestReadTimeout = 1 * time.Minute
+	daemonIdleCheckInterval  = 5 * time.Second
+)
+
+// runDaemonCmd serves CPI requests sent by thin clients (see runClientCmd)
+// keeping SSH connection, VirtualBox capabilities and read caches warm
+func runDaemonCmd(cpiFactory cpi.Factory, opts cpi.FactoryOpts, args []string, logger boshlog.Logger) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+
+	socketPath := flags.String("socket", opts.Daemon.SocketPath, "Path to Unix socket to listen on")
+	idleTimeout := flags.Duration("idle-timeout", opts.DaemonIdleTimeout(), "Exit after not serving requests for this long (0 never exits)")
+	cacheTTL := flags.Duration("cache-ttl", opts.DaemonCacheTTL(), "Share results of read-only VBoxManage commands between requests for this long")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := lockDaemon(*socketPath)
+	if err != nil {
+		return err
+	}
+
+	if lockFile == nil {
+		logger.Info("main", "Another daemon is already serving '%s'", *socketPath)
+		return nil
+	}
+
+	defer lockFile.Close()
+
+	// Socket left behind by a daemon that did not exit cleanly
+	err = os.Remove(*socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return bosherr.WrapErrorf(err, "Removing stale socket '%s'", *socketPath)
+	}
+
+	listener, err := listenPrivately(*socketPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Listening on '%s'", *socketPath)
+	}
+
+	defer os.Remove(*socketPath)
+
+	logger.Info("main", "Serving CPI requests on '%s'", *socketPath)
+
+	d := &daemon{
+		factory:     cpiFactory.WithWarmState(*cacheTTL),
+		listener:    listener,
+		idleTimeout: *idleTimeout,
+		lastActive:  time.Now(),
+		logger:      logger,
+	}
+
+	return d.Serve()
+}
+
+// listenPrivately creates socket that is only accessible by current user
+// since requests include credentials (e.g. agent settings). Socket is created
+// with restrictive umask so that there is no window in which others could connect.
+func listenPrivately(socketPath string) (net.Listener, error) {
+	oldMask := syscall.Umask(0177)
+	defer syscall.Umask(oldMask)
+
+	return net.Listen("unix", socketPath)
+}
+
+// lockDaemon returns nil file if another daemon holds the lock
+func lockDaemon(socketPath string) (*os.File, error) {
+	lockPath := socketPath + ".lock"
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening daemon lock '%s'", lockPath)
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+
+		if err == syscall.EWOULDBLOCK {
+			return nil, nil
+		}
+
+		return nil, bosherr.WrapErrorf(err, "Acquiring daemon lock '%s'", lockPath)
+	}
+
+	return file, nil
+}
+
+type daemon struct {
+	factory     cpi.Factory
+	listener    net.Listener
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	active     int
+	lastActive time.Time
+	stopping   bool
+
+	wg sync.WaitGroup
+
+	logger boshlog.Logger
+}
+
+func (d *daemon) Serve() error {
+	go d.stopOnSignal()
+
+	if d.idleTimeout > 0 {
+		go d.stopWhenIdle()
+	}
+
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			if d.isStopping() {
+				break
+			}
+			return bosherr.WrapError(err, "Accepting connection")
+		}
+
+		d.track(1)
+		d.wg.Add(1)
+
+		go func() {
+			defer d.wg.Done()
+			defer d.track(-1)
+
+			d.handle(conn)
+		}()
+	}
+
+	// Let requests in flight finish
+	d.wg.Wait()
+
+	return nil
+}
+
+func (d *daemon) handle(conn net.Conn) {
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(daemonRequestReadTimeout))
+
+	// Clients close their side for writing after sending request
+	reqBytes, err := ioutil.ReadAll(conn)
+	if err != nil {
+		d.logger.Error("main", "Reading request: %s", err)
+		return
+	}
+
+	err = serveOnce(d.factory, reqBytes, conn, d.logger)
+	if err != nil {
+		d.logger.Error("main", "Serving request: %s", err)
+	}
+}
+
+func (d *daemon) stopOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	d.logger.Info("main", "Stopping due to %s", sig)
+	d.stop()
+}
+
+func (d *daemon) stopWhenIdle() {
+	interval := daemonIdleCheckInterval
+	if d.idleTimeout < interval {
+		interval = d.idleTimeout
+	}
+
+	for range time.Tick(interval) {
+		d.mu.Lock()
+		idle := d.active == 0 && time.Since(d.lastActive) > d.idleTimeout
+		d.mu.Unlock()
+
+		if idle {
+			d.logger.Info("main", "Stopping after being idle for %s", d.idleTimeout)
+			d.stop()
+			return
+		}
+	}
+}
+
+func (d *daemon) stop() {
+	d.mu.Lock()
+	d.stopping = true
+	d.mu.Unlock()
+
+	d.listener.Close()
+}
+
+func (d *daemon) isStopping() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.stopping
+}
+
+func (d *daemon) track(delta int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active += delta
+	d.lastActive = time.Now()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bosh-virtualbox-cpi/cpi"
+	"bosh-virtualbox-cpi/driver/fakes"
+)
+
+const infoRequest = `{"method": "info", "arguments": [], "context": {"director_uuid": "uuid"}}`
+
+var _ = Describe("daemon", func() {
+	var (
+		tmpDir     string
+		socketPath string
+		logger     boshlog.Logger
+		factory    cpi.Factory
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		// Unix socket paths are limited to ~100 chars
+		tmpDir, err = ioutil.TempDir("", "daemon")
+		Expect(err).ToNot(HaveOccurred())
+
+		socketPath = filepath.Join(tmpDir, "cpi.sock")
+
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		factory = newSimulatedFactory(logger)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	startDaemon := func(idleTimeout string) chan error {
+		done := make(chan error, 1)
+
+		go func() {
+			defer GinkgoRecover()
+			done <- runDaemonCmd(factory, cpi.FactoryOpts{}, []string{"-socket", socketPath, "-idle-timeout", idleTimeout}, logger)
+		}()
+
+		// Client would start another daemon if socket is not listened on yet
+		Eventually(func() error {
+			conn, err := net.Dial("unix", socketPath)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}).ShouldNot(HaveOccurred())
+
+		return done
+	}
+
+	sendRequest := func() string {
+		var out bytes.Buffer
+
+		serveLocally := func([]byte) error {
+			Fail("Expected request to be served by daemon")
+			return nil
+		}
+
+		err := runClientCmd(socketPath, "", []byte(infoRequest), serveLocally, &out, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		return out.String()
+	}
+
+	It("serves requests forwarded by clients on socket accessible only by owner", func() {
+		done := startDaemon("1s")
+
+		Expect(sendRequest()).To(ContainSubstring(`"stemcell_formats"`))
+		Expect(sendRequest()).To(ContainSubstring(`"stemcell_formats"`))
+
+		info, err := os.Stat(socketPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+	})
+
+	It("exits and removes socket after being idle", func() {
+		startedAt := time.Now()
+		done := startDaemon("200ms")
+
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		Expect(time.Since(startedAt)).To(BeNumerically(">=", 200*time.Millisecond))
+
+		_, err := os.Stat(socketPath)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("replaces socket left behind by daemon that did not exit cleanly", func() {
+		listener, err := net.Listen("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		listener.Close()
+
+		_, err = os.Stat(socketPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		done := startDaemon("500ms")
+
+		Expect(sendRequest()).To(ContainSubstring(`"stemcell_formats"`))
+
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+	})
+
+	It("serves request in client process when daemon cannot be started", func() {
+		var out bytes.Buffer
+		var servedLocally []byte
+
+		serveLocally := func(reqBytes []byte) error {
+			servedLocally = reqBytes
+			return serveOnce(factory, reqBytes, &out, logger)
+		}
+
+		// Daemon log cannot be created in missing directory
+		missingSocketPath := filepath.Join(tmpDir, "missing", "cpi.sock")
+
+		err := runClientCmd(missingSocketPath, "", []byte(infoRequest), serveLocally, &out, logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(servedLocally)).To(Equal(infoRequest))
+		Expect(out.String()).To(ContainSubstring(`"stemcell_formats"`))
+	})
+})
+
+// newSimulatedFactory returns factory whose CPIs talk to simulated VirtualBox
+func newSimulatedFactory(logger boshlog.Logger) cpi.Factory {
+	fs := boshsys.NewOsFileSystem(logger)
+	compressor := boshcmd.NewTarballCompressor(boshsys.NewExecCmdRunner(logger), fs)
+
+	vb := fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+	opts := cpi.FactoryOpts{BinPath: vb.BinPath(), StoreDir: "~/.bosh_virtualbox_cpi", StorageController: "sata"}
+
+	return cpi.NewFactoryWithRunner(
+		vb.Runner(), fakes.Retrier{}, fs, boshuuid.NewGenerator(), compressor, opts, logger)
+}
+
+var _ = Describe("defaultSocketPath", func() {
+	It("changes when configuration file changes", func() {
+		file, err := ioutil.TempFile("", "config")
+		Expect(err).ToNot(HaveOccurred())
+
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		before := defaultSocketPath(file.Name())
+		Expect(defaultSocketPath(file.Name())).To(Equal(before))
+
+		_, err = file.WriteString(`{"Host": "other"}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(defaultSocketPath(file.Name())).ToNot(Equal(before))
+	})
+})
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/tabwriter"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+// runDoctorCmd checks configured VirtualBox host and suggests how to fix problems
+func runDoctorCmd(cpiFactory cpi.Factory, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+
+	var ips doctorIPsFlag
+
+	minFreeGB := flags.Int("min-free-gb", 10, "Minimum free space in store directory (GB)")
+	flags.Var(&ips, "ip", "IP planned for host-only network that must be allowed (can be repeated)")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	checks := cpiFactory.Doctor(cpi.DoctorOpts{MinFreeDiskMB: *minFreeGB * 1024, HostOnlyIPs: ips})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	var failed int
+
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+
+		if check.Failed() {
+			failed++
+
+			if len(check.Hint) > 0 {
+				fmt.Fprintf(w, "\t\tHint: %s\n", check.Hint)
+			}
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return bosherr.WrapError(err, "Writing checks")
+	}
+
+	if failed > 0 {
+		return bosherr.Errorf("%d check(s) failed", failed)
+	}
+
+	return nil
+}
+
+type doctorIPsFlag []net.IP
+
+func (f *doctorIPsFlag) String() string {
+	var ips []string
+	for _, ip := range *f {
+		ips = append(ips, ip.String())
+	}
+	return strings.Join(ips, ",")
+}
+
+func (f *doctorIPsFlag) Set(val string) error {
+	ip := net.ParseIP(val)
+	if ip == nil {
+		return bosherr.Errorf("Invalid IP '%s'", val)
+	}
+	*f = append(*f, ip)
+	return nil
+}
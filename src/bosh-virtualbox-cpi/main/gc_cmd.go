@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+// runGCCmd deletes resources that are not used anymore; without -confirm
+// it only shows what would be deleted
+func runGCCmd(cpiFactory cpi.Factory, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+
+	knownPath := flags.String("known", "", "File with CIDs referenced by Director (one per line)")
+	allowEmptyKnown := flags.Bool("allow-empty-known", false, "Accept -known file without any CIDs (everything is unknown)")
+	minAge := flags.Duration("min-age", 1*time.Hour, "Keep resources modified more recently than this")
+	confirm := flags.Bool("confirm", false, "Delete resources instead of only showing them")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	opts := cpi.GCOpts{MinAge: *minAge, Confirm: *confirm}
+
+	if len(*knownPath) > 0 {
+		opts.KnownCIDs, err = readKnownCIDs(*knownPath)
+		if err != nil {
+			return err
+		}
+
+		// Most likely a failed export from Director that would make all resources deletable
+		if len(opts.KnownCIDs) == 0 && !*allowEmptyKnown {
+			return bosherr.Errorf("Expected known CIDs '%s' to list at least one CID "+
+				"(use -allow-empty-known if nothing is referenced by Director)", *knownPath)
+		}
+	}
+
+	results, err := cpiFactory.CollectGarbage(opts)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "CID\tKind\tAction\tReason\tNote")
+
+	var failed int
+
+	for _, res := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", res.CID, res.Kind, res.Action, res.Reason, inventoryValue(res.Note))
+
+		if res.Failed() {
+			failed++
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return bosherr.WrapError(err, "Writing results")
+	}
+
+	if !*confirm {
+		fmt.Fprintln(out, "\nDry run; use -confirm to delete")
+	}
+
+	if failed > 0 {
+		return bosherr.Errorf("Failed to delete %d resource(s)", failed)
+	}
+
+	return nil
+}
+
+// readKnownCIDs ignores empty lines and lines starting with #
+func readKnownCIDs(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Opening known CIDs '%s'", path)
+	}
+
+	defer file.Close()
+
+	cids := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			cids[line] = true
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading known CIDs '%s'", path)
+	}
+
+	return cids, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+var _ = Describe("runGCCmd", func() {
+	var (
+		knownPath string
+		factory   cpi.Factory
+	)
+
+	BeforeEach(func() {
+		file, err := ioutil.TempFile("", "known")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = file.WriteString("# exported from Director\n\n")
+		Expect(err).ToNot(HaveOccurred())
+
+		knownPath = file.Name()
+		file.Close()
+
+		factory = newSimulatedFactory(boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	AfterEach(func() {
+		os.Remove(knownPath)
+	})
+
+	It("refuses known CIDs file without any CIDs", func() {
+		var out bytes.Buffer
+
+		err := runGCCmd(factory, []string{"-known", knownPath, "-confirm"}, &out)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("-allow-empty-known"))
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("accepts known CIDs file without any CIDs when explicitly allowed", func() {
+		var out bytes.Buffer
+
+		err := runGCCmd(factory, []string{"-known", knownPath, "-allow-empty-known"}, &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.String()).To(ContainSubstring("Dry run"))
+	})
+})
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+// runInventoryCmd prints stemcells, VMs and disks managed by CPI and flags orphans
+func runInventoryCmd(cpiFactory cpi.Factory, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("inventory", flag.ContinueOnError)
+
+	asJSON := flags.Bool("json", false, "Print inventory as JSON")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	inv, err := cpiFactory.Inventory()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(inv)
+		if err != nil {
+			return bosherr.WrapError(err, "Writing inventory")
+		}
+
+		return nil
+	}
+
+	return writeInventoryTables(inv, out)
+}
+
+func writeInventoryTables(inv cpi.Inventory, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Stemcell\tRegistered\tStored\tClones")
+	for _, sc := range inv.Stemcells {
+		fmt.Fprintf(w, "%s\t%t\t%t\t%s\n", sc.CID, sc.Registered, sc.Stored, inventoryList(sc.Clones))
+	}
+
+	fmt.Fprintln(w, "\nVM\tState\tStored\tStemcell\tDisks\tMetadata")
+	for _, vm := range inv.VMs {
+		state := vm.State
+		if !vm.Registered {
+			state = "unregistered"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\n", vm.CID, state, vm.Stored,
+			inventoryValue(vm.Stemcell), inventoryList(vm.Disks), inventoryMetadata(vm.Metadata))
+	}
+
+	fmt.Fprintln(w, "\nDisk\tSize\tRegistered\tStored\tOwner")
+	for _, disk := range inv.Disks {
+		size := "-"
+		if disk.Registered {
+			size = fmt.Sprintf("%d MB", disk.SizeMB)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\n", disk.CID, size, disk.Registered, disk.Stored, inventoryValue(disk.Owner))
+	}
+
+	fmt.Fprintln(w, "\nOrphan\tKind\tReason")
+	for _, orphan := range inv.Orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", orphan.CID, orphan.Kind, orphan.Reason)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		return bosherr.WrapError(err, "Writing inventory")
+	}
+
+	return nil
+}
+
+func inventoryValue(val string) string {
+	if len(val) == 0 {
+		return "-"
+	}
+	return val
+}
+
+func inventoryList(vals []string) string {
+	return inventoryValue(strings.Join(vals, ", "))
+}
+
+// inventoryMetadata shows metadata as sorted 'key=value' pairs
+func inventoryMetadata(meta map[string]interface{}) string {
+	var pairs []string
+
+	for k, v := range meta {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+
+	sort.Strings(pairs)
+
+	return inventoryList(pairs)
+}
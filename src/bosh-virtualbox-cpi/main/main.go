@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
+	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"time"
 
 	"github.com/cloudfoundry/bosh-cpi-go/rpc"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -38,15 +43,131 @@ func main() {
 	cpiFactory := cpi.NewFactory(
 		fs, cmdRunner, uuidGen, compressor, cpi.FactoryOpts(config), logger)
 
-	cli := rpc.NewFactory(logger).NewCLI(cpiFactory)
+	switch flag.Arg(0) {
+	case "":
+		err = readAndServeOnce(cpiFactory, logger)
+		if err != nil {
+			logger.Error("main", "Serving once: %s", err)
+			os.Exit(1)
+		}
 
-	err = cli.ServeOnce()
-	if err != nil {
-		logger.Error("main", "Serving once: %s", err)
+	case "daemon":
+		err = runDaemonCmd(cpiFactory, cpi.FactoryOpts(config), flag.Args()[1:], logger)
+		if err != nil {
+			logger.Error("main", "Running daemon: %s", err)
+			os.Exit(1)
+		}
+
+	case "client":
+		err = readAndForward(cpiFactory, config.Daemon.SocketPath, *configPathOpt, logger)
+		if err != nil {
+			logger.Error("main", "Forwarding to daemon: %s", err)
+			os.Exit(1)
+		}
+
+	case "audit":
+		err = runAuditCmd(cpiFactory, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			logger.Error("main", "Showing audit log: %s", err)
+			os.Exit(1)
+		}
+
+	case "inventory":
+		err = runInventoryCmd(cpiFactory, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			logger.Error("main", "Showing inventory: %s", err)
+			os.Exit(1)
+		}
+
+	case "gc":
+		err = runGCCmd(cpiFactory, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			logger.Error("main", "Collecting garbage: %s", err)
+			os.Exit(1)
+		}
+
+	case "restore-disk":
+		err = runRestoreDiskCmd(cpiFactory, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			logger.Error("main", "Restoring disk: %s", err)
+			os.Exit(1)
+		}
+
+	case "doctor":
+		err = runDoctorCmd(cpiFactory, flag.Args()[1:], os.Stdout)
+		if err != nil {
+			logger.Error("main", "Checking host: %s", err)
+			os.Exit(1)
+		}
+
+	default:
+		logger.Error("main", "Unknown command '%s'", flag.Arg(0))
 		os.Exit(1)
 	}
 }
 
+func readAndServeOnce(cpiFactory cpi.Factory, logger boshlog.Logger) error {
+	reqBytes, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading from stdin")
+	}
+
+	return serveOnce(cpiFactory, reqBytes, os.Stdout, logger)
+}
+
+func readAndForward(cpiFactory cpi.Factory, socketPath, configPath string, logger boshlog.Logger) error {
+	reqBytes, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading from stdin")
+	}
+
+	serveLocally := func(reqBytes []byte) error {
+		return serveOnce(cpiFactory, reqBytes, os.Stdout, logger)
+	}
+
+	return runClientCmd(socketPath, configPath, reqBytes, serveLocally, os.Stdout, logger)
+}
+
+// serveOnce peeks at requested method so that it can be recorded in audit log
+func serveOnce(cpiFactory cpi.Factory, reqBytes []byte, out io.Writer, logger boshlog.Logger) error {
+	var req struct {
+		Method string `json:"method"`
+	}
+
+	_ = json.Unmarshal(reqBytes, &req) // dispatcher responds with a proper error
+
+	cpiFactory = cpiFactory.WithMethod(req.Method).ForRequest()
+
+	// Dispatcher would report failure to create CPI as unimplemented method
+	err := cpiFactory.Preflight()
+	if err != nil {
+		logger.Error("main", "Preflight: %s", err)
+		return writeCloudError(err, out)
+	}
+
+	cli := rpc.NewFactory(logger).NewCLIWithInOut(bytes.NewReader(reqBytes), out, cpiFactory)
+
+	return cli.ServeOnce()
+}
+
+func writeCloudError(err error, out io.Writer) error {
+	resp := rpc.Response{
+		Error: &rpc.ResponseError{Type: "Bosh::Clouds::CloudError", Message: err.Error()},
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshaling response")
+	}
+
+	_, err = out.Write(respBytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing response")
+	}
+
+	return nil
+}
+
 func basicDeps() (boshlog.Logger, boshsys.FileSystem, boshsys.CmdRunner, boshuuid.Generator) {
 	logger := boshlog.NewWriterLogger(boshlog.LevelDebug, os.Stderr)
 	fs := boshsys.NewOsFileSystem(logger)
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/cpi"
+)
+
+// runRestoreDiskCmd replaces contents of a detached disk with a snapshot;
+// Director does not offer a way to restore snapshots
+func runRestoreDiskCmd(cpiFactory cpi.Factory, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("restore-disk", flag.ContinueOnError)
+
+	snapshotCID := flags.String("snapshot", "", "CID of snapshot to restore from")
+	diskCID := flags.String("disk", "", "CID of detached disk to restore")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if len(*snapshotCID) == 0 || len(*diskCID) == 0 {
+		return bosherr.Error("Must provide -snapshot and -disk")
+	}
+
+	err = cpiFactory.RestoreDisk(apiv1.NewSnapshotCID(*snapshotCID), apiv1.NewDiskCID(*diskCID))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Restored disk '%s' from snapshot '%s'\n", *diskCID, *snapshotCID)
+
+	return nil
+}
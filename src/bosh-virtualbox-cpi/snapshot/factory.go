@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"path/filepath"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver"
+)
+
+type Factory struct {
+	dirPath string
+	uuidGen boshuuid.Generator
+
+	driver driver.Driver
+	runner driver.Runner
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFactory(
+	dirPath string,
+	uuidGen boshuuid.Generator,
+	driver driver.Driver,
+	runner driver.Runner,
+	logger boshlog.Logger,
+) Factory {
+	return Factory{
+		dirPath: dirPath,
+		uuidGen: uuidGen,
+
+		driver: driver,
+		runner: runner,
+
+		logTag: "snapshot.Factory",
+		logger: logger,
+	}
+}
+
+func (f Factory) Create(disk bdisk.Disk, meta apiv1.DiskMeta) (Snapshot, error) {
+	id, err := f.uuidGen.Generate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating snapshot id")
+	}
+
+	id = "snap-" + id
+
+	snapshot := f.newSnapshot(apiv1.NewSnapshotCID(id), disk.Props().Format)
+
+	err = f.runner.MkdirAll(snapshot.Path())
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating snapshot parent")
+	}
+
+	vmIDs, err := disk.AttachedVMIDs()
+	if err != nil {
+		f.cleanUpPartialCreate(snapshot)
+		return nil, err
+	}
+
+	if len(vmIDs) > 0 {
+		err = f.cloneAttached(vmIDs[0], disk, snapshot)
+	} else {
+		err = f.clone(disk, snapshot)
+	}
+	if err != nil {
+		f.cleanUpPartialCreate(snapshot)
+		return nil, err
+	}
+
+	rec := snapshotRecord{DiskID: disk.ID().AsString(), Meta: meta, Format: disk.Props().Format}
+
+	err = snapshot.saveRecord(rec)
+	if err != nil {
+		f.cleanUpPartialCreate(snapshot)
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (f Factory) Find(cid apiv1.SnapshotCID) (Snapshot, error) {
+	format, err := f.findFormat(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.newSnapshot(cid, format), nil
+}
+
+func (f Factory) findFormat(cid apiv1.SnapshotCID) (string, error) {
+	rec, err := f.newSnapshot(cid, bdisk.VMDKFormat).record()
+	if err != nil {
+		// Snapshots that do not exist (or were only partially created)
+		// do not have saved record; their medium is looked for at 'disk.vmdk'
+		if driver.IsNotExistErr(err) {
+			return bdisk.VMDKFormat, nil
+		}
+		return "", bosherr.WrapErrorf(err, "Reading record of snapshot '%s'", cid.AsString())
+	}
+
+	if len(rec.Format) == 0 {
+		return bdisk.VMDKFormat, nil
+	}
+
+	return rec.Format, nil
+}
+
+func (f Factory) newSnapshot(cid apiv1.SnapshotCID, format string) SnapshotImpl {
+	path := filepath.Join(f.dirPath, cid.AsString())
+	return NewSnapshotImpl(cid, path, format, f.driver, f.runner, f.logger)
+}
+
+func (f Factory) clone(disk bdisk.Disk, snapshot SnapshotImpl) error {
+	props := disk.Props()
+
+	_, err := f.driver.Execute(
+		"clonemedium", "disk", disk.ImagePath(), snapshot.ImagePath(),
+		"--format", props.Format,
+		"--variant", props.Variant,
+	)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Cloning disk '%s'", disk.ID().AsString())
+	}
+
+	return nil
+}
+
+// cloneAttached takes a VM snapshot so that disk's medium becomes read-only
+// while VM keeps writing into a differencing image; medium is then cloned
+// and VM snapshot is merged back.
+func (f Factory) cloneAttached(vmID string, disk bdisk.Disk, snapshot SnapshotImpl) error {
+	snapName := snapshot.ID().AsString()
+
+	_, err := f.driver.Execute("snapshot", vmID, "take", snapName, "--live")
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Taking VM snapshot of disk '%s'", disk.ID().AsString())
+	}
+
+	cloneErr := f.clone(disk, snapshot)
+
+	_, err = f.driver.Execute("snapshot", vmID, "delete", snapName)
+	if err != nil {
+		if cloneErr != nil {
+			f.logger.Error(f.logTag, "Failed to delete VM snapshot after failed clone: %s", err)
+			return cloneErr
+		}
+		return bosherr.WrapErrorf(err, "Deleting VM snapshot of disk '%s'", disk.ID().AsString())
+	}
+
+	return cloneErr
+}
+
+func (f Factory) cleanUpPartialCreate(snapshot Snapshot) {
+	err := snapshot.Delete()
+	if err != nil {
+		f.logger.Error(f.logTag, "Failed to clean up partially created snapshot: %s", err)
+	}
+}
@@ -0,0 +1,34 @@
+package snapshot
+
+import (
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+)
+
+type Creator interface {
+	Create(bdisk.Disk, apiv1.DiskMeta) (Snapshot, error)
+}
+
+var _ Creator = Factory{}
+
+type Finder interface {
+	Find(apiv1.SnapshotCID) (Snapshot, error)
+}
+
+var _ Finder = Factory{}
+
+type Snapshot interface {
+	ID() apiv1.SnapshotCID
+
+	Path() string
+	ImagePath() string
+
+	DiskID() (apiv1.DiskCID, error)
+
+	Exists() (bool, error)
+	Delete() error
+	Restore(bdisk.Disk) error
+}
+
+var _ Snapshot = SnapshotImpl{}
@@ -0,0 +1,159 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver"
+)
+
+type SnapshotImpl struct {
+	cid    apiv1.SnapshotCID
+	path   string
+	format string
+
+	driver driver.Driver
+	runner driver.Runner
+
+	logTag string
+	logger boshlog.Logger
+}
+
+type snapshotRecord struct {
+	DiskID string
+	Meta   apiv1.DiskMeta
+
+	// Empty for snapshots taken before medium format followed disk format
+	Format string
+}
+
+func NewSnapshotImpl(
+	cid apiv1.SnapshotCID,
+	path string,
+	format string,
+	driver driver.Driver,
+	runner driver.Runner,
+	logger boshlog.Logger,
+) SnapshotImpl {
+	return SnapshotImpl{cid, path, format, driver, runner, "snapshot.SnapshotImpl", logger}
+}
+
+func (s SnapshotImpl) ID() apiv1.SnapshotCID { return s.cid }
+
+func (s SnapshotImpl) Path() string { return s.path }
+
+// ImagePath returns path to the medium; extension is picked based on format
+// of the disk snapshot was taken from (snapshots used to always be 'disk.vmdk').
+func (s SnapshotImpl) ImagePath() string {
+	return filepath.Join(s.path, "disk."+strings.ToLower(s.format))
+}
+
+func (s SnapshotImpl) DiskID() (apiv1.DiskCID, error) {
+	rec, err := s.record()
+	if err != nil {
+		return apiv1.DiskCID{}, err
+	}
+
+	return apiv1.NewDiskCID(rec.DiskID), nil
+}
+
+func (s SnapshotImpl) Exists() (bool, error) {
+	_, err := s.runner.Stat(s.path)
+	if err != nil {
+		if driver.IsNotExistErr(err) {
+			return false, nil
+		}
+		return false, bosherr.WrapErrorf(err, "Checking snapshot '%s'", s.path)
+	}
+
+	return true, nil
+}
+
+func (s SnapshotImpl) Delete() error {
+	output, err := s.driver.Execute("closemedium", "disk", s.ImagePath(), "--delete")
+	if err != nil {
+		// Medium might have never been created or registered
+		s.logger.Debug(s.logTag, "Ignoring failure to close snapshot medium: %s", output)
+	}
+
+	err = s.runner.RemoveAll(s.path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting snapshot '%s'", s.path)
+	}
+
+	return nil
+}
+
+// Restore replaces contents of a detached disk with contents of the snapshot.
+// Disk CID stays the same so that Director can continue referencing it.
+func (s SnapshotImpl) Restore(disk bdisk.Disk) error {
+	vmIDs, err := disk.AttachedVMIDs()
+	if err != nil {
+		return err
+	}
+
+	if len(vmIDs) > 0 {
+		return bosherr.Errorf("Expected disk '%s' to be detached before restoring (attached to '%v')",
+			disk.ID().AsString(), vmIDs)
+	}
+
+	props := disk.Props()
+	tmpPath := filepath.Join(disk.Path(), "restore"+filepath.Ext(disk.ImagePath()))
+
+	_, err = s.driver.Execute(
+		"clonemedium", "disk", s.ImagePath(), tmpPath,
+		"--format", props.Format,
+		"--variant", props.Variant,
+	)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Cloning snapshot '%s'", s.cid.AsString())
+	}
+
+	err = disk.ReplaceImage(tmpPath)
+	if err != nil {
+		// Restored copy can always be recreated from the snapshot
+		_, closeErr := s.driver.Execute("closemedium", "disk", tmpPath, "--delete")
+		if closeErr != nil {
+			s.logger.Error(s.logTag, "Failed to delete restored copy '%s': %s", tmpPath, closeErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s SnapshotImpl) record() (snapshotRecord, error) {
+	var rec snapshotRecord
+
+	bytes, err := s.runner.Get(filepath.Join(s.path, "snapshot.json"))
+	if err != nil {
+		return rec, bosherr.WrapError(err, "Getting snapshot record")
+	}
+
+	err = json.Unmarshal(bytes, &rec)
+	if err != nil {
+		return rec, bosherr.WrapError(err, "Deserializing snapshot record")
+	}
+
+	return rec, nil
+}
+
+func (s SnapshotImpl) saveRecord(rec snapshotRecord) error {
+	bytes, err := json.Marshal(rec)
+	if err != nil {
+		return bosherr.WrapError(err, "Serializing snapshot record")
+	}
+
+	err = s.runner.Put(filepath.Join(s.path, "snapshot.json"), bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving snapshot record")
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package snapshot_test
+
+import (
+	"path/filepath"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver/fakes"
+	. "bosh-virtualbox-cpi/snapshot"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		vb        *fakes.VirtualBox
+		disks     bdisk.Factory
+		snapshots Factory
+		disk      bdisk.Disk
+	)
+
+	capacity := func(disk bdisk.Disk) int {
+		size, err := disk.(bdisk.DiskImpl).Capacity()
+		Expect(err).ToNot(HaveOccurred())
+		return size
+	}
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+
+		disks = bdisk.NewFactory("/store/disks", boshuuid.NewGenerator(), vb.Driver(logger), vb.Runner(), logger)
+		snapshots = NewFactory("/store/snapshots", boshuuid.NewGenerator(), vb.Driver(logger), vb.Runner(), logger)
+
+		var err error
+
+		disk, err = disks.Create(1024, bdisk.DefaultProps())
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("Create", func() {
+		It("clones disk medium and remembers which disk it came from", func() {
+			snapshot, err := snapshots.Create(disk, apiv1.NewDiskMeta(nil))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(vb.FileExists(snapshot.ImagePath())).To(BeTrue())
+			Expect(vb.MediumPaths()).To(ContainElement(snapshot.ImagePath()))
+
+			diskID, err := snapshot.DiskID()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diskID).To(Equal(disk.ID()))
+		})
+
+		It("clones disk medium keeping its format and variant", func() {
+			vdiDisk, err := disks.Create(1024, bdisk.Props{Format: bdisk.VDIFormat, Variant: bdisk.FixedVariant})
+			Expect(err).ToNot(HaveOccurred())
+
+			snapshot, err := snapshots.Create(vdiDisk, apiv1.NewDiskMeta(nil))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.ImagePath()).To(Equal(filepath.Join(snapshot.Path(), "disk.vdi")))
+			Expect(vb.Invocations()).To(ContainElement([]string{
+				"clonemedium", "disk", vdiDisk.ImagePath(), snapshot.ImagePath(),
+				"--format", "VDI", "--variant", "Fixed"}))
+
+			found, err := snapshots.Find(snapshot.ID())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found.ImagePath()).To(Equal(snapshot.ImagePath()))
+
+			Expect(found.Delete()).To(Succeed())
+			Expect(vb.MediumPaths()).ToNot(ContainElement(snapshot.ImagePath()))
+		})
+
+		It("cleans up when cloning fails", func() {
+			vb.FailOn("clonemedium", "")
+
+			_, err := snapshots.Create(disk, apiv1.NewDiskMeta(nil))
+			Expect(err).To(HaveOccurred())
+
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+		})
+	})
+
+	Describe("Exists and Delete", func() {
+		It("deletes snapshot medium and directory", func() {
+			snapshot, err := snapshots.Create(disk, apiv1.NewDiskMeta(nil))
+			Expect(err).ToNot(HaveOccurred())
+
+			exists, err := snapshot.Exists()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			err = snapshot.Delete()
+			Expect(err).ToNot(HaveOccurred())
+
+			exists, err = snapshot.Exists()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exists).To(BeFalse())
+
+			Expect(vb.MediumPaths()).To(Equal([]string{disk.ImagePath()}))
+		})
+
+		It("deletes snapshot whose medium was never created", func() {
+			snapshot, err := snapshots.Find(apiv1.NewSnapshotCID("snap-missing"))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Delete()).To(Succeed())
+		})
+	})
+
+	Describe("Restore", func() {
+		var snapshot Snapshot
+
+		BeforeEach(func() {
+			var err error
+
+			snapshot, err = snapshots.Create(disk, apiv1.NewDiskMeta(nil))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = disk.Resize(2048)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("replaces disk contents keeping its path", func() {
+			err := snapshot.Restore(disk)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(1024))
+			Expect(vb.MediumPaths()).To(ConsistOf(disk.ImagePath(), snapshot.ImagePath()))
+			Expect(vb.FileExists(filepath.Join(disk.Path(), "previous.vmdk"))).To(BeFalse())
+		})
+
+		It("keeps previous contents when restored contents cannot be moved in place", func() {
+			vb.FailOn("modifymedium", filepath.Join(disk.Path(), "restore.vmdk"))
+
+			err := snapshot.Restore(disk)
+			Expect(err).To(HaveOccurred())
+
+			Expect(capacity(disk)).To(Equal(2048))
+			Expect(vb.MediumPaths()).To(ConsistOf(disk.ImagePath(), snapshot.ImagePath()))
+		})
+	})
+})
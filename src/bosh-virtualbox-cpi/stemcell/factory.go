@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"time"
 
 	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -19,6 +18,10 @@ import (
 	bpds "bosh-virtualbox-cpi/vm/portdevices"
 )
 
+const (
+	stemcellImportMaxAttempts = 2
+)
+
 var (
 	stemcellSuggestedName = regexp.MustCompile(`Suggested VM name "(.+?)"`)
 )
@@ -70,6 +73,17 @@ func NewFactory(
 }
 
 func (f Factory) ImportFromPath(imagePath string) (Stemcell, error) {
+	caps, err := f.driver.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail before uploading large image
+	if len(f.opts.StorageController) > 0 && !caps.SupportsStorageController(f.opts.StorageController) {
+		return nil, bosherr.Errorf("Expected storage controller '%s' to be supported by VirtualBox %s",
+			f.opts.StorageController, caps.Version)
+	}
+
 	id, err := f.uuidGen.Generate()
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Generating stemcell id")
@@ -130,7 +144,7 @@ func (f Factory) upload(imagePath, stemcellPath string) error {
 		return bosherr.WrapErrorf(err, "Unpacking stemcell '%s' to '%s'", imagePath, tmpDir)
 	}
 
-	_, _, err = f.runner.Execute("mkdir", "-p", stemcellPath)
+	err = f.runner.MkdirAll(stemcellPath)
 	if err != nil {
 		return bosherr.WrapError(err, "Creating stemcell parent")
 	}
@@ -150,13 +164,32 @@ func (f Factory) upload(imagePath, stemcellPath string) error {
 		// do nothing
 	}
 
+	manifest, err := f.readManifest(tmpDir)
+	if err != nil {
+		return err
+	}
+
 	for _, fileName := range []string{"image-disk1.vmdk", "image.mf", "image.ovf"} {
-		err := f.runner.Upload(filepath.Join(tmpDir, fileName), filepath.Join(stemcellPath, fileName))
+		srcPath, dstPath := filepath.Join(tmpDir, fileName), filepath.Join(stemcellPath, fileName)
+
+		// Interrupted uploads (e.g. due to dropped SSH connection) continue where they left off
+		err := f.retrier.Retry(func() error {
+			err := f.runner.Upload(srcPath, dstPath)
+			if err != nil {
+				return driver.RetryableErrorImpl{Err: err}
+			}
+			return nil
+		})
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Uploading stemcell")
 		}
 	}
 
+	err = f.verifyUpload(manifest, stemcellPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Verifying uploaded stemcell")
+	}
+
 	return nil
 }
 
@@ -272,12 +305,12 @@ func (f Factory) importOVF(ovfPath string) (string, error) {
 	actionFunc := func() error {
 		output, err := f.driver.Execute("import", ovfPath)
 		if err != nil {
-			return driver.RetryableErrorImpl{err}
+			return driver.RetryableErrorImpl{Err: err}
 		}
 
 		matches := stemcellSuggestedName.FindStringSubmatch(output)
 		if len(matches) != 2 {
-			return driver.RetryableErrorImpl{bosherr.Errorf("Couldn't find VM name in the output:\nOutput: '%s'", output)}
+			return driver.RetryableErrorImpl{Err: bosherr.Errorf("Couldn't find VM name in the output:\nOutput: '%s'", output)}
 		}
 
 		suggestedName := matches[1]
@@ -285,7 +318,7 @@ func (f Factory) importOVF(ovfPath string) (string, error) {
 		output, err = f.driver.Execute("list", "vms")
 		if err != nil {
 			f.cleanUpPartialImport(suggestedName)
-			return driver.RetryableErrorImpl{bosherr.WrapError(err, "Listing VMs after an import")}
+			return driver.RetryableErrorImpl{Err: bosherr.WrapError(err, "Listing VMs after an import")}
 		}
 
 		// todo regexp.MustCompile(`^"#{Regexp.escape(suggestedName)}" \{(.+?)\}$`)
@@ -298,10 +331,16 @@ func (f Factory) importOVF(ovfPath string) (string, error) {
 		}
 
 		f.cleanUpPartialImport(suggestedName)
-		return driver.RetryableErrorImpl{bosherr.Errorf("Failed to import '%s'", ovfPath)}
+		return driver.RetryableErrorImpl{Err: bosherr.Errorf("Failed to import '%s'", ovfPath)}
+	}
+
+	// Import is expensive and its VBoxManage commands are already retried by the driver
+	policy := f.retrier.Policy()
+	if policy.MaxAttempts > stemcellImportMaxAttempts {
+		policy.MaxAttempts = stemcellImportMaxAttempts
 	}
 
-	return internalTmpID, f.retrier.RetryComplex(actionFunc, 2, 2*time.Second)
+	return internalTmpID, f.retrier.RetryComplex(actionFunc, policy)
 }
 
 func (f Factory) cleanUpPartialImport(suggestedNameOrID string) {
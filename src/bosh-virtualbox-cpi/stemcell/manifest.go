@@ -0,0 +1,81 @@
+package stemcell
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var (
+	// e.g. SHA1(image.ovf)= 1e9d8a7ad07e6f1e1d53f2b0b2a8f0a7d0b0c0d0
+	manifestSHA1Line = regexp.MustCompile(`(?m)^SHA1\((.+?)\)\s*=\s*([0-9a-fA-F]{40})\s*$`)
+
+	sha1SumOutput = regexp.MustCompile(`(?m)^([0-9a-fA-F]{40})\s`)
+)
+
+// manifestEntry is a file listed in image.mf manifest with its SHA-1 checksum
+type manifestEntry struct {
+	FileName string
+	SHA1     string
+}
+
+// readManifest has to happen before upload since local runner
+// moves unpacked files into stemcell directory.
+func (f Factory) readManifest(tmpDir string) ([]manifestEntry, error) {
+	mfContents, err := f.fs.ReadFileString(filepath.Join(tmpDir, "image.mf"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading manifest")
+	}
+
+	var entries []manifestEntry
+
+	for _, match := range manifestSHA1Line.FindAllStringSubmatch(mfContents, -1) {
+		entries = append(entries, manifestEntry{FileName: match[1], SHA1: strings.ToLower(match[2])})
+	}
+
+	return entries, nil
+}
+
+// verifyUpload makes sure that uploaded files match SHA-1 checksums listed
+// in image.mf manifest so that import does not fail on truncated images.
+func (f Factory) verifyUpload(manifest []manifestEntry, stemcellPath string) error {
+	for _, entry := range manifest {
+		fileName, expectedSHA1 := entry.FileName, entry.SHA1
+		path := filepath.Join(stemcellPath, fileName)
+
+		actualSHA1, err := f.remoteSHA1(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Calculating checksum of '%s'", fileName)
+		}
+
+		if actualSHA1 != expectedSHA1 {
+			// Make sure that next upload starts from scratch
+			_ = f.runner.RemoveAll(path)
+
+			return bosherr.Errorf(
+				"Expected uploaded '%s' to have SHA1 checksum '%s' but was '%s'", fileName, expectedSHA1, actualSHA1)
+		}
+	}
+
+	return nil
+}
+
+func (f Factory) remoteSHA1(path string) (string, error) {
+	output, status, err := f.runner.Execute("sha1sum", path)
+	if err != nil && (status == 127 || status < 0) {
+		// macOS does not come with sha1sum (127 over SSH, -1 when not found locally)
+		output, _, err = f.runner.Execute("shasum", "-a", "1", path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	matches := sha1SumOutput.FindStringSubmatch(output)
+	if len(matches) != 2 {
+		return "", bosherr.Errorf("Unexpected checksum output '%s'", output)
+	}
+
+	return strings.ToLower(matches[1]), nil
+}
@@ -42,9 +42,9 @@ func (s StemcellImpl) Prepare() error {
 }
 
 func (s StemcellImpl) Exists() (bool, error) {
-	output, err := s.driver.Execute("showvminfo", s.cid.AsString(), "--machinereadable")
+	_, err := s.driver.MachineInfo(s.cid.AsString())
 	if err != nil {
-		if s.driver.IsMissingVMErr(output) {
+		if driver.IsObjectNotFoundErr(err) {
 			return false, nil
 		}
 		return false, err
@@ -54,14 +54,14 @@ func (s StemcellImpl) Exists() (bool, error) {
 }
 
 func (s StemcellImpl) Delete() error {
-	output, err := s.driver.Execute("unregistervm", s.cid.AsString(), "--delete")
+	_, err := s.driver.Execute("unregistervm", s.cid.AsString(), "--delete")
 	if err != nil {
-		if !s.driver.IsMissingVMErr(output) {
+		if !driver.IsObjectNotFoundErr(err) {
 			return bosherr.WrapErrorf(err, "Unregistering stemcell VM")
 		}
 	}
 
-	_, _, err = s.runner.Execute("rm", "-rf", s.path)
+	err = s.runner.RemoveAll(s.path)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Deleting stemcell '%s'", s.path)
 	}
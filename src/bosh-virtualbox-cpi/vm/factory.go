@@ -19,6 +19,9 @@ type FactoryOpts struct {
 	DirPath            string
 	StorageController  string
 	AutoEnableNetworks bool
+
+	GracefulShutdown        bool
+	GracefulShutdownTimeout int // in seconds
 }
 
 type Factory struct {
@@ -27,6 +30,7 @@ type Factory struct {
 
 	driver      driver.Driver
 	runner      driver.Runner
+	locks       driver.Locks
 	diskFactory bdisk.Factory
 
 	agentOptions       apiv1.AgentOptions
@@ -41,6 +45,7 @@ func NewFactory(
 	uuidGen boshuuid.Generator,
 	driver driver.Driver,
 	runner driver.Runner,
+	locks driver.Locks,
 	diskFactory bdisk.Factory,
 	agentOptions apiv1.AgentOptions,
 	stemcellAPIVersion apiv1.StemcellAPIVersion,
@@ -52,6 +57,7 @@ func NewFactory(
 
 		driver:      driver,
 		runner:      runner,
+		locks:       locks,
 		diskFactory: diskFactory,
 
 		agentOptions:       agentOptions,
@@ -70,7 +76,7 @@ func (f Factory) Create(
 	env apiv1.VMEnv,
 ) (VM, error) {
 
-	host := Host{bnet.NewNetworks(f.driver, f.logger)}
+	host := Host{bnet.NewNetworks(f.driver, f.logger), f.locks}
 
 	vmProps, err := NewVMProps(props)
 	if err != nil {
@@ -89,8 +95,14 @@ func (f Factory) Create(
 		}
 	}
 
-	vm, err := f.newClonedVM(stemcell)
+	vm, err := f.newClonedVM(stemcell, f.newRuntimeProps(vmProps))
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.saveRuntimeProps()
 	if err != nil {
+		f.cleanUpPartialCreate(vm)
 		return nil, err
 	}
 
@@ -117,7 +129,7 @@ func (f Factory) Create(
 		return nil, bosherr.WrapError(err, "Initial agent configuration")
 	}
 
-	ephemeralDisk, err := f.diskFactory.Create(vmProps.EphemeralDisk)
+	ephemeralDisk, err := f.diskFactory.Create(vmProps.EphemeralDisk, bdisk.DefaultProps())
 	if err != nil {
 		f.cleanUpPartialCreate(vm)
 		return nil, bosherr.WrapError(err, "Creating ephemeral disk")
@@ -129,7 +141,7 @@ func (f Factory) Create(
 		return nil, bosherr.WrapError(err, "Attaching ephemeral disk")
 	}
 
-	err = vm.Start(vmProps.GUI)
+	err = vm.Start()
 	if err != nil {
 		f.cleanUpPartialCreate(vm)
 		return nil, bosherr.WrapError(err, "Starting VM")
@@ -145,18 +157,26 @@ func (f Factory) cleanUpPartialCreate(vm VM) {
 	}
 }
 
-func (f Factory) newVM(cid apiv1.VMCID) VMImpl {
+func (f Factory) newVM(cid apiv1.VMCID, runtimeProps RuntimeProps) VMImpl {
 	pdsOpts := bpds.PortDevicesOpts{Controller: f.opts.StorageController}
 	portDevices := bpds.NewPortDevices(cid, pdsOpts, f.driver, f.logger)
-	store := NewStore(filepath.Join(f.opts.DirPath, cid.AsString()), f.runner)
-	return NewVMImpl(cid, portDevices, store, f.stemcellAPIVersion, f.driver, f.logger)
+	return NewVMImpl(cid, portDevices, f.newStore(cid), runtimeProps, f.stemcellAPIVersion, f.driver, f.locks, f.logger)
+}
+
+func (f Factory) newStore(cid apiv1.VMCID) Store {
+	return NewStore(filepath.Join(f.opts.DirPath, cid.AsString()), f.runner)
 }
 
 func (f Factory) Find(cid apiv1.VMCID) (VM, error) {
-	return f.newVM(cid), nil
+	runtimeProps, err := f.findRuntimeProps(f.newStore(cid))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding VM '%s'", cid.AsString())
+	}
+
+	return f.newVM(cid, runtimeProps), nil
 }
 
-func (f Factory) newClonedVM(stemcell bstem.Stemcell) (VMImpl, error) {
+func (f Factory) newClonedVM(stemcell bstem.Stemcell, runtimeProps RuntimeProps) (VMImpl, error) {
 	cloneIDInternal, err := f.uuidGen.Generate()
 	if err != nil {
 		return VMImpl{}, bosherr.WrapError(err, "Generating clone VM id")
@@ -164,6 +184,14 @@ func (f Factory) newClonedVM(stemcell bstem.Stemcell) (VMImpl, error) {
 
 	cloneID := "vm-" + cloneIDInternal
 
+	// Stemcell VM is locked by VirtualBox while its snapshot is being cloned
+	lock, err := f.locks.Lock(driver.VMLockScope(stemcell.ID().AsString()))
+	if err != nil {
+		return VMImpl{}, err
+	}
+
+	defer lock.Unlock()
+
 	_, err = f.driver.Execute(
 		"clonevm", stemcell.ID().AsString(),
 		"--snapshot", stemcell.SnapshotName(),
@@ -176,5 +204,5 @@ func (f Factory) newClonedVM(stemcell bstem.Stemcell) (VMImpl, error) {
 		return VMImpl{}, bosherr.WrapError(err, "Cloning VM")
 	}
 
-	return f.newVM(apiv1.NewVMCID(cloneID)), nil
+	return f.newVM(apiv1.NewVMCID(cloneID), runtimeProps), nil
 }
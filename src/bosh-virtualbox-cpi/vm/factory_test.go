@@ -0,0 +1,57 @@
+package vm_test
+
+import (
+	"errors"
+
+	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bdisk "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver"
+	"bosh-virtualbox-cpi/driver/fakes"
+	. "bosh-virtualbox-cpi/vm"
+)
+
+// unreadableRunner fails to read any file, e.g. due to dropped SSH connection
+type unreadableRunner struct {
+	fakes.Runner
+}
+
+func (unreadableRunner) Get(string) ([]byte, error) {
+	return nil, errors.New("connection lost")
+}
+
+var _ = Describe("Factory", func() {
+	var (
+		vb     *fakes.VirtualBox
+		logger boshlog.Logger
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		vb = fakes.NewVirtualBox(fakes.VirtualBoxOpts{})
+	})
+
+	newFactory := func(runner driver.Runner) Factory {
+		return NewFactory(
+			FactoryOpts{DirPath: "/store/vms"}, boshuuid.NewGenerator(),
+			vb.Driver(logger), runner, driver.NewLocks(runner, "/store/locks", logger), bdisk.Factory{},
+			apiv1.AgentOptions{}, apiv1.StemcellAPIVersion{}, logger)
+	}
+
+	Describe("Find", func() {
+		It("uses default runtime props for VMs without saved props", func() {
+			_, err := newFactory(vb.Runner()).Find(apiv1.NewVMCID("vm-old"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns error when saved runtime props cannot be read", func() {
+			_, err := newFactory(unreadableRunner{vb.Runner()}).Find(apiv1.NewVMCID("vm-1"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("connection lost"))
+		})
+	})
+})
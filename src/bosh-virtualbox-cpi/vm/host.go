@@ -4,11 +4,13 @@ import (
 	"fmt"
 	gonet "net"
 
+	"bosh-virtualbox-cpi/driver"
 	bnet "bosh-virtualbox-cpi/vm/network"
 )
 
 type Host struct {
 	networks bnet.Networks
+	locks    driver.Locks
 }
 
 func (h Host) FindNetwork(net Network) (bnet.Network, error) {
@@ -37,13 +39,13 @@ func (h Host) EnableNetworks(nets Networks) error {
 			// do nothing
 
 		case bnet.NATNetworkType:
-			err := newHostNetwork(net, natNetworksAdapter{h.networks}).Enable()
+			err := h.enableNetwork(net, natNetworksAdapter{h.networks})
 			if err != nil {
 				return err
 			}
 
 		case bnet.HostOnlyType:
-			err := newHostNetwork(net, hostOnlysAdapter{h.networks}).Enable()
+			err := h.enableNetwork(net, hostOnlysAdapter{h.networks})
 			if err != nil {
 				return err
 			}
@@ -59,6 +61,34 @@ func (h Host) EnableNetworks(nets Networks) error {
 	return nil
 }
 
+// enableNetwork prevents parallel CPI calls from creating the same network twice
+// (or, in case of host-only networks, two networks with the same name)
+func (h Host) enableNetwork(net Network, adapter netAdapter) error {
+	lock, err := h.locks.Lock(driver.NetworkLockScope(hostNetworkLockName(net)))
+	if err != nil {
+		return err
+	}
+
+	defer lock.Unlock()
+
+	return newHostNetwork(net, adapter).Enable()
+}
+
+// hostNetworkLockName identifies unnamed networks by their subnet. Host-only
+// networks share one host-wide lock since their names are picked by VirtualBox
+// (or, for host-only nets on VirtualBox 7 on macOS, always vboxnet0).
+func hostNetworkLockName(net Network) string {
+	if net.CloudPropertyType() == bnet.HostOnlyType {
+		return bnet.HostOnlyType
+	}
+
+	name := net.CloudPropertyName()
+	if len(name) == 0 {
+		name = net.Gateway() + "-" + net.Netmask()
+	}
+	return net.CloudPropertyType() + "-" + name
+}
+
 type hostNetwork struct {
 	net     Network
 	adapter netAdapter
@@ -13,7 +13,7 @@ var (
 )
 
 func (n Networks) AddHostOnly(name, gateway, netmask string) (bool, error) {
-	systemInfo, err := n.NewSystemInfo()
+	caps, err := n.driver.Capabilities()
 	if err != nil {
 		return false, err
 	}
@@ -24,10 +24,10 @@ func (n Networks) AddHostOnly(name, gateway, netmask string) (bool, error) {
 	}
 
 	var createdName string
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
-		createdName, err = n.createHostOnly(gateway, netmask)
+	if caps.HostOnlyNets() {
+		createdName, err = n.createHostOnlyNet(gateway, netmask)
 	} else {
-		createdName, err = n.createHostOnly("", "")
+		createdName, err = n.createHostOnlyIf()
 	}
 
 	if err != nil {
@@ -35,134 +35,105 @@ func (n Networks) AddHostOnly(name, gateway, netmask string) (bool, error) {
 	}
 
 	if len(name) > 0 && createdName != name {
-		n.cleanUpPartialHostOnlyCreate(createdName)
+		n.cleanUpPartialHostOnlyCreate(caps, createdName)
 		return true, fmt.Errorf("expected created host-only network '%s' to have name '%s'", createdName, name)
 	}
 
-	err = n.configureHostOnly(createdName, gateway, netmask)
-	if err != nil {
-		n.cleanUpPartialHostOnlyCreate(createdName)
-		return true, err
+	if !caps.HostOnlyNets() {
+		err = n.configureHostOnlyIf(createdName, gateway, netmask)
+		if err != nil {
+			n.cleanUpPartialHostOnlyCreate(caps, createdName)
+			return true, err
+		}
 	}
 
 	return true, nil
 }
 
-func (n Networks) createHostOnly(gateway, netmask string) (string, error) {
-	systemInfo, err := n.NewSystemInfo()
-	if err != nil {
-		return "", err
+func (n Networks) createHostOnlyNet(gateway, netmask string) (string, error) {
+	addr := net.ParseIP(netmask).To4()
+	if addr == nil {
+		return "", fmt.Errorf("Unable to parse network mask '%s'", netmask)
 	}
 
-	var matches []string
-	var errorMessage string
-	var matchesLen int
+	mask := net.IPv4Mask(addr[0], addr[1], addr[2], addr[3])
+	cidrRange, _ := mask.Size()
 
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
-		addr := net.ParseIP(netmask).To4()
-		subnetFirstIP := &net.IPNet{
-			IP:   net.ParseIP(gateway),
-			Mask: net.IPv4Mask(addr[0], addr[1], addr[2], addr[3]),
-		}
-		cidrRange, _ := net.IPv4Mask(addr[0], addr[1], addr[2], addr[3]).Size()
-		_, subnet, err := net.ParseCIDR(fmt.Sprintf("%s/%v", gateway, cidrRange))
+	_, subnet, err := net.ParseCIDR(fmt.Sprintf("%s/%v", gateway, cidrRange))
+	if err != nil {
+		return "", err
+	}
 
-		lowerIp, errGetFirstIP := systemInfo.GetFirstIP(subnetFirstIP)
-		if errGetFirstIP != nil {
-			return "", errGetFirstIP
-		}
-		upperIp, errGetLastIP := systemInfo.GetLastIP(subnet)
-		if errGetLastIP != nil {
-			return "", errGetLastIP
-		}
+	lowerIp, err := firstIP(&net.IPNet{IP: net.ParseIP(gateway), Mask: mask})
+	if err != nil {
+		return "", err
+	}
 
-		args := []string{"hostonlynet",
-			"add", fmt.Sprintf("--name=%s", "vboxnet0"),
-			fmt.Sprintf("--netmask=%s", netmask), fmt.Sprintf("--lower-ip=%s", lowerIp.String()),
-			fmt.Sprintf("--upper-ip=%s", upperIp.String()), "--disable"}
+	upperIp, err := lastIP(subnet)
+	if err != nil {
+		return "", err
+	}
 
-		// The output of the hostonlynet interface creation is empty. We need another solution to handle and verify the
-		// VboxManage creation.
-		_, err = n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
-		if err != nil {
-			return "", err
-		}
+	args := []string{"hostonlynet",
+		"add", fmt.Sprintf("--name=%s", "vboxnet0"),
+		fmt.Sprintf("--netmask=%s", netmask), fmt.Sprintf("--lower-ip=%s", lowerIp.String()),
+		fmt.Sprintf("--upper-ip=%s", upperIp.String()), "--disable"}
 
-		args = []string{"list", "hostonlynets"}
-		output, err := n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
-		if err != nil {
-			return "", err
-		}
-
-		matches = createdHostOnlyNetMatch.FindStringSubmatch(output)
-		//Define the return value of the created Host only Adapter. We're only creating one adapter,
-		//so we can also define the used name hard coded.
-		if len(matches) == 1 {
-			matches[0] = "vboxnet0"
-		}
+	// The output of the hostonlynet interface creation is empty. We need another solution to handle and verify the
+	// VboxManage creation.
+	_, err = n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
+	if err != nil {
+		return "", err
+	}
 
-		errorMessage = fmt.Sprintf(
-			"Internal inconsistency: Expected len(%s matches) == 1:",
-			createdHostOnlyNetMatch,
-		)
-		matchesLen = 1
-	} else {
-		args := []string{"hostonlyif", "create"}
-		output, err := n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
-		if err != nil {
-			return "", err
-		}
-		matches = createdHostOnlyMatch.FindStringSubmatch(output)
-		errorMessage = fmt.Sprintf(
-			"Internal inconsistency: Expected len(%s matches) == 2:",
-			createdHostOnlyMatch,
-		)
-		matchesLen = 2
+	output, err := n.driver.Execute("list", "hostonlynets")
+	if err != nil {
+		return "", err
 	}
 
-	if len(matches) != matchesLen {
-		panic(errorMessage)
+	// We're only creating one network, so we can also define the used name hard coded.
+	if !createdHostOnlyNetMatch.MatchString(output) {
+		return "", fmt.Errorf("Expected host-only network 'vboxnet0' to be listed after creation")
 	}
 
-	return matches[matchesLen-1], nil
+	return "vboxnet0", nil
 }
 
-func (n Networks) configureHostOnly(name, gateway, netmask string) error {
-	systemInfo, err := n.NewSystemInfo()
+func (n Networks) createHostOnlyIf() (string, error) {
+	output, err := n.driver.Execute("hostonlyif", "create")
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() == false {
-		args := []string{"hostonlyif", "ipconfig", name}
+	matches := createdHostOnlyMatch.FindStringSubmatch(output)
+	if len(matches) != 2 {
+		return "", fmt.Errorf("Expected created host-only interface name in output '%s'", output)
+	}
 
-		if len(gateway) > 0 {
-			args = append(args, []string{"--ip", gateway, "--netmask", netmask}...)
-		} else {
-			args = append(args, "--dhcp")
-		}
+	return matches[1], nil
+}
 
-		_, err := n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
+func (n Networks) configureHostOnlyIf(name, gateway, netmask string) error {
+	args := []string{"hostonlyif", "ipconfig", name}
 
-		return err
+	if len(gateway) > 0 {
+		args = append(args, []string{"--ip", gateway, "--netmask", netmask}...)
 	} else {
-		return nil
+		args = append(args, "--dhcp")
 	}
-}
 
-func (n Networks) cleanUpPartialHostOnlyCreate(name string) {
-	systemInfo, err := n.NewSystemInfo()
-	if err != nil {
-		n.logger.Error("vm.network.SystemInfo",
-			"Failed to get the SystemInfo: %s", err)
-	}
+	_, err := n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
 
+	return err
+}
+
+func (n Networks) cleanUpPartialHostOnlyCreate(caps driver.Capabilities, name string) {
 	args := []string{
 		"hostonlyif",
 		"remove",
 		name,
 	}
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
+	if caps.HostOnlyNets() {
 		args = []string{
 			"hostonlynet",
 			"remove",
@@ -170,7 +141,7 @@ func (n Networks) cleanUpPartialHostOnlyCreate(name string) {
 		}
 	}
 
-	_, err = n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
+	_, err := n.driver.ExecuteComplex(args, driver.ExecuteOpts{})
 	if err != nil {
 		n.logger.Error("vm.network.Networks",
 			"Failed to clean up partially created host-only network '%s': %s", name, err)
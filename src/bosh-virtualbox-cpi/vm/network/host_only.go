@@ -3,9 +3,7 @@ package network
 import (
 	"bosh-virtualbox-cpi/driver"
 	"fmt"
-	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"net"
-	"os"
 )
 
 type HostOnly struct {
@@ -36,14 +34,13 @@ func (n HostOnly) EnabledDescription() string {
 }
 
 func (n HostOnly) Enable() error {
-	logger := boshlog.NewWriterLogger(boshlog.LevelDebug, os.Stderr)
-	systemInfo, err := Networks{driver: n.driver, logger: logger}.NewSystemInfo()
+	caps, err := n.driver.Capabilities()
 	if err != nil {
 		return err
 	}
 
 	var finalArgs []string
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
+	if caps.HostOnlyNets() {
 		finalArgs = []string{"hostonlynet", "modify", fmt.Sprintf("--name=%s", n.name), "--enable"}
 	} else {
 		args := []string{"hostonlyif", "ipconfig", n.name}
@@ -4,32 +4,15 @@ import (
 	"fmt"
 	"math/big"
 	"net"
-	"runtime"
-	"strings"
 )
 
-type SystemInfo struct {
-	osVersion        string
-	vBoxMajorVersion string
-	VBoxMinorVersion string
-}
-
-func (n Networks) NewSystemInfo() (SystemInfo, error) {
-	vBoxMajorVersion, VBoxMinorVersion, err := n.getVboxVersion()
-	if err != nil {
-		return SystemInfo{}, err
-	}
-
-	return SystemInfo{getOSVersion(), vBoxMajorVersion, VBoxMinorVersion}, nil
-}
-
-// GetFirstIP Get the first usable IP address of a subnet
-func (s SystemInfo) GetFirstIP(subnet *net.IPNet) (net.IP, error) {
+// firstIP returns the first usable IP address of a subnet
+func firstIP(subnet *net.IPNet) (net.IP, error) {
 	return getIndexedIP(subnet, 0)
 }
 
-// GetLastIP Get the last usable IP address of a subnet
-func (s SystemInfo) GetLastIP(subnet *net.IPNet) (net.IP, error) {
+// lastIP returns the last usable IP address of a subnet
+func lastIP(subnet *net.IPNet) (net.IP, error) {
 	size := rangeSize(subnet)
 	if size <= 0 {
 		return nil, fmt.Errorf("can't get range size of subnet. subnet: %q", subnet)
@@ -37,38 +20,6 @@ func (s SystemInfo) GetLastIP(subnet *net.IPNet) (net.IP, error) {
 	return getIndexedIP(subnet, int(size-1))
 }
 
-// IsMacOSXVBoxSpecial6or7Case Identify if you are system is running on MAC OS X and the used
-// VirtualBox version is 6.1 or 7
-func (s SystemInfo) IsMacOSXVBoxSpecial6or7Case() bool {
-	if s.osVersion == "darwin" && (s.vBoxMajorVersion == "7") {
-		return true
-	} else {
-		return false
-	}
-}
-
-// getVboxVersion Extract the corresponding used Virtual Box version
-func (n Networks) getVboxVersion() (string, string, error) {
-	output, err := n.driver.Execute("--version")
-	if err != nil {
-		return "", "", err
-	}
-
-	output = strings.TrimSpace(output)
-	matches := strings.Split(output, ".")
-
-	if len(matches) > 3 {
-		panic(fmt.Sprintf("Internal inconsistency: Expected len(%s matches) >= 3:", createdHostOnlyMatch))
-	}
-
-	return matches[0], matches[1], nil
-}
-
-// getOSVersion Extract the corresponding used operational system
-func getOSVersion() string {
-	return runtime.GOOS
-}
-
 // rangeSize Identify the range size of valid subnet addresses.
 // The functionality is copied from https://github.com/tkestack/tke/blob/v1.9.2/pkg/util/ipallocator/allocator.go
 func rangeSize(subnet *net.IPNet) int64 {
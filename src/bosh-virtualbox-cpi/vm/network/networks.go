@@ -2,7 +2,6 @@ package network
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 
@@ -146,23 +145,25 @@ func (n Networks) BridgedNetworks() ([]Network, error) {
 }
 
 func (n Networks) HostOnlys() ([]Network, error) {
-	systemInfo, err := n.NewSystemInfo()
+	caps, err := n.driver.Capabilities()
 	if err != nil {
 		return nil, err
 	}
 
 	commandName := "hostonlyifs"
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
+	chunks := n.outputChunks
+	if caps.HostOnlyNets() {
 		commandName = "hostonlynets"
+		chunks = n.outputChunksByName
 	}
 
-	output, err := n.driver.Execute("list", fmt.Sprintf("%s", commandName))
+	output, err := n.driver.Execute("list", commandName)
 	if err != nil {
 		return nil, err
 	}
 
 	var nets []Network
-	for _, netChunk := range n.outputChunks(output) {
+	for _, netChunk := range chunks(output) {
 		net := HostOnly{driver: n.driver}
 
 		for _, line := range strings.Split(netChunk, "\n") {
@@ -213,17 +214,27 @@ func (n Networks) outputChunks(output string) []string {
 		return nil
 	}
 
-	logger := boshlog.NewWriterLogger(boshlog.LevelDebug, os.Stderr)
-	systemInfo, err := Networks{driver: n.driver, logger: logger}.NewSystemInfo()
-	if err != nil {
-		return nil
-	}
-	if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
-		output = strings.Replace(output, "\n\n", "\n", -1)
+	return strings.Split(output, "\n\n")
+}
+
+// outputChunksByName is used for output that includes empty lines
+// within a chunk (e.g. `list hostonlynets`); each chunk starts with its name
+func (n Networks) outputChunksByName(output string) []string {
+	var chunks []string
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Name:") || len(chunks) == 0 {
+			chunks = append(chunks, line)
+		} else {
+			chunks[len(chunks)-1] += "\n" + line
+		}
 	}
 
-	outputChunks := strings.Split(output, "\n\n")
-	return outputChunks
+	return chunks
 }
 
 func (n Networks) toBool(s string) (bool, error) {
@@ -2,13 +2,10 @@ package vm
 
 import (
 	"fmt"
-	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"math/rand"
-	"os"
 	"strconv"
 	"strings"
 
-	network "bosh-virtualbox-cpi/vm/network"
 	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 
@@ -70,13 +67,12 @@ func (n NICs) addNIC(nic string, net Network, host Host) (string, error) {
 			return "", err
 		}
 
-		logger := boshlog.NewWriterLogger(boshlog.LevelDebug, os.Stderr)
-		systemInfo, err := network.NewNetworks(n.driver, logger).NewSystemInfo()
+		caps, err := n.driver.Capabilities()
 		if err != nil {
 			return "", err
 		}
 
-		if systemInfo.IsMacOSXVBoxSpecial6or7Case() {
+		if caps.HostOnlyNets() {
 			args = append(args, []string{"hostonlynet", "--host-only-net" + nic, actualNet.Name()}...)
 		} else {
 			args = append(args, []string{"hostonly", "--hostonlyadapter" + nic, actualNet.Name()}...)
@@ -1,7 +1,11 @@
 package portdevices
 
+import (
+	"bosh-virtualbox-cpi/driver"
+)
+
 const (
-	IDEController  = "ide"
-	SCSIController = "scsi"
-	SATAController = "sata"
+	IDEController  = driver.StorageControllerIDE
+	SCSIController = driver.StorageControllerSCSI
+	SATAController = driver.StorageControllerSATA
 )
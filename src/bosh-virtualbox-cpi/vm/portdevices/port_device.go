@@ -2,7 +2,6 @@ package portdevices
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
@@ -11,10 +10,11 @@ import (
 	"bosh-virtualbox-cpi/driver"
 )
 
-var (
-	// Covers `"SATA-ImageUUID-0-0"="a840e5e0-947c-4e63-ac2f-678a86d13980"`
-	portImagewUUIDConfig = regexp.MustCompile(`^"(\w+)-ImageUUID-(\d+)-(\d+)"="(.+)"$`)
-)
+type AttachOpts struct {
+	MType         string // e.g. normal, writethrough
+	NonRotational bool
+	Discard       bool
+}
 
 type PortDevice struct {
 	driver driver.Driver
@@ -97,16 +97,43 @@ func (d PortDevice) Hint() apiv1.DiskHint {
 	}
 }
 
-func (d PortDevice) Attach(path string) error {
-	_, err := d.driver.Execute(
+func (d PortDevice) Attach(path string, opts AttachOpts) error {
+	mtype := opts.MType
+	if len(mtype) == 0 {
+		mtype = "normal"
+	}
+
+	args := []string{
 		"storageattach", d.vmCID.AsString(),
 		"--storagectl", d.name,
 		"--port", d.port,
 		"--device", d.device,
 		"--type", "hdd",
 		"--medium", path,
-		"--mtype", "normal",
-	)
+		"--mtype", mtype,
+	}
+
+	if opts.NonRotational {
+		args = append(args, "--nonrotational", "on")
+	}
+
+	if opts.Discard {
+		args = append(args, "--discard", "on")
+	}
+
+	if d.controller == SATAController {
+		caps, err := d.driver.Capabilities()
+		if err != nil {
+			return err
+		}
+
+		// Lets guest notice attached and detached disks as they happen
+		if caps.SATAHotPlug() {
+			args = append(args, "--hotpluggable", "on")
+		}
+	}
+
+	_, err := d.driver.Execute(args...)
 	return err
 }
 
@@ -123,22 +150,22 @@ func (d PortDevice) Detach() error {
 }
 
 func (d PortDevice) imageUUID() (string, error) {
-	output, err := d.driver.Execute("showvminfo", d.vmCID.AsString(), "--machinereadable")
+	info, err := d.driver.MachineInfo(d.vmCID.AsString())
 	if err != nil {
 		return "", bosherr.WrapErrorf(err, "Determining imageUUID")
 	}
 
-	for _, line := range strings.Split(output, "\n") {
-		matches := portImagewUUIDConfig.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			if len(matches) != 5 {
-				return "", fmt.Errorf("Internal inconsistency: Expected len(%d matches) == 5", len(matches))
-			}
+	for _, ctrl := range info.StorageControllers() {
+		if ctrl.Name != d.name {
+			continue
+		}
 
-			if matches[1] == d.name && matches[2] == d.port && matches[3] == d.device {
-				return matches[4], nil
+		for _, att := range ctrl.Attachments {
+			if att.Port == d.port && att.Device == d.device && len(att.ImageUUID) > 0 {
+				return att.ImageUUID, nil
 			}
 		}
 	}
+
 	return "", bosherr.Errorf("Failed to deterime imageUUID for PortDevice %s %s-%s", d.name, d.port, d.device)
 }
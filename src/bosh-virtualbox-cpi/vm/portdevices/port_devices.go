@@ -3,7 +3,6 @@ package portdevices
 import (
 	"fmt"
 	"regexp"
-	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -13,17 +12,14 @@ import (
 )
 
 var (
-	// Covers `storagecontrollername1="SCSI"` and `storagecontrollername1="SCSI Controller"`
-	scsiControllerName = regexp.MustCompile(`^storagecontrollername\d+="((?i:SCSI).*)"$`)
+	// Covers `SCSI` and `SCSI Controller`
+	scsiControllerName = regexp.MustCompile(`^(?i:SCSI)`)
 
-	// Covers `storagecontrollername="IDE"` and `storagecontrollername0="IDE Controller"`
-	ideControllerName = regexp.MustCompile(`^storagecontrollername\d+="((?i:IDE).*)"$`)
+	// Covers `IDE` and `IDE Controller`
+	ideControllerName = regexp.MustCompile(`^(?i:IDE)`)
 
-	// Covers `"storagecontrollername0="SATA"` and `storagecontrollername0="SATA Controller"`
-	sataControllerName = regexp.MustCompile(`^storagecontrollername\d+="((?i:SATA).*)"$`)
-
-	// Covers `SCSI-1-0"="none"` and `"SCSI Controller-1-0"="none"` (name-port-device)
-	portDeviceConfig = regexp.MustCompile(`^"(?:.+)-(\d+)-(\d+)"="none"$`)
+	// Covers `SATA` and `SATA Controller`
+	sataControllerName = regexp.MustCompile(`^(?i:SATA)`)
 )
 
 type PortDevicesOpts struct {
@@ -44,17 +40,23 @@ func NewPortDevices(vmCID apiv1.VMCID, opts PortDevicesOpts, driver driver.Drive
 
 func (d PortDevices) CDROM() (CDROM, error) {
 	// Always use SCSI for CDROM as IDE controller slots are limited
-	name, _, err := d.determineControllerName(scsiControllerName)
+	ctrl, err := d.determineController(scsiControllerName)
 	if err != nil {
 		return CDROM{}, err
 	}
 
 	// todo pick available?
-	return CDROM{driver: d.driver, vmCID: d.vmCID, name: name, port: "0", device: "0"}, nil
+	return CDROM{driver: d.driver, vmCID: d.vmCID, name: ctrl.Name, port: "0", device: "0"}, nil
 }
 
-func (d PortDevices) FindAvailable() (PortDevice, error) {
-	pds, err := d.availablePDs()
+// FindAvailable returns first unused port device on a given controller;
+// empty controller means that configured default controller is used.
+func (d PortDevices) FindAvailable(controller string) (PortDevice, error) {
+	if len(controller) == 0 {
+		controller = d.opts.Controller
+	}
+
+	pds, err := d.availablePDs(controller)
 	if err != nil {
 		return PortDevice{}, err
 	}
@@ -67,60 +69,29 @@ func (d PortDevices) FindAvailable() (PortDevice, error) {
 }
 
 func (d PortDevices) Find(controller, port, device string) (PortDevice, error) {
-	var controllerNameMatch *regexp.Regexp
-
-	switch controller {
-	case IDEController:
-		controllerNameMatch = ideControllerName
-	case SATAController:
-		controllerNameMatch = sataControllerName
-	case SCSIController, "":
+	if controller == "" {
 		controller = SCSIController
-		controllerNameMatch = scsiControllerName
-	default:
-		panic(fmt.Sprintf("Unexpected storage controller '%s'", controller))
 	}
 
-	name, _, err := d.determineControllerName(controllerNameMatch)
+	ctrl, err := d.determineController(d.controllerNameMatch(controller))
 	if err != nil {
 		return PortDevice{}, err
 	}
 
-	return NewPortDevice(d.driver, d.vmCID, controller, name, port, device), nil
+	return NewPortDevice(d.driver, d.vmCID, controller, ctrl.Name, port, device), nil
 }
 
-func (d PortDevices) availablePDs() ([]PortDevice, error) {
-	var controllerNameMatch *regexp.Regexp
-
-	switch d.opts.Controller {
-	case IDEController:
-		controllerNameMatch = ideControllerName
-	case SCSIController:
-		controllerNameMatch = scsiControllerName
-	case SATAController:
-		controllerNameMatch = sataControllerName
-	default:
-		panic(fmt.Sprintf("Unexpected storage controller '%s'", d.opts.Controller))
-	}
-
-	name, output, err := d.determineControllerName(controllerNameMatch)
+func (d PortDevices) availablePDs(controller string) ([]PortDevice, error) {
+	ctrl, err := d.determineController(d.controllerNameMatch(controller))
 	if err != nil {
 		return nil, err
 	}
 
 	var pds []PortDevice
 
-	for _, line := range strings.Split(output, "\n") {
-		if !strings.HasPrefix(line, fmt.Sprintf("\"%s-", name)) {
-			continue
-		}
-
-		matches := portDeviceConfig.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			if len(matches) != 3 {
-				panic("Internal inconsistency: Expected len(portDeviceConfig matches) == 3")
-			}
-			pd := NewPortDevice(d.driver, d.vmCID, d.opts.Controller, name, matches[1], matches[2])
+	for _, att := range ctrl.Attachments {
+		if att.IsEmpty() {
+			pd := NewPortDevice(d.driver, d.vmCID, controller, ctrl.Name, att.Port, att.Device)
 			pds = append(pds, pd)
 		}
 	}
@@ -128,25 +99,31 @@ func (d PortDevices) availablePDs() ([]PortDevice, error) {
 	return pds, nil
 }
 
-func (d PortDevices) determineControllerName(nameMatch *regexp.Regexp) (string, string, error) {
-	output, err := d.driver.Execute("showvminfo", d.vmCID.AsString(), "--machinereadable")
-	if err != nil {
-		return "", output, bosherr.WrapErrorf(err, "Determining controller name")
+func (d PortDevices) controllerNameMatch(controller string) *regexp.Regexp {
+	switch controller {
+	case IDEController:
+		return ideControllerName
+	case SCSIController:
+		return scsiControllerName
+	case SATAController:
+		return sataControllerName
+	default:
+		panic(fmt.Sprintf("Unexpected storage controller '%s'", controller))
 	}
+}
 
-	for _, line := range strings.Split(output, "\n") {
-		matches := nameMatch.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			if len(matches) != 2 {
-				panic(fmt.Sprintf("Internal inconsistency: Expected len(%s matches) == 2", nameMatch))
-			}
-
-			d.logger.Debug("vm.PortDevices",
-				"Determined controller name '%s' from output '%s'", matches[1], output)
+func (d PortDevices) determineController(nameMatch *regexp.Regexp) (driver.StorageController, error) {
+	info, err := d.driver.MachineInfo(d.vmCID.AsString())
+	if err != nil {
+		return driver.StorageController{}, bosherr.WrapErrorf(err, "Determining controller name")
+	}
 
-			return matches[1], output, nil
-		}
+	ctrl, found := info.StorageController(nameMatch)
+	if !found {
+		return driver.StorageController{}, bosherr.Error("Unknown controller name")
 	}
 
-	return "", output, bosherr.Error("Unknown controller name")
+	d.logger.Debug("vm.PortDevices", "Determined controller name '%s'", ctrl.Name)
+
+	return ctrl, nil
 }
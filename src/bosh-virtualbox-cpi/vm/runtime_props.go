@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"encoding/json"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+const (
+	runtimePropsFileName = "runtime.json"
+
+	StartTypeHeadless = "headless"
+	StartTypeGUI      = "gui"
+	StartTypeSeparate = "separate" // headless VM with GUI that can be attached/detached
+
+	// Used when graceful shutdown is requested without a timeout
+	defaultGracefulShutdownTimeout = 60 * time.Second
+)
+
+// RuntimeProps are decided when VM is created and are later
+// used by operations such as reboot or delete in separate CPI calls.
+type RuntimeProps struct {
+	StartType string `json:"start_type"`
+
+	GracefulShutdown        bool `json:"graceful_shutdown"`
+	GracefulShutdownTimeout int  `json:"graceful_shutdown_timeout"` // in seconds
+}
+
+func (p RuntimeProps) ShutdownTimeout() time.Duration {
+	if p.GracefulShutdownTimeout <= 0 {
+		return defaultGracefulShutdownTimeout
+	}
+	return time.Duration(p.GracefulShutdownTimeout) * time.Second
+}
+
+func (f Factory) defaultRuntimeProps() RuntimeProps {
+	return RuntimeProps{
+		StartType: StartTypeHeadless,
+
+		GracefulShutdown:        f.opts.GracefulShutdown,
+		GracefulShutdownTimeout: f.opts.GracefulShutdownTimeout,
+	}
+}
+
+func (f Factory) newRuntimeProps(props VMProps) RuntimeProps {
+	runtimeProps := f.defaultRuntimeProps()
+
+	runtimeProps.StartType = props.StartType
+
+	if props.GracefulShutdown != nil {
+		runtimeProps.GracefulShutdown = *props.GracefulShutdown
+	}
+
+	if props.GracefulShutdownTimeout > 0 {
+		runtimeProps.GracefulShutdownTimeout = props.GracefulShutdownTimeout
+	}
+
+	return runtimeProps
+}
+
+func (f Factory) findRuntimeProps(store Store) (RuntimeProps, error) {
+	bytes, err := store.Get(runtimePropsFileName)
+	if err != nil {
+		// VMs created before runtime props were saved (or VMs that do not exist)
+		if driver.IsNotExistErr(err) {
+			f.logger.Debug(f.logTag, "Using default runtime props: %s", err)
+			return f.defaultRuntimeProps(), nil
+		}
+		return RuntimeProps{}, bosherr.WrapError(err, "Reading VM runtime props")
+	}
+
+	props := f.defaultRuntimeProps()
+
+	err = json.Unmarshal(bytes, &props)
+	if err != nil {
+		return RuntimeProps{}, bosherr.WrapError(err, "Deserializing VM runtime props")
+	}
+
+	return props, nil
+}
+
+func (vm VMImpl) saveRuntimeProps() error {
+	bytes, err := json.Marshal(vm.runtimeProps)
+	if err != nil {
+		return bosherr.WrapError(err, "Serializing VM runtime props")
+	}
+
+	err = vm.store.Put(runtimePropsFileName, bytes)
+	if err != nil {
+		return bosherr.WrapError(err, "Saving VM runtime props")
+	}
+
+	return nil
+}
@@ -2,7 +2,6 @@ package vm
 
 import (
 	"path/filepath"
-	"strings"
 
 	"bosh-virtualbox-cpi/driver"
 )
@@ -17,17 +16,15 @@ func NewStore(path string, runner driver.Runner) Store {
 }
 
 func (m Store) List() ([]string, error) {
-	_, _, err := m.runner.Execute("mkdir", "-p", m.path)
+	names, err := m.runner.List(m.path)
 	if err != nil {
+		if driver.IsNotExistErr(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	out, _, err := m.runner.Execute("ls", "-1", m.path)
-	if err != nil {
-		return nil, err
-	}
-
-	return strings.Split(out, "\n"), nil
+	return names, nil
 }
 
 func (m Store) Path(key string) string {
@@ -35,7 +32,7 @@ func (m Store) Path(key string) string {
 }
 
 func (m Store) Put(key string, contents []byte) error {
-	_, _, err := m.runner.Execute("mkdir", "-p", m.path)
+	err := m.runner.MkdirAll(m.path)
 	if err != nil {
 		return err
 	}
@@ -48,11 +45,9 @@ func (m Store) Get(key string) ([]byte, error) {
 }
 
 func (m Store) DeleteOne(key string) error {
-	_, _, err := m.runner.Execute("rm", "-rf", filepath.Join(m.path, key))
-	return err
+	return m.runner.RemoveAll(filepath.Join(m.path, key))
 }
 
 func (m Store) Delete() error {
-	_, _, err := m.runner.Execute("rm", "-rf", m.path)
-	return err
+	return m.runner.RemoveAll(m.path)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -18,9 +19,11 @@ type VMImpl struct {
 	portDevices bpds.PortDevices
 	store       Store
 
+	runtimeProps       RuntimeProps
 	stemcellAPIVersion apiv1.StemcellAPIVersion
 
 	driver driver.Driver
+	locks  driver.Locks
 	logger boshlog.Logger
 }
 
@@ -28,16 +31,20 @@ func NewVMImpl(
 	cid apiv1.VMCID,
 	portDevices bpds.PortDevices,
 	store Store,
+	runtimeProps RuntimeProps,
 	stemcellAPIVersion apiv1.StemcellAPIVersion,
 	driver driver.Driver,
+	locks driver.Locks,
 	logger boshlog.Logger,
 ) VMImpl {
 	return VMImpl{
 		cid:                cid,
 		portDevices:        portDevices,
 		store:              store,
+		runtimeProps:       runtimeProps,
 		stemcellAPIVersion: stemcellAPIVersion,
 		driver:             driver,
+		locks:              locks,
 		logger:             logger,
 	}
 }
@@ -45,7 +52,17 @@ func NewVMImpl(
 func (vm VMImpl) ID() apiv1.VMCID { return vm.cid }
 
 func (vm VMImpl) SetProps(props VMProps) error {
-	_, err := vm.driver.Execute(
+	caps, err := vm.driver.Capabilities()
+	if err != nil {
+		return err
+	}
+
+	if !caps.SupportsFirmware(props.Firmware) {
+		return bosherr.Errorf("Expected firmware '%s' to be one of '%s' supported by VirtualBox %s",
+			props.Firmware, strings.Join(caps.Firmwares(), "', '"), caps.Version)
+	}
+
+	_, err = vm.driver.Execute(
 		"modifyvm", vm.cid.AsString(),
 		"--name", vm.cid.AsString(),
 		"--memory", strconv.Itoa(props.Memory),
@@ -100,23 +117,3 @@ func (vm VMImpl) SetMetadata(meta apiv1.VMMeta) error {
 func (vm VMImpl) ConfigureNICs(nets Networks, host Host) error {
 	return NICs{vm.driver, vm.ID()}.Configure(nets, host)
 }
-
-func (vm VMImpl) Delete() error {
-	err := vm.HaltIfRunning()
-	if err != nil {
-		return err
-	}
-
-	// todo is this necessary?
-	err = vm.detachPersistentDisks()
-	if err != nil {
-		return err
-	}
-
-	_, err = vm.driver.Execute("unregistervm", vm.cid.AsString(), "--delete")
-	if err != nil {
-		return err
-	}
-
-	return vm.store.Delete()
-}
@@ -0,0 +1,129 @@
+package vm
+
+import (
+	"path/filepath"
+	"regexp"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-virtualbox-cpi/driver"
+)
+
+var (
+	inaccessibleVMLine = regexp.MustCompile(`(?m)^"<inaccessible>" \{([0-9a-fA-F-]+)\}$`)
+)
+
+// Delete removes VM and everything associated with it. It succeeds
+// when VM is already gone, cannot be read by VirtualBox or is stuck in
+// some intermediate state, so that it can be safely retried.
+func (vm VMImpl) Delete() error {
+	state, err := vm.State()
+	if err != nil {
+		return bosherr.WrapError(err, "Determining VM state")
+	}
+
+	switch state {
+	case "missing":
+		err = vm.unregisterInaccessible()
+
+	case driver.MachineStateInaccessible:
+		err = vm.unregister(vm.cid.AsString(), false)
+
+	default:
+		err = vm.haltForDeletion(state)
+		if err != nil {
+			return err
+		}
+
+		// Persistent disks must be detached so that they are not deleted with the VM
+		err = vm.detachPersistentDisks()
+		if err != nil {
+			return bosherr.WrapError(err, "Detaching persistent disks")
+		}
+
+		err = vm.unregister(vm.cid.AsString(), true)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Removes disk attachment records and agent env, even if VM was already gone
+	err = vm.store.Delete()
+	if err != nil {
+		return bosherr.WrapError(err, "Deleting VM store")
+	}
+
+	return nil
+}
+
+// haltForDeletion brings VM into a state in which its storage can be modified
+func (vm VMImpl) haltForDeletion(state string) error {
+	var err error
+
+	switch state {
+	case "poweroff", "aborted":
+		return nil
+	case "saved":
+		_, err = vm.driver.Execute("discardstate", vm.cid.AsString())
+	case "running":
+		err = vm.halt()
+	default: // e.g. paused, stuck, gurumeditation
+		_, err = vm.driver.Execute("controlvm", vm.cid.AsString(), "poweroff")
+	}
+
+	if err != nil {
+		// VM could have been stopped in the meantime
+		newState, stateErr := vm.State()
+		if stateErr == nil && (newState == "poweroff" || newState == "aborted" || newState == "missing") {
+			return nil
+		}
+		return bosherr.WrapErrorf(err, "Stopping VM in state '%s'", state)
+	}
+
+	return nil
+}
+
+func (vm VMImpl) unregister(nameOrID string, deleteMedia bool) error {
+	args := []string{"unregistervm", nameOrID}
+
+	// Inaccessible VMs do not have known media, hence they are only unregistered
+	if deleteMedia {
+		args = append(args, "--delete")
+	}
+
+	_, err := vm.driver.Execute(args...)
+	if err != nil && !driver.IsObjectNotFoundErr(err) {
+		return bosherr.WrapErrorf(err, "Unregistering VM '%s'", nameOrID)
+	}
+
+	return nil
+}
+
+// unregisterInaccessible looks for inaccessible VM registered under this VM's
+// directory since VirtualBox cannot find such VMs by name.
+func (vm VMImpl) unregisterInaccessible() error {
+	output, err := vm.driver.Execute("list", "vms")
+	if err != nil {
+		return bosherr.WrapError(err, "Listing VMs")
+	}
+
+	for _, match := range inaccessibleVMLine.FindAllStringSubmatch(output, -1) {
+		info, err := vm.driver.MachineInfo(match[1])
+		if err != nil {
+			if driver.IsObjectNotFoundErr(err) {
+				continue
+			}
+			return bosherr.WrapErrorf(err, "Inspecting inaccessible VM '%s'", match[1])
+		}
+
+		cfgFile, _ := info.Value("CfgFile")
+
+		if filepath.Base(filepath.Dir(cfgFile)) == vm.cid.AsString() {
+			vm.logger.Debug("VMImpl", "Unregistering inaccessible VM '%s' (%s)", match[1], cfgFile)
+			return vm.unregister(match[1], false)
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	apiv1 "github.com/cloudfoundry/bosh-cpi-go/apiv1"
 
 	bdisk "bosh-virtualbox-cpi/disk"
+	"bosh-virtualbox-cpi/driver"
 )
 
 func (vm VMImpl) DiskIDs() ([]apiv1.DiskCID, error) {
@@ -40,13 +41,25 @@ func (vm VMImpl) AttachEphemeralDisk(disk bdisk.Disk) error {
 }
 
 func (vm VMImpl) attachDisk(disk bdisk.Disk, ephemeral bool) (apiv1.DiskHint, error) {
-	pd, err := vm.portDevices.FindAvailable()
+	props := disk.Props()
+
+	// Parallel attachments to the same VM would otherwise pick the same port device
+	lock, err := vm.locks.Lock(driver.VMLockScope(vm.cid.AsString()))
+	if err != nil {
+		return apiv1.DiskHint{}, err
+	}
+
+	defer lock.Unlock()
+
+	pd, err := vm.portDevices.FindAvailable(props.Controller)
 	if err != nil {
 		return apiv1.DiskHint{}, err
 	}
 
 	// Actually attach the disk
-	err = vm.hotPlugIfNecessary(!ephemeral, func() error { return pd.Attach(disk.VMDKPath()) })
+	attachFunc := func() error { return pd.Attach(disk.ImagePath(), props.AttachOpts()) }
+
+	err = vm.hotPlugIfNecessary(!ephemeral, attachFunc)
 	if err != nil {
 		return apiv1.DiskHint{}, err
 	}
@@ -149,7 +162,7 @@ func (vm VMImpl) detachPersistentDisks() error {
 		}
 
 		err = pd.Detach()
-		if err != nil {
+		if err != nil && !driver.IsObjectNotFoundErr(err) {
 			return err
 		}
 
@@ -13,11 +13,23 @@ func (vm VMImpl) hotPlug(innerFunc func() error) error {
 
 	// http://dlc.sun.com.edgesuite.net/virtualbox/4.2.16/UserManual.pdf
 	// Section 9.25: VirtualBox expert storage management
-	_, err := vm.driver.Execute("setextradata", vm.cid.AsString(), "VBoxInternal2/SilentReconfigureWhilePaused", "1")
+	info, err := vm.driver.MachineInfo(vm.cid.AsString())
 	if err != nil {
 		return err
 	}
 
+	silent, _, err := info.ExtraData("VBoxInternal2/SilentReconfigureWhilePaused")
+	if err != nil {
+		return err
+	}
+
+	if silent != "1" {
+		_, err = vm.driver.Execute("setextradata", vm.cid.AsString(), "VBoxInternal2/SilentReconfigureWhilePaused", "1")
+		if err != nil {
+			return err
+		}
+	}
+
 	var needsToResume bool
 
 	running, err := vm.IsRunning()
@@ -18,9 +18,14 @@ type VMProps struct {
 
 	Firmware   string `json:"firmware"`
 	GUI              bool
+	StartType        string `json:"start_type"`
 	ParavirtProvider string `json:"paravirtprovider"`
 
 	SharedFolders []SharedFolder `json:"shared_folders"`
+
+	// Unset values fall back to CPI configuration
+	GracefulShutdown        *bool `json:"graceful_shutdown"`
+	GracefulShutdownTimeout int   `json:"graceful_shutdown_timeout"`
 }
 
 func NewVMProps(props apiv1.VMCloudProps) (VMProps, error) {
@@ -45,5 +50,21 @@ func NewVMProps(props apiv1.VMCloudProps) (VMProps, error) {
 		}
 	}
 
+	switch vmProps.StartType {
+	case "":
+		vmProps.StartType = StartTypeHeadless
+		if vmProps.GUI {
+			vmProps.StartType = StartTypeGUI
+		}
+	case StartTypeHeadless, StartTypeGUI, StartTypeSeparate:
+		// valid
+	default:
+		return VMProps{}, errors.New("Expected start type to be one of headless, gui or separate")
+	}
+
+	if vmProps.GracefulShutdownTimeout < 0 {
+		return VMProps{}, errors.New("Expected graceful shutdown timeout not to be negative")
+	}
+
 	return vmProps, nil
 }
@@ -2,6 +2,7 @@ package vm
 
 import (
 	"regexp"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 
@@ -9,15 +10,17 @@ import (
 )
 
 var (
-	vmStarted           = regexp.MustCompile(`VM ".+?" has been successfully started`)
-	vmStateMatch        = regexp.MustCompile(`VMState="(.+?)"`)
-	vmStateInaccessible = regexp.MustCompile(`name="<inaccessible>"`)
+	vmStarted = regexp.MustCompile(`VM ".+?" has been successfully started`)
+)
+
+const (
+	vmShutdownPollInterval = 500 * time.Millisecond
 )
 
 func (vm VMImpl) Exists() (bool, error) {
-	output, err := vm.driver.Execute("showvminfo", vm.cid.AsString(), "--machinereadable")
+	_, err := vm.driver.MachineInfo(vm.cid.AsString())
 	if err != nil {
-		if vm.driver.IsMissingVMErr(output) {
+		if driver.IsObjectNotFoundErr(err) {
 			return false, nil
 		}
 		return false, err
@@ -26,14 +29,10 @@ func (vm VMImpl) Exists() (bool, error) {
 	return true, nil
 }
 
-func (vm VMImpl) Start(gui bool) error {
-	mode := "headless"
-	if gui {
-		mode = "gui"
-	}
-
+// Start uses the same start type that VM was created with
+func (vm VMImpl) Start() error {
 	output, err := vm.driver.ExecuteComplex(
-		[]string{"startvm", vm.cid.AsString(), "--type", mode},
+		[]string{"startvm", vm.cid.AsString(), "--type", vm.runtimeProps.StartType},
 		driver.ExecuteOpts{IgnoreNonZeroExitStatus: true},
 	)
 	if err != nil && !vmStarted.MatchString(output) {
@@ -49,7 +48,7 @@ func (vm VMImpl) Reboot() error {
 		return err
 	}
 
-	return vm.Start(false) // todo find out previous state
+	return vm.Start()
 }
 
 func (vm VMImpl) HaltIfRunning() error {
@@ -59,12 +58,58 @@ func (vm VMImpl) HaltIfRunning() error {
 	}
 
 	if running {
-		_, err = vm.driver.Execute("controlvm", vm.cid.AsString(), "poweroff")
+		return vm.halt()
 	}
 
+	return nil
+}
+
+// halt tries to shut down running VM via ACPI (if enabled) so that guest OS
+// can flush its file systems, and powers it off if that does not happen in time.
+func (vm VMImpl) halt() error {
+	if vm.runtimeProps.GracefulShutdown {
+		stopped, err := vm.shutDownGracefully(vm.runtimeProps.ShutdownTimeout())
+		if err != nil {
+			vm.logger.Error("VMImpl", "Failed to gracefully shut down VM '%s': %s", vm.cid.AsString(), err)
+		} else if stopped {
+			return nil
+		}
+	}
+
+	_, err := vm.driver.Execute("controlvm", vm.cid.AsString(), "poweroff")
 	return err
 }
 
+func (vm VMImpl) shutDownGracefully(timeout time.Duration) (bool, error) {
+	_, err := vm.driver.Execute("controlvm", vm.cid.AsString(), "acpipowerbutton")
+	if err != nil {
+		return false, bosherr.WrapError(err, "Pressing ACPI power button")
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		// Bypass MachineInfo cache since state is expected to change
+		output, err := vm.driver.Execute("showvminfo", vm.cid.AsString(), "--machinereadable")
+		if err != nil {
+			return false, bosherr.WrapError(err, "Determining VM state")
+		}
+
+		// VM goes through e.g. 'stopping' state before it is powered off
+		if driver.NewMachineInfo(output).State() == "poweroff" {
+			vm.logger.Debug("VMImpl", "VM '%s' shut down", vm.cid.AsString())
+			return true, nil
+		}
+
+		if time.Now().After(deadline) {
+			vm.logger.Debug("VMImpl", "VM '%s' did not shut down within %s", vm.cid.AsString(), timeout)
+			return false, nil
+		}
+
+		time.Sleep(vmShutdownPollInterval)
+	}
+}
+
 func (vm VMImpl) IsRunning() (bool, error) {
 	state, err := vm.State()
 	if err != nil {
@@ -75,22 +120,18 @@ func (vm VMImpl) IsRunning() (bool, error) {
 }
 
 func (vm VMImpl) State() (string, error) {
-	output, err := vm.driver.Execute("showvminfo", vm.cid.AsString(), "--machinereadable")
+	info, err := vm.driver.MachineInfo(vm.cid.AsString())
 	if err != nil {
-		if vm.driver.IsMissingVMErr(output) {
+		if driver.IsObjectNotFoundErr(err) {
 			return "missing", nil
 		}
 		return "", err
 	}
 
-	if vmStateInaccessible.MatchString(output) {
-		return "inaccessible", nil
-	}
-
-	matches := vmStateMatch.FindStringSubmatch(output)
-	if len(matches) == 2 {
-		return matches[1], nil
+	state := info.State()
+	if len(state) == 0 {
+		return "", bosherr.Errorf("Unknown VM state for VM '%s'", vm.cid.AsString())
 	}
 
-	return "", bosherr.Errorf("Unknown VM state:\nOutput: '%s'", output)
+	return state, nil
 }